@@ -0,0 +1,51 @@
+package kit
+
+import (
+	"context"
+
+	"github.com/mukhtarkv/workspace/kit/auth"
+	"github.com/mukhtarkv/workspace/kit/authz"
+)
+
+// AuthConfig represents the authentication and authorization configuration of a service,
+// it can be loaded with config.From:
+//
+//	auth:
+//	  enabled: true
+//	  jwt:
+//	    issuer: https://issuer.example.com
+//	    jwksURL: https://issuer.example.com/.well-known/jwks.json
+//	  policyFile: /etc/app/policy.yaml
+type AuthConfig struct {
+	// Enabled authenticates the calls, and authorizes them against the policy file.
+	Enabled     bool `yaml:"enabled"`
+	auth.Config `yaml:",inline"`
+	// PolicyFile is the authorization policy, see authz.Policy.
+	PolicyFile string `yaml:"policyFile"`
+}
+
+// Options returns the Foundation options authenticating and authorizing the calls,
+// none when the authentication is disabled.
+func (c *AuthConfig) Options(ctx context.Context) ([]Option, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+
+	authenticator, err := c.Authenticator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := authz.LoadPolicy(c.PolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	authorizer, err := authz.New(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Option{
+		WithAuth(authenticator, c.Config.Options()...),
+		WithAuthorization(authorizer),
+	}, nil
+}
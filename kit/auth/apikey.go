@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// enforce the APIKeyAuthenticator to implement the Authenticator interface.
+var _ Authenticator = (*APIKeyAuthenticator)(nil)
+
+// APIKeyHeader is the metadata key, and HTTP header, of the API keys.
+const APIKeyHeader = "x-api-key"
+
+// APIKeyAuthenticator authenticates the callers presenting a static API key in the x-api-key metadata.
+//
+// The keys are only kept hashed, and looked up by their hash, so they can't be guessed
+// from the time the lookup takes.
+type APIKeyAuthenticator struct {
+	keys map[[sha256.Size]byte]*Principal
+}
+
+// NewAPIKeyAuthenticator creates a new APIKeyAuthenticator of the API keys and their principal.
+// The method of the principals is set to MethodAPIKey.
+//
+//	a := auth.NewAPIKeyAuthenticator(map[string]*auth.Principal{
+//		os.Getenv("BILLING_API_KEY"): {Subject: "billing", Roles: []string{"service"}},
+//	})
+func NewAPIKeyAuthenticator(keys map[string]*Principal) *APIKeyAuthenticator {
+	a := &APIKeyAuthenticator{keys: make(map[[sha256.Size]byte]*Principal, len(keys))}
+	for key, p := range keys {
+		if len(key) == 0 || p == nil {
+			continue
+		}
+		principal := *p
+		principal.Method = MethodAPIKey
+		a.keys[sha256.Sum256([]byte(key))] = &principal
+	}
+	return a
+}
+
+// Authenticate returns the principal of the API key of the x-api-key metadata.
+func (a *APIKeyAuthenticator) Authenticate(_ context.Context, md metadata.MD) (*Principal, error) {
+	values := md.Get(APIKeyHeader)
+	if len(values) == 0 || len(values[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	p, ok := a.keys[sha256.Sum256([]byte(values[0]))]
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	// each call gets its own copy of the principal.
+	principal := *p
+	return &principal, nil
+}
@@ -0,0 +1,125 @@
+// Package auth authenticates the callers of the gRPC services, and of the grpc-gateway.
+//
+// Callers present either a JWT, in the authorization metadata as a bearer token, verified against
+// the keys of a JWKS (see JWTAuthenticator), or a static API key in the x-api-key metadata
+// (see APIKeyAuthenticator). The interceptors of this package put the verified Principal in the context
+// of the call, and reject the calls without valid credentials with Unauthenticated.
+//
+// See kit.WithAuth to enable them in the Foundation:
+//
+//	keys, err := auth.NewRemoteKeySet("https://issuer.example.com/.well-known/jwks.json")
+//	jwt := auth.NewJWTAuthenticator(keys, auth.WithIssuer("https://issuer.example.com"), auth.WithAudience("todo"))
+//	f, err := kit.NewFoundation("todo", kit.WithAuth(jwt, auth.WithExemptMethods("/grpc.health.v1.Health/Check")))
+//
+// Handlers read the principal from the context:
+//
+//	p, ok := auth.FromContext(ctx)
+package auth
+
+import (
+	"context"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+// Authentication errors.
+const (
+	// ErrNoCredentials when the call doesn't carry the credentials of the authenticator.
+	ErrNoCredentials = Error("credentials missing")
+	// ErrInvalidToken when the JWT is malformed, its signature or its claims are invalid.
+	ErrInvalidToken = Error("invalid token")
+	// ErrTokenExpired when the JWT expired.
+	ErrTokenExpired = Error("token expired")
+	// ErrInvalidAPIKey when the API key is unknown.
+	ErrInvalidAPIKey = Error("invalid api key")
+	// ErrKeyNotFound when the key which signed the JWT is not in the key set.
+	ErrKeyNotFound = Error("signing key not found")
+)
+
+// Error represents an auth error.
+type Error string
+
+// Error returns the error message.
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Authentication methods of the principals.
+const (
+	MethodJWT         = "jwt"
+	MethodAPIKey      = "api-key"
+	MethodCertificate = "certificate"
+)
+
+// Principal is the authenticated caller.
+type Principal struct {
+	// Subject identifies the caller, the sub claim of a JWT or the subject of an API key.
+	Subject string
+	// Method is the authentication method, MethodJWT, MethodAPIKey or MethodCertificate.
+	Method string
+	// Issuer is the iss claim of the JWT.
+	Issuer string
+	// Scopes are the scopes granted to the caller, the scope or scp claim of a JWT.
+	Scopes []string
+	// Roles are the roles of the caller, the roles claim of a JWT.
+	Roles []string
+	// Claims are all the claims of the JWT.
+	Claims map[string]interface{}
+}
+
+// HasScope reports whether the scope is granted to the principal.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the principal has the role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// NewContext returns a new context carrying the principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the context, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// Authenticator authenticates the callers from the metadata of their calls.
+type Authenticator interface {
+	// Authenticate returns the principal of the credentials in the metadata.
+	// If the metadata doesn't carry its credentials, ErrNoCredentials is returned.
+	Authenticate(ctx context.Context, md metadata.MD) (*Principal, error)
+}
+
+// Authenticators tries each authenticator in turn, until one finds its credentials in the metadata.
+type Authenticators []Authenticator
+
+// Authenticate returns the principal of the first authenticator finding its credentials,
+// ErrNoCredentials if none does.
+func (a Authenticators) Authenticate(ctx context.Context, md metadata.MD) (*Principal, error) {
+	for _, authenticator := range a {
+		p, err := authenticator.Authenticate(ctx, md)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+)
+
+// Config represents an authentication configuration, it can be loaded with config.From:
+//
+//	auth:
+//	  jwt:
+//	    issuer: https://issuer.example.com
+//	    audience: todo
+//	    jwksURL: https://issuer.example.com/.well-known/jwks.json
+//	  exemptMethods: ["/grpc.health.v1.Health/*"]
+//
+// The API keys are read from the environment variable AUTH_API_KEYS, as a list of key:subject pairs
+// separated by commas.
+type Config struct {
+	JWT JWTConfig `yaml:"jwt"`
+	// APIKeys are the subjects by API key.
+	APIKeys map[string]string `yaml:"-" env:"AUTH_API_KEYS,overwrite"`
+	// ExemptMethods are the methods callable without credentials, see WithExemptMethods.
+	ExemptMethods []string `yaml:"exemptMethods"`
+}
+
+// JWTConfig represents the configuration of the JWT verification, JWT are not accepted without key set.
+type JWTConfig struct {
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// JWKSFile is the JSON Web Key Set file of the keys.
+	JWKSFile string `yaml:"jwksFile"`
+	// JWKSURL is the JSON Web Key Set endpoint of the keys.
+	JWKSURL string `yaml:"jwksURL"`
+	// Discovery fetches the JWKS endpoint from the OpenID Connect discovery document of the issuer.
+	Discovery bool `yaml:"discovery"`
+}
+
+// Authenticator creates the authenticators of the configuration, JWT first then API keys.
+func (c *Config) Authenticator(ctx context.Context) (Authenticator, error) {
+	var authenticators Authenticators
+
+	var keys KeySet
+	var err error
+	switch {
+	case len(c.JWT.JWKSFile) > 0:
+		keys, err = NewFileKeySet(c.JWT.JWKSFile)
+	case len(c.JWT.JWKSURL) > 0:
+		keys = NewRemoteKeySet(c.JWT.JWKSURL)
+	case c.JWT.Discovery:
+		keys, err = DiscoverKeySet(ctx, c.JWT.Issuer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if keys != nil {
+		authenticators = append(authenticators, NewJWTAuthenticator(keys,
+			WithIssuer(c.JWT.Issuer),
+			WithAudience(c.JWT.Audience),
+		))
+	}
+
+	if len(c.APIKeys) > 0 {
+		keys := make(map[string]*Principal, len(c.APIKeys))
+		for key, subject := range c.APIKeys {
+			keys[key] = &Principal{Subject: subject}
+		}
+		authenticators = append(authenticators, NewAPIKeyAuthenticator(keys))
+	}
+
+	if len(authenticators) == 0 {
+		return nil, errors.New("no authentication method configured")
+	}
+	return authenticators, nil
+}
+
+// Options returns the interceptor options of the configuration.
+func (c *Config) Options() []Option {
+	return []Option{WithExemptMethods(c.ExemptMethods...)}
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mukhtarkv/workspace/kit/config"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestConfigFrom(t *testing.T) {
+	t.Setenv("AUTH_API_KEYS", "secret:billing,other:reporting")
+	k := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, k.jwks, 0o600))
+
+	rawConf := strings.NewReader(`
+jwt:
+  issuer: https://issuer
+  jwksFile: ` + path + `
+exemptMethods: ["/grpc.health.v1.Health/*"]
+`)
+	c := Config{}
+	err := config.From(rawConf, &c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "https://issuer", c.JWT.Issuer)
+	assert.Equal(t, map[string]string{"secret": "billing", "other": "reporting"}, c.APIKeys)
+	assert.Equal(t, []string{"/grpc.health.v1.Health/*"}, c.ExemptMethods)
+
+	a, err := c.Authenticator(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, a, 2)
+	p, err := a.Authenticate(context.TODO(), metadata.Pairs(APIKeyHeader, "other"))
+	assert.NoError(t, err)
+	assert.Equal(t, "reporting", p.Subject)
+}
+
+func TestConfigAuthenticatorEmpty(t *testing.T) {
+	c := Config{}
+	_, err := c.Authenticator(context.TODO())
+	assert.EqualError(t, err, "no authentication method configured")
+}
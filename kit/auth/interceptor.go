@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mukhtarkv/workspace/api/errdetails"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// Reasons of the errdetails.ErrorInfo of the unauthenticated calls.
+const (
+	ReasonCredentialsMissing = "CREDENTIALS_MISSING"
+	ReasonInvalidCredentials = "INVALID_CREDENTIALS"
+	ReasonTokenExpired       = "TOKEN_EXPIRED"
+)
+
+// Option defines an authentication interceptor option.
+type Option func(*options)
+
+// options provides a set of configurable options for the authentication interceptors.
+type options struct {
+	exempt   map[string]bool
+	services []string
+}
+
+// WithExemptMethods defines the methods callable without credentials, such as the health checks,
+// by their full name or, with a trailing wildcard, all the methods of a service:
+//
+//	auth.WithExemptMethods("/todo.v1.ToDoApp/List", "/grpc.health.v1.Health/*")
+//
+// The principal of the valid credentials is still put in the context of the exempt calls.
+func WithExemptMethods(methods ...string) Option {
+	return func(o *options) {
+		for _, m := range methods {
+			if strings.HasSuffix(m, "/*") {
+				o.services = append(o.services, strings.TrimSuffix(m, "*"))
+				continue
+			}
+			o.exempt[m] = true
+		}
+	}
+}
+
+func newOptions(opts []Option) options {
+	// default options
+	o := options{
+		exempt: map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// isExempt reports whether the method is callable without credentials.
+func (o options) isExempt(method string) bool {
+	if o.exempt[method] {
+		return true
+	}
+	for _, s := range o.services {
+		if strings.HasPrefix(method, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns the context of the call with the principal of its credentials.
+func (o options) authenticate(ctx context.Context, a Authenticator, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	p, err := a.Authenticate(ctx, md)
+	if err == nil {
+		return NewContext(ctx, p), nil
+	}
+	if o.isExempt(method) {
+		return ctx, nil
+	}
+	return ctx, unauthenticated(ctx, err)
+}
+
+// unauthenticated returns the gRPC error of the authentication failure.
+func unauthenticated(ctx context.Context, err error) error {
+	var authErr Error
+	if !errors.As(err, &authErr) {
+		log.L().Error(ctx, "authentication failed", log.Error(err))
+		return errors.Status(codes.Unavailable, "authentication unavailable")
+	}
+
+	reason := ReasonInvalidCredentials
+	switch authErr {
+	case ErrNoCredentials:
+		reason = ReasonCredentialsMissing
+	case ErrTokenExpired:
+		reason = ReasonTokenExpired
+	}
+	return errors.Status(codes.Unauthenticated, authErr.Error(), &errdetails.ErrorInfo{Reason: reason})
+}
+
+// UnaryServerInterceptor returns a unary server interceptor authenticating the calls,
+// the calls without valid credentials are rejected with Unauthenticated, unless their method is exempt.
+func UnaryServerInterceptor(a Authenticator, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := o.authenticate(ctx, a, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a stream server interceptor authenticating the streams,
+// the streams without valid credentials are rejected with Unauthenticated, unless their method is exempt.
+func StreamServerInterceptor(a Authenticator, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := o.authenticate(ss.Context(), a, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream is a grpc.ServerStream with the context of the principal.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mukhtarkv/workspace/api/errdetails"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authenticatorFunc is an Authenticator calling the function.
+type authenticatorFunc func(ctx context.Context, md metadata.MD) (*Principal, error)
+
+func (f authenticatorFunc) Authenticate(ctx context.Context, md metadata.MD) (*Principal, error) {
+	return f(ctx, md)
+}
+
+// stream is a grpc.ServerStream of a context.
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context { return s.ctx }
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	a := NewAPIKeyAuthenticator(map[string]*Principal{
+		"secret": {Subject: "billing", Roles: []string{"service"}},
+	})
+
+	p, err := a.Authenticate(context.TODO(), metadata.Pairs(APIKeyHeader, "secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "billing", Method: MethodAPIKey, Roles: []string{"service"}}, p)
+
+	_, err = a.Authenticate(context.TODO(), metadata.Pairs(APIKeyHeader, "guess"))
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	_, err = a.Authenticate(context.TODO(), metadata.MD{})
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestAuthenticators(t *testing.T) {
+	a := Authenticators{
+		NewJWTAuthenticator(&StaticKeySet{}),
+		NewAPIKeyAuthenticator(map[string]*Principal{"secret": {Subject: "billing"}}),
+	}
+
+	p, err := a.Authenticate(context.TODO(), metadata.Pairs(APIKeyHeader, "secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, "billing", p.Subject)
+
+	_, err = a.Authenticate(context.TODO(), metadata.Pairs("authorization", "Bearer a.b.c", APIKeyHeader, "secret"))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = a.Authenticate(context.TODO(), metadata.MD{})
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	a := NewAPIKeyAuthenticator(map[string]*Principal{"secret": {Subject: "billing"}})
+	interceptor := UnaryServerInterceptor(a, WithExemptMethods("/svc/Public", "/grpc.health.v1.Health/*"))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if p, ok := FromContext(ctx); ok {
+			return p.Subject, nil
+		}
+		return "anonymous", nil
+	}
+	call := func(method string, md metadata.MD) (interface{}, error) {
+		ctx := metadata.NewIncomingContext(context.TODO(), md)
+		return interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		md     metadata.MD
+		want   interface{}
+		code   codes.Code
+		reason string
+	}{
+		{"authenticated", "/svc/Private", metadata.Pairs(APIKeyHeader, "secret"), "billing", codes.OK, ""},
+		{"missing", "/svc/Private", metadata.MD{}, nil, codes.Unauthenticated, ReasonCredentialsMissing},
+		{"invalid", "/svc/Private", metadata.Pairs(APIKeyHeader, "guess"), nil, codes.Unauthenticated, ReasonInvalidCredentials},
+		{"exempt", "/svc/Public", metadata.MD{}, "anonymous", codes.OK, ""},
+		{"exempt service", "/grpc.health.v1.Health/Check", metadata.Pairs(APIKeyHeader, "guess"), "anonymous", codes.OK, ""},
+		{"exempt authenticated", "/svc/Public", metadata.Pairs(APIKeyHeader, "secret"), "billing", codes.OK, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := call(tc.method, tc.md)
+			st := status.Convert(err)
+			assert.Equal(t, tc.code, st.Code())
+			assert.Equal(t, tc.want, res)
+			if tc.code != codes.OK && assert.Len(t, st.Details(), 1) {
+				assert.Equal(t, tc.reason, st.Details()[0].(*errdetails.ErrorInfo).Reason)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptorUnavailable(t *testing.T) {
+	a := authenticatorFunc(func(ctx context.Context, md metadata.MD) (*Principal, error) {
+		return nil, errors.New("fetching jwks")
+	})
+	interceptor := UnaryServerInterceptor(a)
+
+	_, err := interceptor(context.TODO(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Private"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	a := NewAPIKeyAuthenticator(map[string]*Principal{"secret": {Subject: "billing"}})
+	interceptor := StreamServerInterceptor(a)
+	info := &grpc.StreamServerInfo{FullMethod: "/svc/Stream"}
+
+	var subject string
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		p, _ := FromContext(ss.Context())
+		subject = p.Subject
+		return nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.TODO(), metadata.Pairs(APIKeyHeader, "secret"))
+	assert.NoError(t, interceptor(nil, &stream{ctx: ctx}, info, handler))
+	assert.Equal(t, "billing", subject)
+
+	err := interceptor(nil, &stream{ctx: context.TODO()}, info, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+// enforce the JWTAuthenticator to implement the Authenticator interface.
+var _ Authenticator = (*JWTAuthenticator)(nil)
+
+// JWTOption defines a JWTAuthenticator option.
+type JWTOption func(*JWTAuthenticator)
+
+// JWTAuthenticator authenticates the callers presenting a JWT as bearer token in the authorization metadata.
+//
+// The token must be signed by a key of the key set with RS256, RS384, RS512, PS256, PS384, PS512,
+// ES256, ES384, ES512 or EdDSA, must not be expired nor used before its nbf claim,
+// and must match the issuer and audience, when defined.
+type JWTAuthenticator struct {
+	keys     KeySet
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewJWTAuthenticator creates a new JWTAuthenticator verifying the tokens with the keys of the key set.
+func NewJWTAuthenticator(keys KeySet, opts ...JWTOption) *JWTAuthenticator {
+	// default options
+	a := &JWTAuthenticator{
+		keys:   keys,
+		leeway: time.Minute,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Authenticate returns the principal of the bearer token of the authorization metadata.
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, md metadata.MD) (*Principal, error) {
+	var token string
+	for _, v := range md.Get("authorization") {
+		if len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+			token = strings.TrimSpace(v[7:])
+			break
+		}
+	}
+	if len(token) == 0 {
+		return nil, ErrNoCredentials
+	}
+	return a.Verify(ctx, token)
+}
+
+// header is the JOSE header of a JWT.
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// claims are the registered claims of a JWT.
+type claims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  audience        `json:"aud"`
+	ExpiresAt *json.Number    `json:"exp"`
+	NotBefore *json.Number    `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scp       json.RawMessage `json:"scp"`
+	Roles     []string        `json:"roles"`
+}
+
+// audience is the aud claim, a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*a = l
+	return nil
+}
+
+// Verify verifies the signature and the claims of the token, and returns its principal.
+func (a *JWTAuthenticator) Verify(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.Wrap(ErrInvalidToken, "malformed token")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, "decoding header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, "decoding signature")
+	}
+
+	key, err := a.keys.Key(ctx, h.Kid)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return nil, errors.Wrap(ErrInvalidToken, err.Error())
+		}
+		return nil, err
+	}
+	if err := verifySignature(h.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, err.Error())
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, "decoding claims")
+	}
+	var all map[string]interface{}
+	if err := decodeSegment(parts[1], &all); err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, "decoding claims")
+	}
+	if err := a.validate(c); err != nil {
+		return nil, err
+	}
+
+	return &Principal{
+		Subject: c.Subject,
+		Method:  MethodJWT,
+		Issuer:  c.Issuer,
+		Scopes:  c.scopes(),
+		Roles:   c.Roles,
+		Claims:  all,
+	}, nil
+}
+
+// validate validates the registered claims.
+func (a *JWTAuthenticator) validate(c claims) error {
+	now := a.now()
+	if c.ExpiresAt == nil {
+		return errors.Wrap(ErrInvalidToken, "exp claim missing")
+	}
+	exp, err := numericDate(*c.ExpiresAt)
+	if err != nil {
+		return errors.Wrap(ErrInvalidToken, "invalid exp claim")
+	}
+	if now.After(exp.Add(a.leeway)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != nil {
+		nbf, err := numericDate(*c.NotBefore)
+		if err != nil {
+			return errors.Wrap(ErrInvalidToken, "invalid nbf claim")
+		}
+		if now.Add(a.leeway).Before(nbf) {
+			return errors.Wrap(ErrInvalidToken, "token not valid yet")
+		}
+	}
+
+	if len(a.issuer) > 0 && c.Issuer != a.issuer {
+		return errors.Wrapf(ErrInvalidToken, "unexpected issuer '%s'", c.Issuer)
+	}
+	if len(a.audience) > 0 {
+		for _, aud := range c.Audience {
+			if aud == a.audience {
+				return nil
+			}
+		}
+		return errors.Wrap(ErrInvalidToken, "unexpected audience")
+	}
+	return nil
+}
+
+// scopes returns the space separated scope claim, or the scp claim, a string or an array of strings.
+func (c claims) scopes() []string {
+	if len(c.Scope) > 0 {
+		return strings.Fields(c.Scope)
+	}
+	var s string
+	if err := json.Unmarshal(c.Scp, &s); err == nil {
+		return strings.Fields(s)
+	}
+	var l []string
+	_ = json.Unmarshal(c.Scp, &l) //nolint
+	return l
+}
+
+func numericDate(n json.Number) (time.Time, error) {
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(f*float64(time.Second))), nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// verifySignature verifies the signature of the signed content with the algorithm and the key.
+// The algorithm must match the type of the key, so a token can't pick a weaker verification.
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	hash, ok := map[string]crypto.Hash{
+		"RS256": crypto.SHA256, "PS256": crypto.SHA256, "ES256": crypto.SHA256,
+		"RS384": crypto.SHA384, "PS384": crypto.SHA384, "ES384": crypto.SHA384,
+		"RS512": crypto.SHA512, "PS512": crypto.SHA512, "ES512": crypto.SHA512,
+	}[alg]
+
+	if alg == "EdDSA" {
+		k, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("algorithm doesn't match the key")
+		}
+		if !ed25519.Verify(k, []byte(signed), signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	if !ok {
+		return errors.Newf("unsupported algorithm '%s'", alg)
+	}
+
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(k, hash, digest, signature)
+		case "PS":
+			return rsa.VerifyPSS(k, hash, digest, signature, nil)
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || k.Curve.Params().BitSize != map[crypto.Hash]int{
+			crypto.SHA256: 256, crypto.SHA384: 384, crypto.SHA512: 521,
+		}[hash] {
+			break
+		}
+		if len(signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return errors.New("algorithm doesn't match the key")
+}
+
+// WithIssuer defines the issuer the tokens must be issued by, their iss claim.
+//
+// Issuer is not verified by default.
+func WithIssuer(issuer string) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.issuer = issuer
+	}
+}
+
+// WithAudience defines the audience the tokens must be issued for, in their aud claim.
+//
+// Audience is not verified by default.
+func WithAudience(audience string) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.audience = audience
+	}
+}
+
+// WithLeeway defines the clock skew tolerated when verifying the exp and nbf claims.
+//
+// Leeway defaults to 1 minute.
+func WithLeeway(d time.Duration) JWTOption {
+	return func(a *JWTAuthenticator) {
+		if d >= 0 {
+			a.leeway = d
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+// testKeys are the private keys signing the test tokens, and their JWKS.
+type testKeys struct {
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+	ed   ed25519.PrivateKey
+	jwks []byte
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	enc := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": enc(rsaKey.N), "e": enc(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": enc(ecKey.X), "y": enc(ecKey.Y)},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(edPub)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": enc(rsaKey.N), "e": "AQAB"},
+	}})
+	assert.NoError(t, err)
+	return &testKeys{rsa: rsaKey, ec: ecKey, ed: edKey, jwks: jwks}
+}
+
+// sign returns a token of the claims signed with the algorithm and the key of the kid.
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	h, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	var err error
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+	case "PS256":
+		signature, err = rsa.SignPSS(rand.Reader, k.rsa, crypto.SHA256, digest[:], nil)
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case "EdDSA":
+		signature = ed25519.Sign(k.ed, []byte(signed))
+	}
+	assert.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestAuthenticator(t *testing.T, k *testKeys, opts ...JWTOption) *JWTAuthenticator {
+	t.Helper()
+	keys, err := NewStaticKeySet(k.jwks)
+	assert.NoError(t, err)
+	a := NewJWTAuthenticator(keys, opts...)
+	a.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return a
+}
+
+func TestJWTVerify(t *testing.T) {
+	k := newTestKeys(t)
+	a := newTestAuthenticator(t, k, WithIssuer("https://issuer"), WithAudience("todo"))
+	claims := map[string]interface{}{
+		"iss":   "https://issuer",
+		"sub":   "user-1",
+		"aud":   []string{"todo", "other"},
+		"exp":   1700000600,
+		"scope": "todo.read todo.write",
+		"roles": []string{"admin"},
+	}
+
+	for _, tc := range []struct{ alg, kid string }{
+		{"RS256", "rsa"}, {"PS256", "rsa"}, {"ES256", "ec"}, {"EdDSA", "ed"},
+	} {
+		t.Run(tc.alg, func(t *testing.T) {
+			p, err := a.Verify(context.TODO(), k.sign(t, tc.alg, tc.kid, claims))
+			assert.NoError(t, err)
+			assert.Equal(t, "user-1", p.Subject)
+			assert.Equal(t, MethodJWT, p.Method)
+			assert.Equal(t, "https://issuer", p.Issuer)
+			assert.Equal(t, []string{"todo.read", "todo.write"}, p.Scopes)
+			assert.True(t, p.HasRole("admin"))
+			assert.Equal(t, "user-1", p.Claims["sub"])
+		})
+	}
+}
+
+func TestJWTVerifyErrors(t *testing.T) {
+	k := newTestKeys(t)
+	a := newTestAuthenticator(t, k, WithIssuer("https://issuer"), WithAudience("todo"))
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{"iss": "https://issuer", "sub": "user-1", "aud": "todo", "exp": 1700000600}
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		c := valid()
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"malformed", "abc.def", ErrInvalidToken},
+		{"expired", k.sign(t, "RS256", "rsa", with("exp", 1699999000)), ErrTokenExpired},
+		{"exp missing", k.sign(t, "RS256", "rsa", with("exp", nil)), ErrInvalidToken},
+		{"not valid yet", k.sign(t, "RS256", "rsa", with("nbf", 1700001000)), ErrInvalidToken},
+		{"issuer", k.sign(t, "RS256", "rsa", with("iss", "https://other")), ErrInvalidToken},
+		{"audience", k.sign(t, "RS256", "rsa", with("aud", "other")), ErrInvalidToken},
+		{"unknown key", k.sign(t, "RS256", "unknown", valid()), ErrInvalidToken},
+		{"encryption key", k.sign(t, "RS256", "enc", valid()), ErrInvalidToken},
+		{"algorithm mismatch", k.sign(t, "ES256", "rsa", valid()), ErrInvalidToken},
+		{"none algorithm", k.sign(t, "none", "rsa", valid()), ErrInvalidToken},
+		{"tampered", k.sign(t, "RS256", "rsa", valid())[:20] + "x" + k.sign(t, "RS256", "rsa", valid())[21:], ErrInvalidToken},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := a.Verify(context.TODO(), tc.token)
+			assert.True(t, errors.Is(err, tc.err), "unexpected error %v", err)
+		})
+	}
+}
+
+func TestJWTLeeway(t *testing.T) {
+	k := newTestKeys(t)
+	a := newTestAuthenticator(t, k)
+	token := k.sign(t, "RS256", "rsa", map[string]interface{}{"sub": "user-1", "exp": 1699999970})
+
+	_, err := a.Verify(context.TODO(), token)
+	assert.NoError(t, err)
+
+	a = newTestAuthenticator(t, k, WithLeeway(0))
+	_, err = a.Verify(context.TODO(), token)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	k := newTestKeys(t)
+	a := newTestAuthenticator(t, k)
+	token := k.sign(t, "EdDSA", "ed", map[string]interface{}{"sub": "user-1", "exp": 1700000600, "scp": []string{"a", "b"}})
+
+	p, err := a.Authenticate(context.TODO(), metadata.Pairs("authorization", "Bearer "+token))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, p.Scopes)
+
+	_, err = a.Authenticate(context.TODO(), metadata.Pairs("authorization", "Basic dXNlcjpwYXNz"))
+	assert.ErrorIs(t, err, ErrNoCredentials)
+	_, err = a.Authenticate(context.TODO(), metadata.MD{})
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
@@ -0,0 +1,395 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+)
+
+// enforce the key sets to implement the KeySet interface.
+var (
+	_ KeySet = (*StaticKeySet)(nil)
+	_ KeySet = (*RemoteKeySet)(nil)
+)
+
+// KeySet provides the public keys verifying the JWT signatures.
+type KeySet interface {
+	// Key returns the public key of the key ID.
+	// If the key set has a single key, it is returned for an empty key ID.
+	// If the key is unknown, ErrKeyNotFound is returned.
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// jwk is a JSON Web Key, RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the signature keys of the JSON Web Key Set by ID, the unsupported keys are ignored.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, errors.Wrap(err, "decoding jwks")
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, errors.Wrapf(err, "decoding jwk '%s'", k.Kid)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns the public key of the JWK, nil if its type is not supported.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Newf("unsupported curve '%s'", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errors.Newf("unsupported curve '%s'", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty jwk parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// lookup returns the key of the ID, or the single key for an empty ID.
+func lookup(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if len(kid) == 0 && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	key, ok := keys[kid]
+	return key, ok
+}
+
+// StaticKeySet is a KeySet whose keys never change, such as the keys of a local JWKS file.
+type StaticKeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+// NewStaticKeySet creates a new StaticKeySet from a JSON Web Key Set.
+func NewStaticKeySet(jwks []byte) (*StaticKeySet, error) {
+	keys, err := parseJWKS(jwks)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeySet{keys: keys}, nil
+}
+
+// NewFileKeySet creates a new StaticKeySet from a JSON Web Key Set file.
+func NewFileKeySet(path string) (*StaticKeySet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading jwks file '%s'", path)
+	}
+	return NewStaticKeySet(b)
+}
+
+// Key returns the public key of the key ID.
+func (s *StaticKeySet) Key(_ context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := lookup(s.keys, kid)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// RemoteKeySetOption defines a RemoteKeySet option.
+type RemoteKeySetOption func(*RemoteKeySet)
+
+// RemoteKeySet is a KeySet fetching the keys from a JWKS HTTP endpoint.
+//
+// The keys are fetched on first use and refreshed periodically, in the background: the cached keys
+// are served during the refresh. An unknown key ID triggers a refresh, at most once per minimum
+// refresh interval, so the keys rotated by the issuer are picked up without letting the callers
+// flood the endpoint. Concurrent callers share the same fetch, which is not canceled with them.
+type RemoteKeySet struct {
+	url                string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+	now                func() time.Time
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	fetched  time.Time
+	fetching *fetch
+}
+
+// fetch is a fetch of the keys in progress.
+type fetch struct {
+	done chan struct{}
+	err  error
+}
+
+// fetchTimeout bounds the fetches of the keys, whatever the HTTP client.
+const fetchTimeout = 30 * time.Second
+
+// NewRemoteKeySet creates a new RemoteKeySet fetching the JSON Web Key Set from the url.
+func NewRemoteKeySet(url string, opts ...RemoteKeySetOption) *RemoteKeySet {
+	// default options
+	s := &RemoteKeySet{
+		url:                url,
+		client:             &http.Client{Timeout: 10 * time.Second},
+		refreshInterval:    time.Hour,
+		minRefreshInterval: time.Minute,
+		now:                time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// DiscoverKeySet creates a new RemoteKeySet from the jwks_uri of the OpenID Connect discovery document
+// of the issuer.
+func DiscoverKeySet(ctx context.Context, issuer string, opts ...RemoteKeySetOption) (*RemoteKeySet, error) {
+	s := NewRemoteKeySet("", opts...)
+	b, err := s.get(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching openid configuration")
+	}
+
+	var conf struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return nil, errors.Wrap(err, "decoding openid configuration")
+	}
+	if len(conf.JWKSURI) == 0 {
+		return nil, errors.New("openid configuration without jwks_uri")
+	}
+	s.url = conf.JWKSURI
+	return s, nil
+}
+
+// Key returns the public key of the key ID, fetching the keys if needed.
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	keys, fetched := s.keys, s.fetched
+	s.mu.Unlock()
+
+	switch {
+	case keys == nil:
+		// there are no keys to serve until the first fetch succeeds.
+		if err := s.refresh(ctx, 0, true); err != nil {
+			return nil, err
+		}
+	case s.now().Sub(fetched) >= s.refreshInterval:
+		_ = s.refresh(ctx, s.refreshInterval, false) //nolint
+	}
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	// the key may have been rotated by the issuer.
+	if err := s.refresh(ctx, s.minRefreshInterval, true); err != nil {
+		return nil, err
+	}
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// lookup returns the cached key of the key ID.
+func (s *RemoteKeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return lookup(s.keys, kid)
+}
+
+// refresh fetches the keys if they were fetched at least minAge ago, or joins the fetch in progress.
+// It waits for the fetch unless wait is false, the previous keys are kept if it fails.
+func (s *RemoteKeySet) refresh(ctx context.Context, minAge time.Duration, wait bool) error {
+	s.mu.Lock()
+	f := s.fetching
+	if f == nil {
+		now := s.now()
+		// another caller may have refreshed the keys in the meantime.
+		if s.keys != nil && now.Sub(s.fetched) < minAge {
+			s.mu.Unlock()
+			return nil
+		}
+		// the failures are not retried before the minimum refresh interval either.
+		s.fetched = now
+		f = &fetch{done: make(chan struct{})}
+		s.fetching = f
+		go s.fetchKeys(f)
+	}
+	s.mu.Unlock()
+
+	if !wait {
+		return nil
+	}
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetchKeys fetches the keys outside of the lock, and swaps them once fetched.
+func (s *RemoteKeySet) fetchKeys(f *fetch) {
+	// the fetch is shared by the callers, it is not canceled with the one starting it.
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	var keys map[string]crypto.PublicKey
+	b, err := s.get(ctx, s.url)
+	if err != nil {
+		err = errors.Wrap(err, "fetching jwks")
+	} else {
+		keys, err = parseJWKS(b)
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+	}
+	s.fetching = nil
+	s.mu.Unlock()
+
+	f.err = err
+	close(f.done)
+}
+
+func (s *RemoteKeySet) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Newf("unexpected status %d from '%s'", resp.StatusCode, url)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// WithHTTPClient defines the HTTP client fetching the keys.
+//
+// HTTPClient defaults to a client with a 10s timeout.
+func WithHTTPClient(client *http.Client) RemoteKeySetOption {
+	return func(s *RemoteKeySet) {
+		if client != nil {
+			s.client = client
+		}
+	}
+}
+
+// WithRefreshInterval defines how often the keys are refreshed.
+//
+// RefreshInterval defaults to 1 hour.
+func WithRefreshInterval(d time.Duration) RemoteKeySetOption {
+	return func(s *RemoteKeySet) {
+		if d > 0 {
+			s.refreshInterval = d
+		}
+	}
+}
+
+// WithMinRefreshInterval defines the minimum interval between two refreshes triggered by unknown key IDs.
+//
+// MinRefreshInterval defaults to 1 minute.
+func WithMinRefreshInterval(d time.Duration) RemoteKeySetOption {
+	return func(s *RemoteKeySet) {
+		if d >= 0 {
+			s.minRefreshInterval = d
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileKeySet(t *testing.T) {
+	// Given
+	k := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, k.jwks, 0o600))
+
+	// When
+	keys, err := NewFileKeySet(path)
+
+	// Then
+	assert.NoError(t, err)
+	key, err := keys.Key(context.TODO(), "rsa")
+	assert.NoError(t, err)
+	assert.Equal(t, &k.rsa.PublicKey, key)
+	_, err = keys.Key(context.TODO(), "enc")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = keys.Key(context.TODO(), "")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	_, err = NewFileKeySet(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	// Given
+	k := newTestKeys(t)
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(k.jwks)
+	}))
+	defer srv.Close()
+
+	now := time.Unix(1700000000, 0)
+	keys := NewRemoteKeySet(srv.URL, WithRefreshInterval(time.Hour), WithMinRefreshInterval(time.Minute))
+	keys.now = func() time.Time { return now }
+
+	// When
+	_, err1 := keys.Key(context.TODO(), "rsa")
+	_, err2 := keys.Key(context.TODO(), "ec")
+	// unknown keys don't refresh before the minimum interval.
+	_, err3 := keys.Key(context.TODO(), "rotated")
+	fetchesBefore := fetches.Load()
+	now = now.Add(2 * time.Minute)
+	_, err4 := keys.Key(context.TODO(), "rotated")
+	now = now.Add(2 * time.Hour)
+	_, err5 := keys.Key(context.TODO(), "ed")
+
+	// Then
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.ErrorIs(t, err3, ErrKeyNotFound)
+	assert.EqualValues(t, 1, fetchesBefore)
+	assert.ErrorIs(t, err4, ErrKeyNotFound)
+	assert.NoError(t, err5)
+	// the keys are refreshed in the background.
+	assert.Eventually(t, func() bool { return fetches.Load() == 3 }, time.Second, 10*time.Millisecond)
+}
+
+func TestRemoteKeySetServesCachedKeysDuringRefresh(t *testing.T) {
+	// Given cached keys, and a slow endpoint
+	k := newTestKeys(t)
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		_, _ = w.Write(k.jwks)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	now := time.Unix(1700000000, 0)
+	var mu sync.Mutex
+	keys := NewRemoteKeySet(srv.URL, WithRefreshInterval(time.Hour), WithMinRefreshInterval(time.Minute))
+	keys.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	_, err := keys.Key(context.TODO(), "rsa")
+	assert.NoError(t, err)
+
+	// When the keys are due for a refresh
+	mu.Lock()
+	now = now.Add(2 * time.Hour)
+	mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys.Key(ctx, "ec")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// Then the cached keys are served without waiting for the single refresh
+	assert.NoError(t, ctx.Err())
+	assert.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+	// When a caller waiting for the refresh of an unknown key gives up
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	_, err = keys.Key(short, "rotated")
+
+	// Then it doesn't wait for the fetch
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestDiscoverKeySet(t *testing.T) {
+	// Given
+	k := newTestKeys(t)
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"issuer": "` + srv.URL + `", "jwks_uri": "` + srv.URL + `/keys"}`))
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(k.jwks)
+	})
+
+	// When
+	keys, err := DiscoverKeySet(context.TODO(), srv.URL+"/")
+
+	// Then
+	assert.NoError(t, err)
+	key, err := keys.Key(context.TODO(), "ed")
+	assert.NoError(t, err)
+	assert.NotNil(t, key)
+}
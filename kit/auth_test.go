@@ -0,0 +1,65 @@
+package kit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mukhtarkv/workspace/kit/auth"
+	"github.com/mukhtarkv/workspace/kit/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthConfigFrom(t *testing.T) {
+	rawConf := strings.NewReader(`
+enabled: true
+jwt:
+  issuer: https://issuer
+exemptMethods: ["/grpc.health.v1.Health/*"]
+policyFile: /etc/app/policy.yaml
+`)
+	var c AuthConfig
+	assert.NoError(t, config.From(rawConf, &c))
+	assert.True(t, c.Enabled)
+	assert.Equal(t, "https://issuer", c.JWT.Issuer)
+	assert.Equal(t, []string{"/grpc.health.v1.Health/*"}, c.ExemptMethods)
+	assert.Equal(t, "/etc/app/policy.yaml", c.PolicyFile)
+}
+
+func TestAuthConfigOptions(t *testing.T) {
+	ctx := context.TODO()
+	policyFile := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(policyFile, []byte(`
+default: deny
+rules:
+  - methods: ["/grpc.health.v1.Health/*"]
+    public: true
+`), 0o600))
+
+	// the calls are not authenticated when disabled.
+	opts, err := (&AuthConfig{}).Options(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, opts)
+
+	// the calls are authenticated and authorized when enabled.
+	conf := AuthConfig{
+		Enabled:    true,
+		Config:     auth.Config{APIKeys: map[string]string{"secret": "billing"}},
+		PolicyFile: policyFile,
+	}
+	opts, err = conf.Options(ctx)
+	assert.NoError(t, err)
+	o := FoundationOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	assert.NotNil(t, o.authenticator)
+	assert.NotNil(t, o.authorizer)
+
+	// the policy file is required.
+	conf.PolicyFile = filepath.Join(t.TempDir(), "missing.yaml")
+	_, err = conf.Options(ctx)
+	assert.Error(t, err)
+}
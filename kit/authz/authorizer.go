@@ -0,0 +1,239 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mukhtarkv/workspace/api/errdetails"
+	"github.com/mukhtarkv/workspace/kit/auth"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Reasons of the errdetails.ErrorInfo of the denied calls.
+const (
+	ReasonNoRule       = "NO_RULE"
+	ReasonMissingRole  = "MISSING_ROLE"
+	ReasonMissingScope = "MISSING_SCOPE"
+	ReasonNotOwner     = "NOT_OWNER"
+	ReasonPredicate    = "PREDICATE_FAILED"
+)
+
+// Predicate reports whether the principal is allowed to perform the request, such as owning the resource
+// it targets. The request is nil for the streams.
+type Predicate func(ctx context.Context, p *auth.Principal, method string, req interface{}) (bool, error)
+
+// Option defines an Authorizer option.
+type Option func(*Authorizer)
+
+// Authorizer authorizes the calls against a policy.
+type Authorizer struct {
+	rules      rules
+	allow      bool
+	predicates map[string]Predicate
+	logger     *log.Logger
+}
+
+// New creates a new Authorizer of the policy.
+// It fails if the policy is invalid, or refers to predicates not registered with WithPredicate.
+func New(policy *Policy, opts ...Option) (*Authorizer, error) {
+	if policy == nil {
+		return nil, errors.New("authorization policy is nil")
+	}
+
+	// default options
+	a := &Authorizer{
+		predicates: map[string]Predicate{},
+		logger:     log.L(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	switch policy.Default {
+	case "", Deny:
+	case Allow:
+		a.allow = true
+	default:
+		return nil, errors.Newf("unknown authorization policy default '%s'", policy.Default)
+	}
+
+	r, err := policy.index()
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range policy.Rules {
+		if _, ok := a.predicates[rule.Predicate]; len(rule.Predicate) > 0 && !ok {
+			return nil, errors.Newf("unknown authorization predicate '%s'", rule.Predicate)
+		}
+	}
+	a.rules = r
+	return a, nil
+}
+
+// Authorize reports whether the principal of the context is allowed to call the method with the request.
+// It returns a PermissionDenied error, or Unauthenticated without principal, when the call is denied.
+// The request is nil for the streams, their ownership checks are always denied.
+func (a *Authorizer) Authorize(ctx context.Context, method string, req interface{}) error {
+	p, _ := auth.FromContext(ctx)
+	reason, err := a.decide(ctx, p, method, req)
+	if err != nil {
+		a.logger.Error(ctx, "authorization failed", log.String("method", method), log.Error(err))
+		return errors.Status(codes.Internal, "authorization failed")
+	}
+	if len(reason) == 0 {
+		return nil
+	}
+
+	fields := []log.Field{
+		log.String("audit.decision", "deny"),
+		log.String("audit.method", method),
+		log.String("audit.reason", reason),
+	}
+	if p != nil {
+		fields = append(fields, log.String("audit.subject", p.Subject), log.String("audit.auth_method", p.Method))
+	}
+	a.logger.Warn(ctx, "authorization denied", fields...)
+
+	if p == nil {
+		return errors.Status(codes.Unauthenticated, "authentication required", &errdetails.ErrorInfo{
+			Reason: auth.ReasonCredentialsMissing,
+		})
+	}
+	return errors.Status(codes.PermissionDenied, "permission denied", &errdetails.ErrorInfo{
+		Reason:   reason,
+		Metadata: map[string]string{"method": method},
+	})
+}
+
+// decide returns the reason the call is denied, empty if it is allowed.
+func (a *Authorizer) decide(ctx context.Context, p *auth.Principal, method string, req interface{}) (string, error) {
+	rule := a.rules.rule(method)
+	if rule == nil {
+		if a.allow {
+			return "", nil
+		}
+		return ReasonNoRule, nil
+	}
+	if rule.Public {
+		return "", nil
+	}
+	if p == nil {
+		return auth.ReasonCredentialsMissing, nil
+	}
+
+	if len(rule.Roles) > 0 && !hasAny(p, rule.Roles) {
+		return ReasonMissingRole, nil
+	}
+	for _, scope := range rule.Scopes {
+		if !p.HasScope(scope) {
+			return ReasonMissingScope, nil
+		}
+	}
+	if hasAny(p, rule.AdminRoles) {
+		return "", nil
+	}
+
+	if len(rule.Owner) > 0 {
+		owner, ok := field(req, rule.Owner)
+		if !ok || len(owner) == 0 || owner != p.Subject {
+			return ReasonNotOwner, nil
+		}
+	}
+	if len(rule.Predicate) > 0 {
+		ok, err := a.predicates[rule.Predicate](ctx, p, method, req)
+		if err != nil {
+			return "", errors.Wrapf(err, "evaluating predicate '%s'", rule.Predicate)
+		}
+		if !ok {
+			return ReasonPredicate, nil
+		}
+	}
+	return "", nil
+}
+
+// hasAny reports whether the principal has any of the roles.
+func hasAny(p *auth.Principal, roles []string) bool {
+	for _, role := range roles {
+		if p.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// field returns the value of the field path of the proto request.
+func field(req interface{}, path string) (string, bool) {
+	msg, ok := req.(proto.Message)
+	if !ok || msg == nil {
+		return "", false
+	}
+
+	m := msg.ProtoReflect()
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd == nil || fd.IsList() || fd.IsMap() {
+			return "", false
+		}
+		v := m.Get(fd)
+		if i == len(names)-1 {
+			if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+				return "", false
+			}
+			return fmt.Sprint(v.Interface()), true
+		}
+		if fd.Kind() != protoreflect.MessageKind {
+			return "", false
+		}
+		m = v.Message()
+	}
+	return "", false
+}
+
+// UnaryServerInterceptor returns a unary server interceptor authorizing the calls.
+// It must be chained after the authentication interceptor, see auth.UnaryServerInterceptor.
+func (a *Authorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := a.Authorize(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a stream server interceptor authorizing the streams when they are opened.
+// It must be chained after the authentication interceptor, see auth.StreamServerInterceptor.
+func (a *Authorizer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.Authorize(ss.Context(), info.FullMethod, nil); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// WithPredicate registers a predicate the rules refer to by name.
+func WithPredicate(name string, predicate Predicate) Option {
+	return func(a *Authorizer) {
+		if len(name) > 0 && predicate != nil {
+			a.predicates[name] = predicate
+		}
+	}
+}
+
+// WithLogger defines the logger of the audit log lines.
+//
+// Logger defaults to the global logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(a *Authorizer) {
+		if logger != nil {
+			a.logger = logger
+		}
+	}
+}
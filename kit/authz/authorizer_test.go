@@ -0,0 +1,157 @@
+package authz
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mukhtarkv/workspace/api/errdetails"
+	pb "github.com/mukhtarkv/workspace/api/todo/todoapp/v1beta1"
+	"github.com/mukhtarkv/workspace/kit/auth"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testPolicy = `
+default: deny
+rules:
+  - methods: ["/grpc.health.v1.Health/*"]
+    public: true
+  - methods: ["/todo.todoapp.v1beta1.ToDoApp/List"]
+    scopes: [todo.read]
+  - methods: ["/todo.todoapp.v1beta1.ToDoApp/Create"]
+    roles: [user, admin]
+    scopes: [todo.write]
+  - methods: ["/todo.todoapp.v1beta1.ToDoApp/Delete"]
+    owner: id
+    adminRoles: [admin]
+  - methods: ["/todo.todoapp.v1beta1.ToDoApp/Update"]
+    owner: item.title
+    predicate: editable
+`
+
+func loadTestPolicy(t *testing.T, policy string) *Policy {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(policy), 0o600))
+	p, err := LoadPolicy(path)
+	assert.NoError(t, err)
+	return p
+}
+
+func TestAuthorize(t *testing.T) {
+	editable := func(ctx context.Context, p *auth.Principal, method string, req interface{}) (bool, error) {
+		return req.(*pb.UpdateRequest).Id != "locked", nil
+	}
+	a, err := New(loadTestPolicy(t, testPolicy), WithPredicate("editable", editable), WithLogger(log.NewNop()))
+	assert.NoError(t, err)
+
+	reader := &auth.Principal{Subject: "u1", Scopes: []string{"todo.read"}}
+	writer := &auth.Principal{Subject: "u1", Roles: []string{"user"}, Scopes: []string{"todo.read", "todo.write"}}
+	admin := &auth.Principal{Subject: "root", Roles: []string{"admin"}}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		method    string
+		req       interface{}
+		code      codes.Code
+		reason    string
+	}{
+		{"public", nil, "/grpc.health.v1.Health/Check", nil, codes.OK, ""},
+		{"anonymous", nil, "/todo.todoapp.v1beta1.ToDoApp/List", nil, codes.Unauthenticated, auth.ReasonCredentialsMissing},
+		{"scope", reader, "/todo.todoapp.v1beta1.ToDoApp/List", nil, codes.OK, ""},
+		{"missing role", reader, "/todo.todoapp.v1beta1.ToDoApp/Create", nil, codes.PermissionDenied, ReasonMissingRole},
+		{"missing scope", &auth.Principal{Subject: "u1", Roles: []string{"user"}, Scopes: []string{"todo.read"}},
+			"/todo.todoapp.v1beta1.ToDoApp/Create", nil, codes.PermissionDenied, ReasonMissingScope},
+		{"role and scopes", writer, "/todo.todoapp.v1beta1.ToDoApp/Create", nil, codes.OK, ""},
+		{"owner", reader, "/todo.todoapp.v1beta1.ToDoApp/Delete", &pb.DeleteRequest{Id: "u1"}, codes.OK, ""},
+		{"not owner", reader, "/todo.todoapp.v1beta1.ToDoApp/Delete", &pb.DeleteRequest{Id: "u2"}, codes.PermissionDenied, ReasonNotOwner},
+		{"admin", admin, "/todo.todoapp.v1beta1.ToDoApp/Delete", &pb.DeleteRequest{Id: "u2"}, codes.OK, ""},
+		{"stream owner", reader, "/todo.todoapp.v1beta1.ToDoApp/Delete", nil, codes.PermissionDenied, ReasonNotOwner},
+		{"nested owner", reader, "/todo.todoapp.v1beta1.ToDoApp/Update",
+			&pb.UpdateRequest{Id: "1", Item: &pb.UpdateRequest_ToDoItem{Title: "u1"}}, codes.OK, ""},
+		{"missing nested owner", reader, "/todo.todoapp.v1beta1.ToDoApp/Update", &pb.UpdateRequest{Id: "1"}, codes.PermissionDenied, ReasonNotOwner},
+		{"predicate", reader, "/todo.todoapp.v1beta1.ToDoApp/Update",
+			&pb.UpdateRequest{Id: "locked", Item: &pb.UpdateRequest_ToDoItem{Title: "u1"}}, codes.PermissionDenied, ReasonPredicate},
+		{"no rule", admin, "/todo.todoapp.v1beta1.ToDoApp/Purge", nil, codes.PermissionDenied, ReasonNoRule},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+			if tc.principal != nil {
+				ctx = auth.NewContext(ctx, tc.principal)
+			}
+
+			err := a.Authorize(ctx, tc.method, tc.req)
+
+			st := status.Convert(err)
+			assert.Equal(t, tc.code, st.Code())
+			if tc.code != codes.OK && assert.Len(t, st.Details(), 1) {
+				assert.Equal(t, tc.reason, st.Details()[0].(*errdetails.ErrorInfo).Reason)
+			}
+		})
+	}
+}
+
+func TestAuthorizePredicateError(t *testing.T) {
+	failing := func(ctx context.Context, p *auth.Principal, method string, req interface{}) (bool, error) {
+		return false, errors.New("storage unavailable")
+	}
+	a, err := New(&Policy{Rules: []Rule{{Methods: []string{"*"}, Predicate: "failing"}}},
+		WithPredicate("failing", failing), WithLogger(log.NewNop()))
+	assert.NoError(t, err)
+
+	err = a.Authorize(auth.NewContext(context.TODO(), &auth.Principal{Subject: "u1"}), "/svc/Method", nil)
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestDefaultAllow(t *testing.T) {
+	a, err := New(&Policy{Default: Allow, Rules: []Rule{
+		{Methods: []string{"/svc/*"}, Roles: []string{"admin"}},
+		{Methods: []string{"/svc/Public"}, Public: true},
+	}}, WithLogger(log.NewNop()))
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.Authorize(context.TODO(), "/other/Method", nil))
+	assert.NoError(t, a.Authorize(context.TODO(), "/svc/Public", nil))
+	assert.Equal(t, codes.Unauthenticated, status.Code(a.Authorize(context.TODO(), "/svc/Private", nil)))
+}
+
+func TestNewInvalidPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *Policy
+		err    string
+	}{
+		{"nil", nil, "authorization policy is nil"},
+		{"default", &Policy{Default: "maybe"}, "unknown authorization policy default 'maybe'"},
+		{"duplicate", &Policy{Rules: []Rule{{Methods: []string{"/svc/A"}}, {Methods: []string{"/svc/A"}}}}, "method '/svc/A' ruled more than once"},
+		{"predicate", &Policy{Rules: []Rule{{Methods: []string{"/svc/A"}, Predicate: "unknown"}}}, "unknown authorization predicate 'unknown'"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.policy)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	a, err := New(&Policy{Rules: []Rule{{Methods: []string{"/svc/Allowed"}, Public: true}}}, WithLogger(log.NewNop()))
+	assert.NoError(t, err)
+	unary := a.UnaryServerInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	res, err := unary(context.TODO(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Allowed"}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", res)
+	_, err = unary(context.TODO(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Denied"}, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,130 @@
+// Package authz authorizes the calls of the authenticated callers, see kit/auth, against a declarative policy.
+//
+// The policy maps the gRPC methods to the roles and scopes they require, and to the ownership predicates
+// the requests must satisfy. It is usually loaded from a YAML file with LoadPolicy:
+//
+//	default: deny
+//	rules:
+//	  - methods: ["/grpc.health.v1.Health/*"]
+//	    public: true
+//	  - methods: ["/todo.todoapp.v1beta1.ToDoApp/List"]
+//	    scopes: [todo.read]
+//	  - methods: ["/sample.sampleapp.v1.SampleApp/Delete"]
+//	    roles: [user, admin]
+//	    owner: id
+//	    adminRoles: [admin]
+//
+// The denied calls are rejected with PermissionDenied, and logged as an audit log line.
+//
+//	policy, err := authz.LoadPolicy("/etc/app/policy.yaml")
+//	authorizer, err := authz.New(policy)
+//	f, err := kit.NewFoundation("todo", kit.WithAuth(a), kit.WithAuthorization(authorizer))
+package authz
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mukhtarkv/workspace/kit/config"
+	"github.com/mukhtarkv/workspace/kit/errors"
+)
+
+// Default decisions of the methods without rule.
+const (
+	Deny  = "deny"
+	Allow = "allow"
+)
+
+// Policy is the authorization policy of the methods.
+type Policy struct {
+	// Default is the decision for the methods without rule, Deny (default) or Allow.
+	Default string `yaml:"default"`
+	// Rules are the rules of the methods.
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule defines the requirements to call methods.
+//
+// A call is allowed when its principal has one of the roles, all the scopes,
+// owns the requested resource and satisfies the predicate, when they are defined.
+type Rule struct {
+	// Methods are the full names of the methods, or all the methods of a service with a trailing wildcard,
+	// such as /todo.todoapp.v1beta1.ToDoApp/*, or all the methods with *.
+	// A method is ruled by its exact name first, then by its service, then by the wildcard.
+	Methods []string `yaml:"methods"`
+	// Public allows the calls without principal, and ignores the other requirements.
+	Public bool `yaml:"public"`
+	// Roles are the roles allowed to call the methods, any of them is required.
+	Roles []string `yaml:"roles"`
+	// Scopes are the scopes required to call the methods, all of them are required.
+	Scopes []string `yaml:"scopes"`
+	// Owner is the path of the request field holding the owner of the resource, such as user_id or item.owner,
+	// it must be equal to the subject of the principal.
+	Owner string `yaml:"owner"`
+	// Predicate is the name of a predicate, registered with WithPredicate, the call must satisfy.
+	Predicate string `yaml:"predicate"`
+	// AdminRoles are the roles exempt from the ownership and predicate checks.
+	AdminRoles []string `yaml:"adminRoles"`
+}
+
+// LoadPolicy loads the policy of a YAML file, see config.From.
+func LoadPolicy(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening policy file '%s'", path)
+	}
+	defer f.Close()
+
+	var p Policy
+	if err := config.From(f, &p); err != nil {
+		return nil, errors.Wrapf(err, "decoding policy file '%s'", path)
+	}
+	return &p, nil
+}
+
+// rules indexes the rules by method.
+type rules struct {
+	methods  map[string]*Rule
+	services map[string]*Rule
+	all      *Rule
+}
+
+// index indexes the rules of the policy, a method can only be ruled once.
+func (p *Policy) index() (rules, error) {
+	r := rules{
+		methods:  map[string]*Rule{},
+		services: map[string]*Rule{},
+	}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		for _, m := range rule.Methods {
+			var prev *Rule
+			switch {
+			case m == "*":
+				prev, r.all = r.all, rule
+			case strings.HasSuffix(m, "/*"):
+				service := strings.TrimSuffix(m, "*")
+				prev, r.services[service] = r.services[service], rule
+			default:
+				prev, r.methods[m] = r.methods[m], rule
+			}
+			if prev != nil {
+				return rules{}, errors.Newf("method '%s' ruled more than once", m)
+			}
+		}
+	}
+	return r, nil
+}
+
+// rule returns the rule of the method, nil if none.
+func (r rules) rule(method string) *Rule {
+	if rule, ok := r.methods[method]; ok {
+		return rule
+	}
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		if rule, ok := r.services[method[:i+1]]; ok {
+			return rule
+		}
+	}
+	return r.all
+}
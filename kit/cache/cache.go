@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"reflect"
 	"time"
 
 	"github.com/vmihailenco/msgpack/v5"
@@ -20,6 +21,11 @@ type Cache interface {
 	// If the data or the cache expired, cache.ErrNotFound will be returned.
 	MultiGet(ctx context.Context, keys []string, value interface{}) error
 
+	// MultiGetRaw gets the encoded data of multiple keys, aligned with the keys.
+	// The data of the missing or expired keys is nil.
+	// Use MultiGetMap to unmarshal it.
+	MultiGetRaw(ctx context.Context, keys []string) ([][]byte, error)
+
 	// Set sets the given data to the cache with a duration TTL.
 	// if the data already exist in the cache, it will be replaced by the new value and the new duration.
 	// If duration is set to Zero (0), the cache will never expire until removed by calling Delete function
@@ -29,15 +35,126 @@ type Cache interface {
 	// Delete deletes data from the cache.
 	// if the key doesn't exist, nil error will be return.
 	Delete(ctx context.Context, key string) error
+
+	// SetNX sets the given data to the cache with a duration TTL, only if the key doesn't exist.
+	// It returns whether the data has been set.
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+
+	// Incr increments the integer stored at the key by delta and returns the new value.
+	// If the key doesn't exist, it is set to delta without expiration, otherwise its TTL is kept.
+	// The counter can be read with Get into an integer.
+	Incr(ctx context.Context, key string, delta int64) (int64, error)
+
+	// Decr decrements the integer stored at the key by delta and returns the new value, see Incr.
+	Decr(ctx context.Context, key string, delta int64) (int64, error)
+
+	// MultiSet sets all the given data to the cache with the same duration TTL.
+	// Same with Set:
+	// If duration is set to Zero (0), the cache will never expire.
+	MultiSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error
+
+	// MultiDelete deletes the data of all the keys from the cache.
+	// Same with Delete:
+	// if a key doesn't exist, nil error will be return.
+	MultiDelete(ctx context.Context, keys []string) error
+
+	// Expire sets the duration TTL of the key, Zero (0) removing its expiration.
+	// It returns false if the key doesn't exist.
+	Expire(ctx context.Context, key string, expiration time.Duration) (bool, error)
+
+	// TTL returns the remaining duration TTL of the key, or Zero (0) if the key never expires.
+	// If the key doesn't exist, cache.ErrNotFound will be returned.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+
+	// CompareAndSwap replaces the data of the key by the new value with a duration TTL,
+	// only if its current data is equal to the old value once marshalled.
+	// It returns whether the data has been replaced, a missing key is never replaced.
+	CompareAndSwap(ctx context.Context, key string, old interface{}, new interface{}, expiration time.Duration) (bool, error)
+
+	// GetSet sets the given data to the cache with a duration TTL and unmarshall its previous data
+	// to the given old value.
+	// If the key didn't exist, the data is set and cache.ErrNotFound will be returned.
+	GetSet(ctx context.Context, key string, value interface{}, expiration time.Duration, old interface{}) error
+}
+
+// TaggedCache is a Cache whose entries can be tagged, and invalidated by tag.
+//
+// Tags relate the entries to the entities they depend on, such as a todo and the list pages
+// containing it, so all of them are invalidated at once when the entity changes:
+//
+//	_ = c.SetWithTags(ctx, "todos:page:1", page, time.Minute, "todos", "todo:"+id)
+//	_ = c.InvalidateTags(ctx, "todo:"+id)
+type TaggedCache interface {
+	Cache
+
+	// SetWithTags sets the given data to the cache with a duration TTL, like Set,
+	// and attaches the tags to the key.
+	SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error
+
+	// InvalidateTags deletes all the keys attached to any of the tags.
+	// If a tag doesn't exist, nil error will be return.
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// MultiGetMap gets the values of multiple keys from the cache, keyed by their key.
+// The keys of the missing or expired data are returned as missing.
+//
+// If the data of some keys cannot be unmarshalled into T, the other values are returned
+// along with a KeyErrors holding the error of each of those keys.
+// When T is a pointer, such as a protobuf message, each value is allocated and decoded into directly.
+//
+//	todos, missing, err := cache.MultiGetMap[ToDo](ctx, c, keys)
+//	items, missing, err := cache.MultiGetMap[*pb.ToDoItem](ctx, c, keys)
+func MultiGetMap[T any](ctx context.Context, c Cache, keys []string) (map[string]T, []string, error) {
+	values := make(map[string]T, len(keys))
+	if len(keys) == 0 {
+		return values, nil, nil
+	}
+
+	results, err := c.MultiGetRaw(ctx, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	var missing []string
+	var keyErrors KeyErrors
+	for i, key := range keys {
+		if results[i] == nil {
+			missing = append(missing, key)
+			continue
+		}
+
+		var v T
+		var dest interface{} = &v
+		if typ.Kind() == reflect.Ptr {
+			v = reflect.New(typ.Elem()).Interface().(T)
+			dest = v
+		}
+		if err := Unmarshal(results[i], dest); err != nil {
+			if keyErrors == nil {
+				keyErrors = KeyErrors{}
+			}
+			keyErrors[key] = err
+			continue
+		}
+		values[key] = v
+	}
+
+	if keyErrors != nil {
+		return values, missing, keyErrors
+	}
+	return values, missing, nil
 }
 
-// Marshal returns the encoded bytes of v.
+// Marshal returns the msgpack encoded bytes of v.
 func Marshal(v interface{}) ([]byte, error) {
 	return msgpack.Marshal(v)
 }
 
 // Unmarshal decodes the encoded data and stores the result
-// in the value pointed to by v
+// in the value pointed to by v. Data encoded by a Serializer is decoded
+// according to its header, whatever its codec and compression.
 func Unmarshal(data []byte, v interface{}) error {
-	return msgpack.Unmarshal(data, v)
+	return decode(data, v)
 }
@@ -0,0 +1,74 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type todo struct {
+	ID    string
+	Title string
+}
+
+func TestMultiGetMap(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c, err := memory.New()
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Set(ctx, "a", todo{ID: "a", Title: "A"}, 0))
+	assert.NoError(t, c.Set(ctx, "c", todo{ID: "c", Title: "C"}, 0))
+	assert.NoError(t, c.Set(ctx, "invalid", "not a todo", 0))
+
+	// When
+	values, missing, err := cache.MultiGetMap[todo](ctx, c, []string{"a", "b", "c", "invalid"})
+
+	// Then
+	var keyErrors cache.KeyErrors
+	assert.ErrorAs(t, err, &keyErrors)
+	assert.Len(t, keyErrors, 1)
+	assert.Contains(t, keyErrors, "invalid")
+
+	assert.Equal(t, map[string]todo{
+		"a": {ID: "a", Title: "A"},
+		"c": {ID: "c", Title: "C"},
+	}, values)
+	assert.Equal(t, []string{"b"}, missing)
+}
+
+func TestMultiGetMapProtobuf(t *testing.T) {
+	// Given protobuf messages cached with the protobuf codec
+	ctx := context.TODO()
+	c, err := memory.New(memory.WithSerializer(cache.NewSerializer(cache.WithCodec(cache.Protobuf))))
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Set(ctx, "a", wrapperspb.String("A"), 0))
+	assert.NoError(t, c.Set(ctx, "c", wrapperspb.String("C"), 0))
+
+	// When
+	values, missing, err := cache.MultiGetMap[*wrapperspb.StringValue](ctx, c, []string{"a", "b", "c"})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Len(t, values, 2)
+	assert.True(t, proto.Equal(wrapperspb.String("A"), values["a"]))
+	assert.True(t, proto.Equal(wrapperspb.String("C"), values["c"]))
+	assert.Equal(t, []string{"b"}, missing)
+}
+
+func TestMultiGetMapEmpty(t *testing.T) {
+	ctx := context.TODO()
+	c, err := memory.New()
+	assert.NoError(t, err)
+
+	values, missing, err := cache.MultiGetMap[todo](ctx, c, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, values)
+	assert.Empty(t, missing)
+}
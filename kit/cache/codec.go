@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Encoded values written by a Serializer with another codec than msgpack, or with compression,
+// start with a 2 bytes header: the headerMarker, never used by msgpack, and a byte holding
+// the header version, the codec and the compression. Values without header are msgpack encoded,
+// so caches filled before the header was introduced are still readable.
+const (
+	headerMarker  byte = 0xc1
+	headerVersion byte = 1
+)
+
+// Codec encodes and decodes the cached values.
+type Codec interface {
+	// Name returns the name of the codec.
+	Name() string
+	// Marshal returns the encoded bytes of v.
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes the data into the value pointed by v.
+	Unmarshal(data []byte, v interface{}) error
+
+	// id identifies the codec in the header of the values.
+	id() byte
+}
+
+// Built-in codecs.
+var (
+	// Msgpack encodes the values with msgpack, it is the default codec.
+	Msgpack Codec = msgpackCodec{}
+	// JSON encodes the values with encoding/json.
+	JSON Codec = jsonCodec{}
+	// Gob encodes the values with encoding/gob.
+	Gob Codec = gobCodec{}
+	// Protobuf encodes the values with protobuf, they must be proto.Message.
+	Protobuf Codec = protoCodec{}
+)
+
+// codecs are the codecs by id.
+var codecs = map[byte]Codec{
+	Msgpack.id():  Msgpack,
+	JSON.id():     JSON,
+	Gob.id():      Gob,
+	Protobuf.id(): Protobuf,
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+func (msgpackCodec) id() byte     { return 0 }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+func (jsonCodec) id() byte     { return 1 }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+func (gobCodec) id() byte     { return 2 }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Name() string { return "protobuf" }
+func (protoCodec) id() byte     { return 3 }
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.Newf("value of type %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errors.Newf("value of type %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// Compression is an algorithm compressing the encoded values.
+type Compression byte
+
+// Compression algorithms.
+const (
+	NoCompression Compression = iota
+	Zstd
+	Snappy
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+// zstdCodec returns the zstd encoder and decoder shared by the serializers,
+// they are safe for concurrent use of EncodeAll and DecodeAll.
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder) {
+	zstdOnce.Do(func() {
+		// creating them only fails with invalid options.
+		zstdEncoder, _ = zstd.NewWriter(nil)                                 //nolint
+		zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0)) //nolint
+	})
+	return zstdEncoder, zstdDecoder
+}
+
+func (c Compression) compress(data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case Zstd:
+		enc, _ := zstdCodec()
+		return enc.EncodeAll(data, nil), nil
+	case Snappy:
+		return s2.EncodeSnappy(nil, data), nil
+	}
+	return nil, errors.Newf("unknown compression %d", c)
+}
+
+func (c Compression) decompress(data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case Zstd:
+		_, dec := zstdCodec()
+		return dec.DecodeAll(data, nil)
+	case Snappy:
+		return s2.Decode(nil, data)
+	}
+	return nil, errors.Newf("unknown compression %d", c)
+}
+
+// SerializerOption defines a Serializer option.
+type SerializerOption func(*Serializer)
+
+// Serializer encodes the values written by the cache backends, with a codec and an optional compression.
+//
+// The values are decoded according to their header, whatever the serializer configuration,
+// so the codec or the compression can be changed without flushing the caches:
+// replicas running the new configuration read the values written with the previous one.
+type Serializer struct {
+	codec       Codec
+	compression Compression
+	threshold   int
+}
+
+// DefaultSerializer encodes the values with msgpack, without compression nor header.
+var DefaultSerializer = NewSerializer()
+
+// NewSerializer creates a new Serializer.
+//
+//	s := cache.NewSerializer(cache.WithCodec(cache.JSON), cache.WithCompression(cache.Zstd, 1024))
+func NewSerializer(opts ...SerializerOption) *Serializer {
+	// default options
+	s := &Serializer{
+		codec:     Msgpack,
+		threshold: 1024,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Marshal returns the encoded bytes of v.
+func (s *Serializer) Marshal(v interface{}) ([]byte, error) {
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	compression := NoCompression
+	if s.compression != NoCompression && len(data) >= s.threshold {
+		compressed, err := s.compression.compress(data)
+		if err != nil {
+			return nil, err
+		}
+		// incompressible data is kept as it is.
+		if len(compressed) < len(data) {
+			data = compressed
+			compression = s.compression
+		}
+	}
+
+	// plain msgpack values are written without header, they are readable by the previous versions.
+	if s.codec == Msgpack && compression == NoCompression {
+		return data, nil
+	}
+
+	header := headerVersion<<6 | s.codec.id()<<3 | byte(compression)
+	return append([]byte{headerMarker, header}, data...), nil
+}
+
+// Unmarshal decodes the encoded data and stores the result in the value pointed by v.
+func (s *Serializer) Unmarshal(data []byte, v interface{}) error {
+	return Unmarshal(data, v)
+}
+
+// UnmarshalInt decodes the integer counter incremented by the Incr of the cache backends.
+// Counters can only be encoded with msgpack or JSON, without compression, the encodings
+// the Redis backend decodes in its Lua script. They are written back as plain msgpack,
+// readable whatever the serializer.
+func UnmarshalInt(data []byte) (int64, error) {
+	if len(data) > 0 && data[0] == headerMarker {
+		if len(data) < 2 {
+			return 0, errors.New("cache value header is truncated")
+		}
+		header := data[1]
+		codec := header >> 3 & 0x7
+		if header>>6 != headerVersion || Compression(header&0x7) != NoCompression ||
+			(codec != Msgpack.id() && codec != JSON.id()) {
+			return 0, ErrIncrEncoding
+		}
+	}
+
+	var n int64
+	if err := decode(data, &n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// decode decodes the data according to its header.
+func decode(data []byte, v interface{}) error {
+	if len(data) == 0 || data[0] != headerMarker {
+		return msgpack.Unmarshal(data, v)
+	}
+	if len(data) < 2 {
+		return errors.New("cache value header is truncated")
+	}
+
+	header := data[1]
+	if version := header >> 6; version != headerVersion {
+		return errors.Newf("cache value header version %d is not supported", version)
+	}
+	codec, ok := codecs[header>>3&0x7]
+	if !ok {
+		return errors.Newf("cache value codec %d is not supported", header>>3&0x7)
+	}
+
+	payload, err := Compression(header & 0x7).decompress(data[2:])
+	if err != nil {
+		return errors.Wrap(err, "decompressing cache value")
+	}
+	return codec.Unmarshal(payload, v)
+}
+
+// WithCodec defines the codec encoding the values.
+//
+// Codec defaults to Msgpack.
+func WithCodec(codec Codec) SerializerOption {
+	return func(s *Serializer) {
+		if codec != nil {
+			s.codec = codec
+		}
+	}
+}
+
+// WithCompression defines the compression of the encoded values larger than the threshold in bytes.
+//
+// Compression defaults to NoCompression, threshold defaults to 1024 bytes.
+func WithCompression(compression Compression, threshold int) SerializerOption {
+	return func(s *Serializer) {
+		s.compression = compression
+		if threshold >= 0 {
+			s.threshold = threshold
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type item struct {
+	Name  string
+	Count int
+}
+
+func TestSerializerCodecs(t *testing.T) {
+	for _, codec := range []Codec{Msgpack, JSON, Gob} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			s := NewSerializer(WithCodec(codec))
+
+			b, err := s.Marshal(item{Name: "name", Count: 42})
+			assert.NoError(t, err)
+
+			var v item
+			assert.NoError(t, s.Unmarshal(b, &v))
+			assert.Equal(t, item{Name: "name", Count: 42}, v)
+		})
+	}
+}
+
+func TestSerializerProtobuf(t *testing.T) {
+	s := NewSerializer(WithCodec(Protobuf))
+
+	b, err := s.Marshal(wrapperspb.String("value"))
+	assert.NoError(t, err)
+
+	v := &wrapperspb.StringValue{}
+	assert.NoError(t, Unmarshal(b, v))
+	assert.True(t, proto.Equal(wrapperspb.String("value"), v))
+
+	_, err = s.Marshal(item{})
+	assert.Error(t, err)
+}
+
+func TestSerializerCompression(t *testing.T) {
+	value := strings.Repeat("compressible ", 200)
+
+	for _, compression := range []Compression{Zstd, Snappy} {
+		s := NewSerializer(WithCompression(compression, 100))
+
+		b, err := s.Marshal(value)
+		assert.NoError(t, err)
+		assert.Equal(t, headerMarker, b[0])
+		assert.Equal(t, byte(compression), b[1]&0x7)
+		assert.Less(t, len(b), len(value))
+
+		var v string
+		assert.NoError(t, Unmarshal(b, &v))
+		assert.Equal(t, value, v)
+
+		// values under the threshold are not compressed.
+		b, err = s.Marshal("small")
+		assert.NoError(t, err)
+		assert.NotEqual(t, headerMarker, b[0])
+	}
+}
+
+func TestSerializerHeader(t *testing.T) {
+	// msgpack values are written without header, as before the serializers.
+	b, err := DefaultSerializer.Marshal("value")
+	assert.NoError(t, err)
+	legacy, err := Marshal("value")
+	assert.NoError(t, err)
+	assert.Equal(t, legacy, b)
+
+	// values written with any codec are readable by any serializer.
+	b, err = NewSerializer(WithCodec(JSON)).Marshal("value")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{headerMarker, headerVersion<<6 | JSON.id()<<3}, b[:2])
+
+	var v string
+	assert.NoError(t, DefaultSerializer.Unmarshal(b, &v))
+	assert.Equal(t, "value", v)
+
+	assert.Error(t, Unmarshal([]byte{headerMarker}, &v))
+	assert.Error(t, Unmarshal([]byte{headerMarker, 3 << 6}, &v))
+}
@@ -1,2 +1,4 @@
 // Package cache contains interfaces for caching data.
+//
+// Implementations are provided by kit/cache/redis and, in-process, by kit/cache/memory.
 package cache
@@ -1,10 +1,18 @@
 package cache
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
 // Cache errors.
 const (
 	ErrKeyInvalid   = Error("cache key is not valid")
 	ErrValueInvalid = Error("cache value is invalid")
 	ErrNotFound     = Error("cache value not found")
+	// ErrIncrEncoding is returned by Incr for the values it cannot decode, see UnmarshalInt.
+	ErrIncrEncoding = Error("cache value encoding is not supported by incr")
 )
 
 // Error represents a cache error.
@@ -14,3 +22,21 @@
 func (e Error) Error() string {
 	return string(e)
 }
+
+// KeyErrors represents the errors of some keys of a multiple keys operation.
+type KeyErrors map[string]error
+
+// Error returns the error messages of the keys.
+func (e KeyErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	messages := make([]string, 0, len(keys))
+	for _, key := range keys {
+		messages = append(messages, fmt.Sprintf("key '%s': %v", key, e[key]))
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,310 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+)
+
+// Loader loads the value of a key missing from the cache, such as from a database.
+// Returning ErrNotFound caches the absence of the value when negative caching is enabled (see WithNegativeTTL).
+type Loader func(ctx context.Context) (interface{}, error)
+
+// LoadingOption defines a LoadingCache option.
+type LoadingOption func(*loadingOptions)
+
+// loadingOptions provides a set of configurable options for the LoadingCache.
+type loadingOptions struct {
+	staleTTL       time.Duration
+	negativeTTL    time.Duration
+	beta           float64
+	refreshTimeout time.Duration
+}
+
+// entry is the value stored by the LoadingCache, along with the metadata to refresh it.
+type entry struct {
+	// Value is the encoded value.
+	Value []byte `msgpack:"v,omitempty"`
+	// NotFound records the loader returned ErrNotFound.
+	NotFound bool `msgpack:"n,omitempty"`
+	// FreshUntil is the time the value becomes stale, zero never.
+	FreshUntil time.Time `msgpack:"f"`
+	// Delta is how long loading the value took.
+	Delta time.Duration `msgpack:"d"`
+}
+
+// LoadingCache is a read-through cache over any Cache.
+//
+// Concurrent loads of the same key are collapsed into a single call of the loader,
+// so a cold key doesn't stampede the database. The loader is called with a context detached
+// from the cancellation of the callers and bounded by the refresh timeout (see WithRefreshTimeout),
+// a caller giving up doesn't fail the load for the others. Values can be served stale while they are
+// refreshed in the background (see WithStaleTTL), and refreshed early with a probability
+// increasing as they get close to their expiration (see WithEarlyExpiration).
+//
+// Values are stored along with their metadata, keys written by GetOrLoad must only be read by GetOrLoad.
+type LoadingCache struct {
+	cache Cache
+	opts  loadingOptions
+	group group
+	now   func() time.Time
+}
+
+// NewLoadingCache creates a new LoadingCache over the given cache.
+func NewLoadingCache(c Cache, opts ...LoadingOption) *LoadingCache {
+	// default options
+	o := loadingOptions{
+		refreshTimeout: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &LoadingCache{
+		cache: c,
+		opts:  o,
+		now:   time.Now,
+	}
+}
+
+// GetOrLoad gets the value of the key from the cache and unmarshalls it into dest.
+// If the key is missing, the loader is called and its value is cached for the ttl duration,
+// 0 meaning the value never expires.
+//
+//	var todo ToDo
+//	err := loading.GetOrLoad(ctx, "todo:"+id, &todo, time.Minute, func(ctx context.Context) (interface{}, error) {
+//		return storage.Fetch(ctx, id)
+//	})
+//
+// If the loader returns ErrNotFound and negative caching is enabled, ErrNotFound is returned
+// until the negative TTL expires, without calling the loader again.
+func (l *LoadingCache) GetOrLoad(ctx context.Context, key string, dest interface{}, ttl time.Duration, loader Loader) error {
+	if len(key) == 0 {
+		return ErrKeyInvalid
+	}
+	if loader == nil {
+		return errors.New("loader is nil")
+	}
+
+	var e entry
+	if err := l.cache.Get(ctx, key, &e); err == nil {
+		if l.expiresSoon(e) {
+			l.refresh(key, ttl, loader)
+		}
+		return e.decode(dest)
+	}
+
+	e, err := l.load(ctx, key, ttl, loader)
+	if err != nil {
+		return err
+	}
+	return e.decode(dest)
+}
+
+// expiresSoon reports whether the entry is stale or is picked for early expiration.
+func (l *LoadingCache) expiresSoon(e entry) bool {
+	if e.FreshUntil.IsZero() {
+		return false
+	}
+	now := l.now()
+	if !now.Before(e.FreshUntil) {
+		return true
+	}
+	if l.opts.beta <= 0 || e.NotFound {
+		return false
+	}
+
+	// the probability of an early refresh increases as the expiration gets close,
+	// and with the time the value takes to load.
+	gap := -float64(e.Delta) * l.opts.beta * math.Log(1-rand.Float64()) //nolint:gosec
+	return now.Add(time.Duration(gap)).After(e.FreshUntil)
+}
+
+// refresh reloads the key in the background, unless it is already loading.
+func (l *LoadingCache) refresh(key string, ttl time.Duration, loader Loader) {
+	if l.group.loading(key) {
+		return
+	}
+	go func() {
+		_, _ = l.load(context.Background(), key, ttl, loader) //nolint
+	}()
+}
+
+// load calls the loader once for all the concurrent callers, and caches its result.
+func (l *LoadingCache) load(ctx context.Context, key string, ttl time.Duration, loader Loader) (entry, error) {
+	return l.group.do(ctx, key, func() (entry, error) {
+		// the load is shared by all the callers, it outlives the caller starting it.
+		ctx, cancel := context.WithTimeout(detachedContext{ctx}, l.opts.refreshTimeout)
+		defer cancel()
+
+		start := l.now()
+		v, err := loader(ctx)
+		now := l.now()
+
+		if errors.Is(err, ErrNotFound) {
+			if l.opts.negativeTTL > 0 {
+				e := entry{NotFound: true, FreshUntil: now.Add(l.opts.negativeTTL)}
+				// failing to cache the result doesn't fail the load.
+				_ = l.cache.Set(ctx, key, e, l.opts.negativeTTL) //nolint
+			}
+			return entry{}, ErrNotFound
+		}
+		if err != nil {
+			return entry{}, err
+		}
+		if v == nil {
+			return entry{}, ErrValueInvalid
+		}
+
+		b, err := Marshal(v)
+		if err != nil {
+			return entry{}, errors.Wrapf(err, "marshalling value for key '%s'", key)
+		}
+
+		e := entry{Value: b, Delta: now.Sub(start)}
+		expiration := time.Duration(0)
+		if ttl > 0 {
+			e.FreshUntil = now.Add(ttl)
+			expiration = ttl + l.opts.staleTTL
+		}
+		// failing to cache the value doesn't fail the load.
+		_ = l.cache.Set(ctx, key, e, expiration) //nolint
+		return e, nil
+	})
+}
+
+// decode unmarshalls the value of the entry into dest.
+func (e entry) decode(dest interface{}) error {
+	if e.NotFound {
+		return ErrNotFound
+	}
+	return Unmarshal(e.Value, dest)
+}
+
+// detachedContext keeps the values of its parent, without its deadline and cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+// call is a load in progress.
+type call struct {
+	done  chan struct{}
+	entry entry
+	err   error
+}
+
+// group collapses the concurrent loads of a key.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do calls fn once for all the concurrent callers of the same key, in its own goroutine,
+// a caller stops waiting when its context is canceled, without stopping fn.
+func (g *group) do(ctx context.Context, key string, fn func() (entry, error)) (entry, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call{}
+	}
+	c, ok := g.calls[key]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.call(key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.entry, c.err
+	case <-ctx.Done():
+		return entry{}, ctx.Err()
+	}
+}
+
+// call runs fn for the call of the key, and releases its waiters.
+func (g *group) call(key string, c *call, fn func() (entry, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = errors.Newf("loader panicked: %v", r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.entry, c.err = fn()
+}
+
+// loading reports whether the key is being loaded.
+func (g *group) loading(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.calls[key]
+	return ok
+}
+
+// WithStaleTTL defines how long a value is served stale after its ttl expired,
+// while it is refreshed in the background.
+//
+// StaleTTL defaults to 0, values are loaded again once expired.
+func WithStaleTTL(d time.Duration) LoadingOption {
+	return func(o *loadingOptions) {
+		if d > 0 {
+			o.staleTTL = d
+		}
+	}
+}
+
+// WithNegativeTTL defines how long the loader returning ErrNotFound is cached.
+//
+// NegativeTTL defaults to 0, missing values are not cached.
+func WithNegativeTTL(d time.Duration) LoadingOption {
+	return func(o *loadingOptions) {
+		if d > 0 {
+			o.negativeTTL = d
+		}
+	}
+}
+
+// WithEarlyExpiration enables the probabilistic early expiration of the values:
+// each read refreshes the value in the background with a probability increasing as its expiration
+// gets close, scaled by beta and how long the value took to load. A beta of 1 is a sensible default,
+// greater values favor earlier refreshes.
+//
+// EarlyExpiration is disabled by default.
+func WithEarlyExpiration(beta float64) LoadingOption {
+	return func(o *loadingOptions) {
+		if beta > 0 {
+			o.beta = beta
+		}
+	}
+}
+
+// WithRefreshTimeout defines the timeout of the loads, including the background refreshes.
+// A load is shared by the concurrent callers, it is not canceled when they stop waiting.
+//
+// RefreshTimeout defaults to 1 minute.
+func WithRefreshTimeout(d time.Duration) LoadingOption {
+	return func(o *loadingOptions) {
+		if d > 0 {
+			o.refreshTimeout = d
+		}
+	}
+}
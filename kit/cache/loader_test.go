@@ -0,0 +1,211 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/cache/memory"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLoadingCache(t *testing.T, opts ...cache.LoadingOption) *cache.LoadingCache {
+	c, err := memory.New()
+	if err != nil {
+		t.Fatalf("setting up memory cache %v", err)
+	}
+	return cache.NewLoadingCache(c, opts...)
+}
+
+func TestGetOrLoad(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	l := newLoadingCache(t)
+
+	var loads atomic.Int32
+	loader := func(ctx context.Context) (interface{}, error) {
+		loads.Add(1)
+		return "value", nil
+	}
+
+	// When
+	var v1, v2 string
+	err1 := l.GetOrLoad(ctx, "key", &v1, time.Minute, loader)
+	err2 := l.GetOrLoad(ctx, "key", &v2, time.Minute, loader)
+
+	// Then
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, "value", v1)
+	assert.Equal(t, "value", v2)
+	assert.EqualValues(t, 1, loads.Load())
+}
+
+func TestGetOrLoadCollapsesConcurrentLoads(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	l := newLoadingCache(t)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (interface{}, error) {
+		loads.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	// When
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, l.GetOrLoad(ctx, "key", &results[i], time.Minute, loader))
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Then
+	assert.EqualValues(t, 1, loads.Load())
+	for _, r := range results {
+		assert.Equal(t, 42, r)
+	}
+}
+
+func TestGetOrLoadLeaderCanceled(t *testing.T) {
+	// Given a load started by a caller, awaited by another
+	l := newLoadingCache(t)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (interface{}, error) {
+		loads.Add(1)
+		select {
+		case <-release:
+			return "value", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error)
+	go func() {
+		var v string
+		leaderErr <- l.GetOrLoad(leaderCtx, "key", &v, time.Minute, loader)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	var v string
+	followerErr := make(chan error)
+	go func() {
+		followerErr <- l.GetOrLoad(context.Background(), "key", &v, time.Minute, loader)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// When the first caller gives up
+	cancel()
+	assert.ErrorIs(t, <-leaderErr, context.Canceled)
+	close(release)
+
+	// Then the other caller still gets the value
+	assert.NoError(t, <-followerErr)
+	assert.Equal(t, "value", v)
+	assert.EqualValues(t, 1, loads.Load())
+}
+
+func TestGetOrLoadErrors(t *testing.T) {
+	ctx := context.TODO()
+	l := newLoadingCache(t)
+
+	var v string
+	loadErr := errors.New("database down")
+	err := l.GetOrLoad(ctx, "key", &v, time.Minute, func(ctx context.Context) (interface{}, error) {
+		return nil, loadErr
+	})
+	assert.ErrorIs(t, err, loadErr)
+
+	err = l.GetOrLoad(ctx, "key", &v, time.Minute, func(ctx context.Context) (interface{}, error) {
+		return "value", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+
+	assert.ErrorIs(t, l.GetOrLoad(ctx, "", &v, time.Minute, nil), cache.ErrKeyInvalid)
+}
+
+func TestGetOrLoadNegativeCaching(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	l := newLoadingCache(t, cache.WithNegativeTTL(time.Minute))
+
+	var loads atomic.Int32
+	loader := func(ctx context.Context) (interface{}, error) {
+		loads.Add(1)
+		return nil, cache.ErrNotFound
+	}
+
+	// When
+	var v string
+	err1 := l.GetOrLoad(ctx, "key", &v, time.Minute, loader)
+	err2 := l.GetOrLoad(ctx, "key", &v, time.Minute, loader)
+
+	// Then
+	assert.ErrorIs(t, err1, cache.ErrNotFound)
+	assert.ErrorIs(t, err2, cache.ErrNotFound)
+	assert.EqualValues(t, 1, loads.Load())
+}
+
+func TestGetOrLoadStaleWhileRevalidate(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	l := newLoadingCache(t, cache.WithStaleTTL(time.Minute))
+
+	var loads atomic.Int32
+	loader := func(ctx context.Context) (interface{}, error) {
+		return int(loads.Add(1)), nil
+	}
+
+	var v int
+	assert.NoError(t, l.GetOrLoad(ctx, "key", &v, 20*time.Millisecond, loader))
+	assert.Equal(t, 1, v)
+
+	// When
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, l.GetOrLoad(ctx, "key", &v, 20*time.Millisecond, loader))
+
+	// Then the stale value is served, and refreshed in the background.
+	assert.Equal(t, 1, v)
+	assert.Eventually(t, func() bool {
+		return l.GetOrLoad(ctx, "key", &v, time.Minute, loader) == nil && v == 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestGetOrLoadEarlyExpiration(t *testing.T) {
+	// Given a slow loader and a beta large enough for almost every read to refresh the value.
+	ctx := context.TODO()
+	l := newLoadingCache(t, cache.WithEarlyExpiration(1000))
+
+	var loads atomic.Int32
+	loader := func(ctx context.Context) (interface{}, error) {
+		time.Sleep(50 * time.Millisecond)
+		return int(loads.Add(1)), nil
+	}
+
+	var v int
+	assert.NoError(t, l.GetOrLoad(ctx, "key", &v, time.Second, loader))
+	assert.Equal(t, 1, v)
+
+	// When the value is read before it expires.
+	// Then it is refreshed in the background.
+	assert.Eventually(t, func() bool {
+		return l.GetOrLoad(ctx, "key", &v, time.Second, loader) == nil && v == 2
+	}, 500*time.Millisecond, 5*time.Millisecond)
+}
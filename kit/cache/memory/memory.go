@@ -0,0 +1,630 @@
+// Package memory provides an in-process implementation of cache.Cache.
+//
+// Values are encoded with a cache.Serializer, msgpack by default, so they behave exactly as with the Redis backend:
+// the cached value is a copy, and decoding into another type follows the msgpack rules.
+// The cache is bounded by a number of entries and optionally a number of bytes,
+// the least recently used entries are evicted first.
+package memory
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// enforce the Cache to implement the cache.TaggedCache interface.
+var _ cache.TaggedCache = (*Cache)(nil)
+
+// Eviction reasons.
+const (
+	reasonCapacity = "capacity"
+	reasonExpired  = "expired"
+)
+
+// Option defines a Cache option.
+type Option func(*options)
+
+// options provides a set of configurable options for the Cache.
+type options struct {
+	name       string
+	maxEntries int
+	maxBytes   int
+	serializer *cache.Serializer
+}
+
+// entry is a cached value.
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	tags      []string
+}
+
+// expired reports whether the entry expired at the given time.
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// size returns the number of bytes accounted for the entry.
+func (e *entry) size() int {
+	return len(e.key) + len(e.value)
+}
+
+// Cache provides an in-process LRU cache with TTL expiry.
+//
+// Expired entries are removed when they are accessed, or evicted as the least recently used.
+type Cache struct {
+	opts    options
+	metrics *cacheMetrics
+	now     func() time.Time
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+	bytes int
+	// tags indexes the keys by tag.
+	tags map[string]map[string]struct{}
+}
+
+// New creates a new Cache.
+func New(opts ...Option) (*Cache, error) {
+	// default options
+	o := options{
+		name:       "memory",
+		maxEntries: 10000,
+		serializer: cache.DefaultSerializer,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	m, err := newCacheMetrics(o.name)
+	if err != nil {
+		return nil, errors.Wrap(err, "cache metrics")
+	}
+
+	return &Cache{
+		opts:    o,
+		metrics: m,
+		now:     time.Now,
+		items:   map[string]*list.Element{},
+		lru:     list.New(),
+		tags:    map[string]map[string]struct{}{},
+	}, nil
+}
+
+// Len returns the number of entries in the cache, including the expired entries not removed yet.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Flush removes all the entries from the cache.
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = map[string]*list.Element{}
+	c.lru.Init()
+	c.bytes = 0
+	c.tags = map[string]map[string]struct{}{}
+}
+
+func (c *Cache) Get(ctx context.Context, key string, value interface{}) error {
+	if len(key) == 0 {
+		return cache.ErrKeyInvalid
+	}
+
+	b, ok := c.get(ctx, key)
+	if !ok {
+		c.metrics.miss(ctx, 1)
+		return cache.ErrNotFound
+	}
+	c.metrics.hit(ctx, 1)
+
+	return cache.Unmarshal(b, value)
+}
+
+func (c *Cache) MultiGet(ctx context.Context, keys []string, value interface{}) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// Making sure that we are getting the correct interface
+	// we are expecting to get a &[]myType
+	typeOf := reflect.TypeOf(value)
+	if typeOf == nil || typeOf.Kind() != reflect.Ptr {
+		return errors.New("value should be a pointer")
+	}
+
+	valueOf := reflect.ValueOf(value).Elem()
+	if valueOf.Kind() != reflect.Slice {
+		return errors.New("value should be a pointer of slice")
+	}
+
+	values, err := c.MultiGetRaw(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	// type represent the type of the slice
+	typ := typeOf.Elem().Elem()
+	for _, b := range values {
+		if b == nil {
+			continue
+		}
+
+		// creating a new value of the slice type
+		object := reflect.New(typ).Interface()
+		if err := cache.Unmarshal(b, object); err != nil {
+			continue
+		}
+		// Adding to the slice the value.
+		valueOf.Set(reflect.Append(valueOf, reflect.ValueOf(object).Elem()))
+	}
+
+	return nil
+}
+
+func (c *Cache) MultiGetRaw(ctx context.Context, keys []string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values := make([][]byte, len(keys))
+	hits := 0
+	for i, key := range keys {
+		if b, ok := c.get(ctx, key); ok {
+			values[i] = b
+			hits++
+		}
+	}
+	c.metrics.hit(ctx, hits)
+	c.metrics.miss(ctx, len(keys)-hits)
+
+	return values, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	b, err := c.marshal(key, value)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	evicted := c.set(key, b, c.expiresAt(expiration))
+	c.mu.Unlock()
+
+	c.metrics.evict(ctx, evicted, reasonCapacity)
+	return nil
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	if len(key) == 0 {
+		return cache.ErrKeyInvalid
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	return nil
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	b, err := c.marshal(key, value)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	el, expired := c.lookup(key)
+	evicted := 0
+	if el == nil {
+		evicted = c.set(key, b, c.expiresAt(expiration))
+	}
+	c.mu.Unlock()
+
+	c.metrics.evict(ctx, expired, reasonExpired)
+	c.metrics.evict(ctx, evicted, reasonCapacity)
+	return el == nil, nil
+}
+
+func (c *Cache) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	if len(key) == 0 {
+		return 0, cache.ErrKeyInvalid
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, _ := c.lookup(key)
+	var n int64
+	var expiresAt time.Time
+	if el != nil {
+		e := el.Value.(*entry)
+		var err error
+		if n, err = cache.UnmarshalInt(e.value); err != nil {
+			return 0, errors.Wrapf(err, "value of key '%s' is not an integer", key)
+		}
+		expiresAt = e.expiresAt
+	}
+	n += delta
+
+	// counters are plain msgpack whatever the serializer, as in Redis.
+	b, err := cache.Marshal(n)
+	if err != nil {
+		return 0, errors.Wrapf(err, "marshalling value for key '%s'", key)
+	}
+	c.set(key, b, expiresAt)
+	return n, nil
+}
+
+func (c *Cache) Decr(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.Incr(ctx, key, -delta)
+}
+
+func (c *Cache) MultiSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	encoded := make(map[string][]byte, len(values))
+	for key, value := range values {
+		b, err := c.marshal(key, value)
+		if err != nil {
+			return err
+		}
+		encoded[key] = b
+	}
+
+	c.mu.Lock()
+	expiresAt := c.expiresAt(expiration)
+	evicted := 0
+	for key, b := range encoded {
+		evicted += c.set(key, b, expiresAt)
+	}
+	c.mu.Unlock()
+
+	c.metrics.evict(ctx, evicted, reasonCapacity)
+	return nil
+}
+
+func (c *Cache) MultiDelete(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if len(key) == 0 {
+			return cache.ErrKeyInvalid
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+func (c *Cache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	if len(key) == 0 {
+		return false, cache.ErrKeyInvalid
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, _ := c.lookup(key)
+	if el == nil {
+		return false, nil
+	}
+	el.Value.(*entry).expiresAt = c.expiresAt(expiration)
+	return true, nil
+}
+
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if len(key) == 0 {
+		return 0, cache.ErrKeyInvalid
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, _ := c.lookup(key)
+	if el == nil {
+		return 0, cache.ErrNotFound
+	}
+	e := el.Value.(*entry)
+	if e.expiresAt.IsZero() {
+		return 0, nil
+	}
+	return e.expiresAt.Sub(c.now()), nil
+}
+
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old interface{}, new interface{}, expiration time.Duration) (bool, error) {
+	oldBytes, err := c.marshal(key, old)
+	if err != nil {
+		return false, err
+	}
+	newBytes, err := c.marshal(key, new)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	el, _ := c.lookup(key)
+	if el == nil || !bytes.Equal(el.Value.(*entry).value, oldBytes) {
+		c.mu.Unlock()
+		return false, nil
+	}
+	evicted := c.set(key, newBytes, c.expiresAt(expiration))
+	c.mu.Unlock()
+
+	c.metrics.evict(ctx, evicted, reasonCapacity)
+	return true, nil
+}
+
+func (c *Cache) GetSet(ctx context.Context, key string, value interface{}, expiration time.Duration, old interface{}) error {
+	b, err := c.marshal(key, value)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	var prev []byte
+	if el, _ := c.lookup(key); el != nil {
+		prev = el.Value.(*entry).value
+	}
+	evicted := c.set(key, b, c.expiresAt(expiration))
+	c.mu.Unlock()
+
+	c.metrics.evict(ctx, evicted, reasonCapacity)
+	if prev == nil {
+		return cache.ErrNotFound
+	}
+	return cache.Unmarshal(prev, old)
+}
+
+// SetWithTags sets the value of the key, and indexes the key by the tags.
+func (c *Cache) SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error {
+	b, err := c.marshal(key, value)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if len(tag) == 0 {
+			return errors.New("cache tag is empty")
+		}
+	}
+
+	c.mu.Lock()
+	evicted := c.set(key, b, c.expiresAt(expiration), append([]string(nil), tags...)...)
+	c.mu.Unlock()
+
+	c.metrics.evict(ctx, evicted, reasonCapacity)
+	return nil
+}
+
+// InvalidateTags deletes the keys indexed by the tags.
+func (c *Cache) InvalidateTags(ctx context.Context, tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			c.remove(c.items[key])
+		}
+	}
+	return nil
+}
+
+// marshal validates the key and value, and returns the encoded value.
+func (c *Cache) marshal(key string, value interface{}) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, cache.ErrKeyInvalid
+	}
+
+	if value == nil {
+		return nil, cache.ErrValueInvalid
+	}
+
+	b, err := c.opts.serializer.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "marshalling value for key '%s'", key)
+	}
+	return b, nil
+}
+
+// expiresAt returns the expiration time of an entry set now, zero never.
+func (c *Cache) expiresAt(expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return c.now().Add(expiration)
+}
+
+// lookup returns the element of the key, removing it if it expired.
+// It returns the number of expired entries removed. The lock must be held.
+func (c *Cache) lookup(key string) (*list.Element, int) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, 0
+	}
+	if el.Value.(*entry).expired(c.now()) {
+		c.remove(el)
+		return nil, 1
+	}
+	return el, 0
+}
+
+// set sets the entry of the key as the most recently used, and evicts the least recently used entries
+// exceeding the bounds. It returns the number of entries evicted. The lock must be held.
+func (c *Cache) set(key string, value []byte, expiresAt time.Time, tags ...string) int {
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	e := &entry{key: key, value: value, expiresAt: expiresAt, tags: tags}
+	c.items[key] = c.lru.PushFront(e)
+	c.bytes += e.size()
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = map[string]struct{}{}
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	return c.evict()
+}
+
+// get returns the encoded value of the key, and marks it as recently used.
+func (c *Cache) get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	el, expired := c.lookup(key)
+	if el != nil {
+		c.lru.MoveToFront(el)
+	}
+	c.mu.Unlock()
+
+	c.metrics.evict(ctx, expired, reasonExpired)
+	if el == nil {
+		return nil, false
+	}
+	return el.Value.(*entry).value, true
+}
+
+// evict removes the least recently used entries until the cache fits its bounds,
+// and returns the number of entries removed. The lock must be held.
+func (c *Cache) evict() int {
+	evicted := 0
+	for c.lru.Len() > 0 && c.overflows() {
+		c.remove(c.lru.Back())
+		evicted++
+	}
+	return evicted
+}
+
+// overflows reports whether the cache exceeds its bounds. The lock must be held.
+func (c *Cache) overflows() bool {
+	if c.opts.maxEntries > 0 && c.lru.Len() > c.opts.maxEntries {
+		return true
+	}
+	return c.opts.maxBytes > 0 && c.bytes > c.opts.maxBytes
+}
+
+// remove removes the entry from the cache. The lock must be held.
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.items, e.key)
+	c.bytes -= e.size()
+	for _, tag := range e.tags {
+		delete(c.tags[tag], e.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+// cacheMetrics are the OTEL metrics of the cache.
+type cacheMetrics struct {
+	attrs     attribute.Set
+	hits      metric.Int64Counter
+	misses    metric.Int64Counter
+	evictions metric.Int64Counter
+}
+
+func newCacheMetrics(name string) (*cacheMetrics, error) {
+	meter := otel.Meter("kit/cache/memory")
+
+	hits, err := meter.Int64Counter("cache.hits",
+		metric.WithDescription("Number of cache lookups finding a value."))
+	if err != nil {
+		return nil, err
+	}
+	misses, err := meter.Int64Counter("cache.misses",
+		metric.WithDescription("Number of cache lookups not finding a value."))
+	if err != nil {
+		return nil, err
+	}
+	evictions, err := meter.Int64Counter("cache.evictions",
+		metric.WithDescription("Number of cache entries evicted, by reason."))
+	if err != nil {
+		return nil, err
+	}
+
+	return &cacheMetrics{
+		attrs:     attribute.NewSet(attribute.String("cache", name)),
+		hits:      hits,
+		misses:    misses,
+		evictions: evictions,
+	}, nil
+}
+
+func (m *cacheMetrics) hit(ctx context.Context, n int) {
+	if n > 0 {
+		m.hits.Add(ctx, int64(n), metric.WithAttributeSet(m.attrs))
+	}
+}
+
+func (m *cacheMetrics) miss(ctx context.Context, n int) {
+	if n > 0 {
+		m.misses.Add(ctx, int64(n), metric.WithAttributeSet(m.attrs))
+	}
+}
+
+func (m *cacheMetrics) evict(ctx context.Context, n int, reason string) {
+	if n > 0 {
+		m.evictions.Add(ctx, int64(n), metric.WithAttributeSet(m.attrs),
+			metric.WithAttributes(attribute.String("reason", reason)))
+	}
+}
+
+// WithName defines the name of the cache, recorded as the `cache` attribute of its metrics.
+//
+// Name defaults to "memory".
+func WithName(name string) Option {
+	return func(o *options) {
+		if len(name) > 0 {
+			o.name = name
+		}
+	}
+}
+
+// WithMaxEntries defines the maximum number of entries in the cache, 0 means unbounded.
+//
+// MaxEntries defaults to 10000.
+func WithMaxEntries(n int) Option {
+	return func(o *options) {
+		if n >= 0 {
+			o.maxEntries = n
+		}
+	}
+}
+
+// WithMaxBytes defines the maximum size of the keys and encoded values in the cache, 0 means unbounded.
+//
+// MaxBytes defaults to 0.
+func WithMaxBytes(n int) Option {
+	return func(o *options) {
+		if n >= 0 {
+			o.maxBytes = n
+		}
+	}
+}
+
+// WithSerializer defines the serializer encoding the values, such as with another codec or compression.
+//
+// Serializer defaults to cache.DefaultSerializer, encoding with msgpack.
+func WithSerializer(s *cache.Serializer) Option {
+	return func(o *options) {
+		if s != nil {
+			o.serializer = s
+		}
+	}
+}
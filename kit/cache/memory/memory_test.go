@@ -0,0 +1,384 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+type myStruct struct {
+	Value  string
+	Number int
+	Float  float64
+}
+
+func TestSetAndGet(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	mystruct := myStruct{
+		Value:  "This is a string value",
+		Number: 42,
+		Float:  42.42,
+	}
+
+	// When
+	err = c.Set(ctx, "key", mystruct, 0)
+	assert.NoError(t, err)
+
+	mystruct2 := myStruct{}
+	err = c.Get(ctx, "key", &mystruct2)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, mystruct, mystruct2)
+}
+
+func TestGetErrors(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	var v string
+	assert.ErrorIs(t, c.Get(ctx, "", &v), cache.ErrKeyInvalid)
+	assert.ErrorIs(t, c.Get(ctx, "missing", &v), cache.ErrNotFound)
+	assert.ErrorIs(t, c.Set(ctx, "key", nil, 0), cache.ErrValueInvalid)
+}
+
+func TestSetAndMultiGet(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	var keys []string
+	var expected []myStruct
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("key_%d", i)
+		value := myStruct{Value: key, Number: i}
+		assert.NoError(t, c.Set(ctx, key, value, 0))
+		keys = append(keys, key)
+		expected = append(expected, value)
+	}
+
+	// When
+	var values []myStruct
+	err = c.MultiGet(ctx, append(keys, "missing"), &values)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, expected, values)
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Set(ctx, "key", "value", 0))
+	assert.NoError(t, c.Delete(ctx, "key"))
+	assert.NoError(t, c.Delete(ctx, "key"))
+
+	var v string
+	assert.ErrorIs(t, c.Get(ctx, "key", &v), cache.ErrNotFound)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestExpiration(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	assert.NoError(t, c.Set(ctx, "key", "value", time.Minute))
+	assert.NoError(t, c.Set(ctx, "forever", "value", 0))
+
+	// When
+	now = now.Add(time.Minute)
+
+	// Then
+	var v string
+	assert.ErrorIs(t, c.Get(ctx, "key", &v), cache.ErrNotFound)
+	assert.NoError(t, c.Get(ctx, "forever", &v))
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestMaxEntries(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c, err := New(WithMaxEntries(2))
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Set(ctx, "a", "a", 0))
+	assert.NoError(t, c.Set(ctx, "b", "b", 0))
+
+	// a becomes the most recently used.
+	var v string
+	assert.NoError(t, c.Get(ctx, "a", &v))
+
+	// When
+	assert.NoError(t, c.Set(ctx, "c", "c", 0))
+
+	// Then
+	assert.Equal(t, 2, c.Len())
+	assert.NoError(t, c.Get(ctx, "a", &v))
+	assert.NoError(t, c.Get(ctx, "c", &v))
+	assert.ErrorIs(t, c.Get(ctx, "b", &v), cache.ErrNotFound)
+}
+
+func TestMaxBytes(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c, err := New(WithMaxEntries(0), WithMaxBytes(100))
+	assert.NoError(t, err)
+
+	// When
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, c.Set(ctx, fmt.Sprintf("key_%d", i), "0123456789", 0))
+	}
+
+	// Then
+	c.mu.Lock()
+	assert.LessOrEqual(t, c.bytes, 100)
+	c.mu.Unlock()
+	assert.Less(t, c.Len(), 10)
+
+	var v string
+	assert.NoError(t, c.Get(ctx, "key_9", &v))
+	assert.ErrorIs(t, c.Get(ctx, "key_0", &v), cache.ErrNotFound)
+}
+
+func TestValuesAreCopied(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	values := []string{"a", "b"}
+	assert.NoError(t, c.Set(ctx, "key", values, 0))
+	values[0] = "changed"
+
+	var cached []string
+	assert.NoError(t, c.Get(ctx, "key", &cached))
+	assert.Equal(t, []string{"a", "b"}, cached)
+}
+
+func TestSetNX(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	ok, err := c.SetNX(ctx, "key", "a", 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = c.SetNX(ctx, "key", "b", 0)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	var v string
+	assert.NoError(t, c.Get(ctx, "key", &v))
+	assert.Equal(t, "a", v)
+}
+
+func TestIncrAndDecr(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	// When
+	n, err := c.Incr(ctx, "counter", 5)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, n)
+	_, err = c.Expire(ctx, "counter", time.Minute)
+	assert.NoError(t, err)
+	n, err = c.Decr(ctx, "counter", 2)
+
+	// Then
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+
+	var v int
+	assert.NoError(t, c.Get(ctx, "counter", &v))
+	assert.Equal(t, 3, v)
+
+	ttl, err := c.TTL(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	assert.NoError(t, c.Set(ctx, "string", "value", 0))
+	_, err = c.Incr(ctx, "string", 1)
+	assert.Error(t, err)
+}
+
+func TestIncrSerializer(t *testing.T) {
+	// Given a cache encoding the values with JSON
+	ctx := context.TODO()
+	c, err := New(WithSerializer(cache.NewSerializer(cache.WithCodec(cache.JSON))))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Set(ctx, "counter", 41, 0))
+
+	// When
+	n, err := c.Incr(ctx, "counter", 1)
+
+	// Then
+	assert.NoError(t, err)
+	assert.EqualValues(t, 42, n)
+	var v int
+	assert.NoError(t, c.Get(ctx, "counter", &v))
+	assert.Equal(t, 42, v)
+
+	// the encodings Redis cannot increment are rejected.
+	gob, err := New(WithSerializer(cache.NewSerializer(cache.WithCodec(cache.Gob))))
+	assert.NoError(t, err)
+	assert.NoError(t, gob.Set(ctx, "counter", 41, 0))
+	_, err = gob.Incr(ctx, "counter", 1)
+	assert.ErrorIs(t, err, cache.ErrIncrEncoding)
+}
+
+func TestMultiSetAndMultiDelete(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	err = c.MultiSet(ctx, map[string]interface{}{"a": "a", "b": "b", "c": "c"}, 0)
+	assert.NoError(t, err)
+
+	var values []string
+	assert.NoError(t, c.MultiGet(ctx, []string{"a", "b", "c"}, &values))
+	assert.Equal(t, []string{"a", "b", "c"}, values)
+
+	assert.NoError(t, c.MultiDelete(ctx, []string{"a", "c", "missing"}))
+	values = nil
+	assert.NoError(t, c.MultiGet(ctx, []string{"a", "b", "c"}, &values))
+	assert.Equal(t, []string{"b"}, values)
+
+	assert.ErrorIs(t, c.MultiSet(ctx, map[string]interface{}{"": "a"}, 0), cache.ErrKeyInvalid)
+}
+
+func TestExpireAndTTL(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	_, err = c.TTL(ctx, "key")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+	ok, err := c.Expire(ctx, "key", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, c.Set(ctx, "key", "value", time.Minute))
+	now = now.Add(10 * time.Second)
+	ttl, err := c.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 50*time.Second, ttl)
+
+	ok, err = c.Expire(ctx, "key", 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ttl, err = c.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.Zero(t, ttl)
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	swapped, err := c.CompareAndSwap(ctx, "key", "a", "b", 0)
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+
+	assert.NoError(t, c.Set(ctx, "key", "a", 0))
+	swapped, err = c.CompareAndSwap(ctx, "key", "b", "c", 0)
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = c.CompareAndSwap(ctx, "key", "a", "c", 0)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	var v string
+	assert.NoError(t, c.Get(ctx, "key", &v))
+	assert.Equal(t, "c", v)
+}
+
+func TestGetSet(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	var old string
+	assert.ErrorIs(t, c.GetSet(ctx, "key", "a", 0, &old), cache.ErrNotFound)
+	assert.NoError(t, c.GetSet(ctx, "key", "b", 0, &old))
+	assert.Equal(t, "a", old)
+
+	var v string
+	assert.NoError(t, c.Get(ctx, "key", &v))
+	assert.Equal(t, "b", v)
+}
+
+func TestInvalidateTags(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.SetWithTags(ctx, "todo:1", "todo 1", 0, "todo:1"))
+	assert.NoError(t, c.SetWithTags(ctx, "todo:2", "todo 2", 0, "todo:2"))
+	assert.NoError(t, c.SetWithTags(ctx, "todos:page:1", "page 1", 0, "todos", "todo:1", "todo:2"))
+	assert.NoError(t, c.SetWithTags(ctx, "todos:page:2", "page 2", 0, "todos"))
+
+	// When
+	err = c.InvalidateTags(ctx, "todo:1")
+
+	// Then
+	assert.NoError(t, err)
+	var v string
+	assert.ErrorIs(t, c.Get(ctx, "todo:1", &v), cache.ErrNotFound)
+	assert.ErrorIs(t, c.Get(ctx, "todos:page:1", &v), cache.ErrNotFound)
+	assert.NoError(t, c.Get(ctx, "todo:2", &v))
+	assert.NoError(t, c.Get(ctx, "todos:page:2", &v))
+
+	// When
+	assert.NoError(t, c.InvalidateTags(ctx, "todos", "missing"))
+
+	// Then
+	assert.ErrorIs(t, c.Get(ctx, "todos:page:2", &v), cache.ErrNotFound)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestTagsFollowTheKey(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New()
+	assert.NoError(t, err)
+
+	// the key set again without the tag is not invalidated anymore.
+	assert.NoError(t, c.SetWithTags(ctx, "key", "a", 0, "tag"))
+	assert.NoError(t, c.Set(ctx, "key", "b", 0))
+	assert.NoError(t, c.InvalidateTags(ctx, "tag"))
+
+	var v string
+	assert.NoError(t, c.Get(ctx, "key", &v))
+	assert.Equal(t, "b", v)
+
+	assert.Error(t, c.SetWithTags(ctx, "key", "a", 0, ""))
+	c.mu.Lock()
+	assert.Empty(t, c.tags)
+	c.mu.Unlock()
+}
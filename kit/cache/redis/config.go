@@ -0,0 +1,180 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis deployment modes.
+const (
+	ModeSingle   = "single"
+	ModeCluster  = "cluster"
+	ModeSentinel = "sentinel"
+	ModeRing     = "ring"
+)
+
+// Config represents a Redis configuration, it can be loaded with config.From:
+//
+//	redis:
+//	  mode: sentinel
+//	  addrs: ["sentinel-0:26379", "sentinel-1:26379", "sentinel-2:26379"]
+//	  masterName: mymaster
+//	  tls:
+//	    enabled: true
+//	    caFile: /etc/redis/ca.crt
+//
+// The credentials are read from the environment variables REDIS_USERNAME, REDIS_PASSWORD,
+// REDIS_SENTINEL_USERNAME and REDIS_SENTINEL_PASSWORD, and REDIS_ADDRS overrides the addresses.
+type Config struct {
+	// Mode is the deployment mode: single (default), cluster, sentinel or ring.
+	Mode string `yaml:"mode"`
+	// Addrs are the addresses of the node, of the cluster nodes, of the sentinels or of the ring shards.
+	Addrs []string `yaml:"addrs" env:"REDIS_ADDRS,overwrite"`
+	// MasterName is the name of the master monitored by the sentinels.
+	MasterName string `yaml:"masterName"`
+	// DB is the database selected, only supported by the single and sentinel modes.
+	DB int `yaml:"db"`
+
+	Username         string `yaml:"-" env:"REDIS_USERNAME,overwrite"`
+	Password         string `yaml:"-" env:"REDIS_PASSWORD,overwrite"`
+	SentinelUsername string `yaml:"-" env:"REDIS_SENTINEL_USERNAME,overwrite"`
+	SentinelPassword string `yaml:"-" env:"REDIS_SENTINEL_PASSWORD,overwrite"`
+
+	PoolSize     int           `yaml:"poolSize"`
+	DialTimeout  time.Duration `yaml:"dialTimeout"`
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+
+	// ReadOnly routes the read commands to the replicas, in cluster mode.
+	ReadOnly bool `yaml:"readOnly"`
+
+	TLS TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig represents the TLS configuration of the Redis connections.
+type TLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CAFile is the PEM file of the certificate authorities verifying the server, the system ones by default.
+	CAFile string `yaml:"caFile"`
+	// CertFile and KeyFile are the PEM files of the client certificate, for mutual TLS.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ServerName overrides the name verified in the server certificate.
+	ServerName         string `yaml:"serverName"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+// New creates the Cache defined by the configuration mode, with the given options.
+func (c *Config) New(opts ...Option) (*Cache, error) {
+	if len(c.Addrs) == 0 {
+		return nil, errors.New("redis addrs missing")
+	}
+
+	tlsConfig, err := c.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Mode {
+	case "", ModeSingle:
+		return New(&redis.Options{
+			Addr:         c.Addrs[0],
+			DB:           c.DB,
+			Username:     c.Username,
+			Password:     c.Password,
+			PoolSize:     c.PoolSize,
+			DialTimeout:  c.DialTimeout,
+			ReadTimeout:  c.ReadTimeout,
+			WriteTimeout: c.WriteTimeout,
+			TLSConfig:    tlsConfig,
+		}, opts...)
+	case ModeCluster:
+		return NewFromClient(redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        c.Addrs,
+			Username:     c.Username,
+			Password:     c.Password,
+			PoolSize:     c.PoolSize,
+			DialTimeout:  c.DialTimeout,
+			ReadTimeout:  c.ReadTimeout,
+			WriteTimeout: c.WriteTimeout,
+			ReadOnly:     c.ReadOnly,
+			TLSConfig:    tlsConfig,
+		}), opts...)
+	case ModeSentinel:
+		if len(c.MasterName) == 0 {
+			return nil, errors.New("redis sentinel master name missing")
+		}
+		return NewFromClient(redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       c.MasterName,
+			SentinelAddrs:    c.Addrs,
+			DB:               c.DB,
+			Username:         c.Username,
+			Password:         c.Password,
+			SentinelUsername: c.SentinelUsername,
+			SentinelPassword: c.SentinelPassword,
+			PoolSize:         c.PoolSize,
+			DialTimeout:      c.DialTimeout,
+			ReadTimeout:      c.ReadTimeout,
+			WriteTimeout:     c.WriteTimeout,
+			TLSConfig:        tlsConfig,
+		}), opts...)
+	case ModeRing:
+		shards := make(map[string]string, len(c.Addrs))
+		for _, addr := range c.Addrs {
+			shards[addr] = addr
+		}
+		return NewRing(&redis.RingOptions{
+			Addrs:        shards,
+			DB:           c.DB,
+			Username:     c.Username,
+			Password:     c.Password,
+			PoolSize:     c.PoolSize,
+			DialTimeout:  c.DialTimeout,
+			ReadTimeout:  c.ReadTimeout,
+			WriteTimeout: c.WriteTimeout,
+			TLSConfig:    tlsConfig,
+		}, opts...)
+	}
+
+	return nil, errors.Newf("unknown redis mode '%s'", c.Mode)
+}
+
+// config returns the TLS configuration, nil if TLS is disabled.
+func (c *TLSConfig) config() (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+
+	conf := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if len(c.CAFile) > 0 {
+		b, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading redis ca file '%s'", c.CAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Newf("no certificate found in redis ca file '%s'", c.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading redis client certificate")
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
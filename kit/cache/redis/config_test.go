@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFrom(t *testing.T) {
+	t.Setenv("REDIS_PASSWORD", "secret")
+
+	rawConf := strings.NewReader(`
+mode: sentinel
+addrs: ["sentinel-0:26379", "sentinel-1:26379"]
+masterName: mymaster
+tls:
+  enabled: true
+  serverName: redis.local
+`)
+	c := Config{}
+	err := config.From(rawConf, &c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ModeSentinel, c.Mode)
+	assert.Equal(t, []string{"sentinel-0:26379", "sentinel-1:26379"}, c.Addrs)
+	assert.Equal(t, "mymaster", c.MasterName)
+	assert.Equal(t, "secret", c.Password)
+	assert.True(t, c.TLS.Enabled)
+}
+
+func TestConfigNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		client redis.UniversalClient
+	}{
+		{name: "single", config: Config{Addrs: []string{"localhost:6379"}}, client: &redis.Client{}},
+		{name: "cluster", config: Config{Mode: ModeCluster, Addrs: []string{"node-0:6379", "node-1:6379"}}, client: &redis.ClusterClient{}},
+		{name: "sentinel", config: Config{Mode: ModeSentinel, Addrs: []string{"sentinel-0:26379"}, MasterName: "mymaster"}, client: &redis.Client{}},
+		{name: "ring", config: Config{Mode: ModeRing, Addrs: []string{"shard-0:6379", "shard-1:6379"}}, client: &redis.Ring{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serializer := cache.NewSerializer(cache.WithCodec(cache.JSON))
+			c, err := tt.config.New(WithSerializer(serializer))
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer c.Close()
+			assert.IsType(t, tt.client, c.Client())
+			assert.Same(t, serializer, c.opts.serializer)
+		})
+	}
+}
+
+func TestConfigNewErrors(t *testing.T) {
+	_, err := (&Config{}).New()
+	assert.Error(t, err)
+
+	_, err = (&Config{Mode: "unknown", Addrs: []string{"localhost:6379"}}).New()
+	assert.Error(t, err)
+
+	_, err = (&Config{Mode: ModeSentinel, Addrs: []string{"sentinel-0:26379"}}).New()
+	assert.Error(t, err)
+
+	_, err = (&Config{Addrs: []string{"localhost:6379"}, TLS: TLSConfig{Enabled: true, CAFile: "missing.crt"}}).New()
+	assert.Error(t, err)
+}
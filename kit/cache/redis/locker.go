@@ -0,0 +1,131 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/lock"
+	"github.com/redis/go-redis/v9"
+)
+
+// enforce the Locker to implement the lock.ScopedLocker interface.
+var _ lock.ScopedLocker = (*Locker)(nil)
+
+// lockScript acquires the lock with a new fencing token as value.
+// The counter is incremented even when the lock is not acquired, tokens only need to increase.
+var lockScript = redis.NewScript(`
+local token = redis.call("INCR", KEYS[2])
+if redis.call("SET", KEYS[1], token, "NX", "PX", ARGV[1]) then
+	return token
+end
+return 0
+`)
+
+// extendScript renews the lock expiration only if it is still held by the caller.
+var extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// unlockScript deletes the lock only if it is still held by the caller.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Locker provides lease based distributed locks on Redis.
+//
+// A lock is a key set with NX and a PX expiration. Its value is a fencing token drawn from a counter
+// incremented on each acquisition attempt, so the lock can only be extended or released by its holder,
+// and a former holder whose lease expired is detected by the protected resource.
+// The counter never expires, so the tokens keep increasing: it is a small key kept per lock key.
+//
+// The lock and counter keys share a hash tag, locks are supported by Redis Cluster.
+// The scoped locks of a key share its counter.
+type Locker struct {
+	client redis.UniversalClient
+}
+
+// NewLocker creates a new Locker sharing the connection of the given cache.
+func NewLocker(c *Cache) (*Locker, error) {
+	if c == nil || c.client == nil {
+		return nil, errors.New("no redis client")
+	}
+	return &Locker{client: c.client}, nil
+}
+
+// TryLock acquires the lock identified by the key for the ttl duration, without waiting.
+func (l *Locker) TryLock(ctx context.Context, key string, ttl time.Duration) (lock.Lock, error) {
+	return l.tryLock(ctx, key, "", ttl)
+}
+
+// TryLockScoped acquires the lock identified by the key and the scope for the ttl duration, without waiting.
+func (l *Locker) TryLockScoped(ctx context.Context, key, scope string, ttl time.Duration) (lock.Lock, error) {
+	return l.tryLock(ctx, key, ":scope:"+scope, ttl)
+}
+
+// tryLock acquires the lock of the key, suffixed by the scope, with a token drawn from the counter of the key.
+func (l *Locker) tryLock(ctx context.Context, key, scope string, ttl time.Duration) (lock.Lock, error) {
+	if len(key) == 0 {
+		return nil, errors.New("lock key is empty")
+	}
+	if ttl < time.Millisecond {
+		return nil, errors.New("lock ttl must be at least 1ms")
+	}
+
+	lockKey := "lock:{" + key + "}"
+	fenceKey := lockKey + ":fence"
+	lockKey += scope
+
+	token, err := lockScript.Run(ctx, l.client, []string{lockKey, fenceKey}, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, errors.Wrapf(err, "acquiring lock '%s'", key)
+	}
+	if token == 0 {
+		return nil, lock.ErrNotAcquired
+	}
+
+	return &redisLock{
+		client: l.client,
+		key:    lockKey,
+		token:  token,
+	}, nil
+}
+
+// redisLock is a lock acquired by the Locker.
+type redisLock struct {
+	client redis.UniversalClient
+	key    string
+	token  int64
+}
+
+// Token returns the fencing token of the lock.
+func (l *redisLock) Token() int64 {
+	return l.token
+}
+
+// Extend renews the lease of the lock.
+func (l *redisLock) Extend(ctx context.Context, ttl time.Duration) error {
+	ok, err := extendScript.Run(ctx, l.client, []string{l.key}, strconv.FormatInt(l.token, 10), ttl.Milliseconds()).Int64()
+	if err != nil {
+		return errors.Wrapf(err, "extending lock '%s'", l.key)
+	}
+	if ok == 0 {
+		return lock.ErrNotHeld
+	}
+	return nil
+}
+
+// Unlock releases the lock if it is still held.
+func (l *redisLock) Unlock(ctx context.Context) error {
+	if err := unlockScript.Run(ctx, l.client, []string{l.key}, strconv.FormatInt(l.token, 10)).Err(); err != nil {
+		return errors.Wrapf(err, "releasing lock '%s'", l.key)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/id"
+	"github.com/mukhtarkv/workspace/kit/lock"
+	"github.com/stretchr/testify/assert"
+)
+
+func (r *redisTestSuite) TestLockerTryLock() {
+	// Given
+	ctx := context.TODO()
+	locker, err := NewLocker(r.cache)
+	assert.NoError(r.T(), err)
+	key := fmt.Sprintf("key_%s", id.New())
+
+	// When
+	l, err := locker.TryLock(ctx, key, time.Minute)
+	assert.NoError(r.T(), err)
+	_, err = locker.TryLock(ctx, key, time.Minute)
+
+	// Then
+	assert.ErrorIs(r.T(), err, lock.ErrNotAcquired)
+
+	// Once released, the lock is acquired again with a greater token.
+	assert.NoError(r.T(), l.Unlock(ctx))
+	l2, err := locker.TryLock(ctx, key, time.Minute)
+	assert.NoError(r.T(), err)
+	defer l2.Unlock(ctx)
+	assert.Greater(r.T(), l2.Token(), l.Token())
+
+	// the fencing counter never expires, so the tokens keep increasing.
+	fenceKey := "lock:{" + key + "}:fence"
+	defer r.cache.client.Del(ctx, fenceKey)
+	assert.Equal(r.T(), time.Duration(-1), r.cache.client.PTTL(ctx, fenceKey).Val())
+}
+
+func (r *redisTestSuite) TestLockerExtend() {
+	// Given
+	ctx := context.TODO()
+	locker, err := NewLocker(r.cache)
+	assert.NoError(r.T(), err)
+	key := fmt.Sprintf("key_%s", id.New())
+
+	l, err := locker.TryLock(ctx, key, 100*time.Millisecond)
+	assert.NoError(r.T(), err)
+	defer l.Unlock(ctx)
+
+	// When
+	err = l.Extend(ctx, time.Minute)
+	assert.NoError(r.T(), err)
+	time.Sleep(200 * time.Millisecond)
+
+	// Then
+	_, err = locker.TryLock(ctx, key, time.Minute)
+	assert.ErrorIs(r.T(), err, lock.ErrNotAcquired)
+}
+
+func (r *redisTestSuite) TestLockerExpiredHolder() {
+	// Given
+	ctx := context.TODO()
+	locker, err := NewLocker(r.cache)
+	assert.NoError(r.T(), err)
+	key := fmt.Sprintf("key_%s", id.New())
+
+	stale, err := locker.TryLock(ctx, key, 50*time.Millisecond)
+	assert.NoError(r.T(), err)
+	time.Sleep(100 * time.Millisecond)
+
+	l, err := locker.TryLock(ctx, key, time.Minute)
+	assert.NoError(r.T(), err)
+	defer l.Unlock(ctx)
+
+	// When
+	extendErr := stale.Extend(ctx, time.Minute)
+	unlockErr := stale.Unlock(ctx)
+
+	// Then
+	assert.ErrorIs(r.T(), extendErr, lock.ErrNotHeld)
+	assert.NoError(r.T(), unlockErr)
+	// the stale holder didn't release the lock of the new holder.
+	_, err = locker.TryLock(ctx, key, time.Minute)
+	assert.ErrorIs(r.T(), err, lock.ErrNotAcquired)
+	assert.NoError(r.T(), l.Extend(ctx, time.Minute))
+}
+
+func (r *redisTestSuite) TestLockerTryLockScoped() {
+	// Given
+	ctx := context.TODO()
+	locker, err := NewLocker(r.cache)
+	assert.NoError(r.T(), err)
+	key := fmt.Sprintf("key_%s", id.New())
+	defer r.cache.client.Del(ctx, "lock:{"+key+"}:fence")
+
+	// When
+	l1, err := locker.TryLockScoped(ctx, key, "1", time.Minute)
+	assert.NoError(r.T(), err)
+	defer l1.Unlock(ctx)
+	_, err = locker.TryLockScoped(ctx, key, "1", time.Minute)
+	assert.ErrorIs(r.T(), err, lock.ErrNotAcquired)
+	l2, err := locker.TryLockScoped(ctx, key, "2", time.Minute)
+
+	// Then the scopes are locked independently, with tokens drawn from the same counter
+	assert.NoError(r.T(), err)
+	defer l2.Unlock(ctx)
+	assert.Greater(r.T(), l2.Token(), l1.Token())
+}
@@ -0,0 +1,456 @@
+package redis
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/cache/memory"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/id"
+	"github.com/mukhtarkv/workspace/kit/log"
+	"github.com/redis/go-redis/v9"
+)
+
+// enforce the NearCache to implement the cache.TaggedCache interface.
+var _ cache.TaggedCache = (*NearCache)(nil)
+
+// NearOption defines a NearCache option.
+type NearOption func(*nearOptions)
+
+// nearOptions provides a set of configurable options for the NearCache.
+type nearOptions struct {
+	channel      string
+	localTTL     time.Duration
+	localOptions []memory.Option
+	logger       *log.Logger
+}
+
+// NearCache is a two tiers cache, keeping the hot keys of Redis in an in-process memory.Cache.
+//
+// Values are read from the local tier first, then from Redis, and kept locally for the local TTL.
+// Writes go to Redis, then the keys are broadcast on a Redis pub/sub channel, so the other
+// replicas drop them from their local tier. Invalidations missed while disconnected from Redis
+// are handled by flushing the local tier on reconnection, the local TTL bounds the staleness
+// of a value in every other case.
+//
+// Values read from Redis are kept locally no longer than their remaining TTL in Redis.
+// A value read while its key is invalidated is not kept locally, so a slow read
+// doesn't bring back a stale value after the invalidation.
+type NearCache struct {
+	remote *Cache
+	local  *memory.Cache
+	opts   nearOptions
+	origin string
+
+	// versions are incremented on each invalidation of the keys hashed to them,
+	// a value read from Redis is only kept locally if the version of its key didn't change.
+	versions [256]atomic.Uint64
+
+	pubsub *redis.PubSub
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewNearCache creates a new NearCache in front of the given Redis cache,
+// and starts listening to the invalidations of the other replicas.
+func NewNearCache(remote *Cache, opts ...NearOption) (*NearCache, error) {
+	if remote == nil || remote.client == nil {
+		return nil, errors.New("no redis client")
+	}
+
+	// default options
+	o := nearOptions{
+		channel:  "cache:invalidations",
+		localTTL: time.Minute,
+		logger:   log.L(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	local, err := memory.New(append([]memory.Option{memory.WithName("near")}, o.localOptions...)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "local cache")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &NearCache{
+		remote: remote,
+		local:  local,
+		opts:   o,
+		origin: id.New(),
+		pubsub: remote.client.Subscribe(ctx, o.channel),
+		cancel: cancel,
+	}
+
+	// wait for the subscription, so the writes following the creation are seen.
+	if _, err := c.pubsub.Receive(ctx); err != nil {
+		cancel()
+		_ = c.pubsub.Close() //nolint
+		return nil, errors.Wrapf(err, "subscribing to '%s'", o.channel)
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.listen(ctx)
+	}()
+
+	return c, nil
+}
+
+// Close stops listening to the invalidations, the Redis cache is left open.
+func (c *NearCache) Close() error {
+	c.cancel()
+	err := c.pubsub.Close()
+	c.wg.Wait()
+	c.local.Flush()
+	return err
+}
+
+func (c *NearCache) Get(ctx context.Context, key string, value interface{}) error {
+	b, err := c.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return cache.Unmarshal(b, value)
+}
+
+func (c *NearCache) MultiGet(ctx context.Context, keys []string, value interface{}) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	valueOf, err := sliceValue(value)
+	if err != nil {
+		return err
+	}
+
+	values, err := c.MultiGetRaw(ctx, keys)
+	if err != nil {
+		return err
+	}
+	appendValues(valueOf, values)
+	return nil
+}
+
+func (c *NearCache) MultiGetRaw(ctx context.Context, keys []string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values := make([][]byte, len(keys))
+	var missing []string
+	var missingIdx []int
+	for i, key := range keys {
+		if err := c.local.Get(ctx, key, &values[i]); err != nil {
+			missing = append(missing, key)
+			missingIdx = append(missingIdx, i)
+		}
+	}
+
+	if len(missing) > 0 {
+		versions := make([]uint64, len(missing))
+		for i, key := range missing {
+			versions[i] = c.version(key).Load()
+		}
+
+		gets := make([]*redis.StringCmd, len(missing))
+		ttls := make([]*redis.DurationCmd, len(missing))
+		_, err := c.remote.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range missing {
+				gets[i] = pipe.Get(ctx, key)
+				ttls[i] = pipe.PTTL(ctx, key)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, errors.Wrapf(err, "getting values of keys %+v", missing)
+		}
+		for i, key := range missing {
+			b, err := gets[i].Bytes()
+			if err != nil {
+				continue
+			}
+			values[missingIdx[i]] = b
+			c.fill(ctx, key, b, ttls[i].Val(), versions[i])
+		}
+	}
+
+	return values, nil
+}
+
+func (c *NearCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if len(key) == 0 {
+		return cache.ErrKeyInvalid
+	}
+
+	if value == nil {
+		return cache.ErrValueInvalid
+	}
+
+	b, err := c.remote.opts.serializer.Marshal(value)
+	if err != nil {
+		return errors.Wrapf(err, "marshalling value for key '%s'", key)
+	}
+
+	if err := c.remote.client.Set(ctx, key, b, expiration).Err(); err != nil {
+		return errors.Wrapf(err, "saving value to cache for key '%s'", key)
+	}
+
+	if err := c.invalidate(ctx, key); err != nil {
+		return err
+	}
+
+	localTTL := c.opts.localTTL
+	if expiration > 0 && expiration < localTTL {
+		localTTL = expiration
+	}
+	_ = c.local.Set(ctx, key, b, localTTL) //nolint
+	return nil
+}
+
+func (c *NearCache) Delete(ctx context.Context, key string) error {
+	if err := c.remote.Delete(ctx, key); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *NearCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	ok, err := c.remote.SetNX(ctx, key, value, expiration)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return ok, c.invalidate(ctx, key)
+}
+
+func (c *NearCache) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	n, err := c.remote.Incr(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	return n, c.invalidate(ctx, key)
+}
+
+func (c *NearCache) Decr(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.Incr(ctx, key, -delta)
+}
+
+func (c *NearCache) MultiSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	if err := c.remote.MultiSet(ctx, values, expiration); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return c.invalidate(ctx, keys...)
+}
+
+func (c *NearCache) MultiDelete(ctx context.Context, keys []string) error {
+	if err := c.remote.MultiDelete(ctx, keys); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, keys...)
+}
+
+func (c *NearCache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	ok, err := c.remote.Expire(ctx, key, expiration)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return ok, c.invalidate(ctx, key)
+}
+
+func (c *NearCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.remote.TTL(ctx, key)
+}
+
+func (c *NearCache) CompareAndSwap(ctx context.Context, key string, old interface{}, new interface{}, expiration time.Duration) (bool, error) {
+	swapped, err := c.remote.CompareAndSwap(ctx, key, old, new, expiration)
+	if err != nil || !swapped {
+		return swapped, err
+	}
+	return swapped, c.invalidate(ctx, key)
+}
+
+func (c *NearCache) GetSet(ctx context.Context, key string, value interface{}, expiration time.Duration, old interface{}) error {
+	err := c.remote.GetSet(ctx, key, value, expiration, old)
+	// the value may have been set even if the old value cannot be unmarshalled.
+	if invalidateErr := c.invalidate(ctx, key); invalidateErr != nil {
+		return invalidateErr
+	}
+	return err
+}
+
+func (c *NearCache) SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error {
+	if err := c.remote.SetWithTags(ctx, key, value, expiration, tags...); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *NearCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	keys, err := c.remote.invalidateTags(ctx, tags)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	return c.invalidate(ctx, keys...)
+}
+
+// get returns the encoded value of the key, from the local tier or from Redis.
+func (c *NearCache) get(ctx context.Context, key string) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, cache.ErrKeyInvalid
+	}
+
+	var b []byte
+	if err := c.local.Get(ctx, key, &b); err == nil {
+		return b, nil
+	}
+
+	version := c.version(key).Load()
+	var (
+		get *redis.StringCmd
+		ttl *redis.DurationCmd
+	)
+	_, err := c.remote.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		ttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil {
+		// If key doesn't exist or the cache expired.
+		if errors.Is(err, redis.Nil) {
+			return nil, cache.ErrNotFound
+		}
+		return nil, errors.Wrapf(err, "unmarshal value of key '%s'", key)
+	}
+
+	b, _ = get.Bytes()
+	c.fill(ctx, key, b, ttl.Val(), version)
+	return b, nil
+}
+
+// fill keeps the value read from Redis in the local tier, for the local TTL or its remaining TTL
+// in Redis if shorter, unless the key was invalidated since the version was loaded.
+func (c *NearCache) fill(ctx context.Context, key string, b []byte, remaining time.Duration, version uint64) {
+	localTTL := c.opts.localTTL
+	// PTTL is negative for the keys without expiration.
+	if remaining > 0 && remaining < localTTL {
+		localTTL = remaining
+	}
+
+	v := c.version(key)
+	_ = c.local.Set(ctx, key, b, localTTL) //nolint
+	// an invalidation may have raced the read, or the local set: the value may be stale.
+	if v.Load() != version {
+		_ = c.local.Delete(ctx, key) //nolint
+	}
+}
+
+// version returns the invalidation version of the key.
+func (c *NearCache) version(key string) *atomic.Uint64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key)) //nolint
+	return &c.versions[h.Sum32()%uint32(len(c.versions))]
+}
+
+// drop drops the key from the local tier, and stops the reads in flight from keeping it.
+func (c *NearCache) drop(ctx context.Context, key string) {
+	c.version(key).Add(1)
+	_ = c.local.Delete(ctx, key) //nolint
+}
+
+// flush drops all the keys from the local tier, and stops the reads in flight from keeping them.
+func (c *NearCache) flush() {
+	for i := range c.versions {
+		c.versions[i].Add(1)
+	}
+	c.local.Flush()
+}
+
+// invalidate drops the keys from the local tier, and broadcasts them to the other replicas.
+func (c *NearCache) invalidate(ctx context.Context, keys ...string) error {
+	_, err := c.remote.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			c.drop(ctx, key)
+			pipe.Publish(ctx, c.opts.channel, c.origin+":"+key)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "publishing invalidation of keys %+v", keys)
+	}
+	return nil
+}
+
+// listen drops the keys invalidated by the other replicas from the local tier,
+// and flushes it when the subscription is restored after a disconnection.
+func (c *NearCache) listen(ctx context.Context) {
+	for msg := range c.pubsub.ChannelWithSubscriptions() {
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind == "subscribe" {
+				c.opts.logger.Warn(ctx, "near cache resubscribed, flushing local cache", log.String("channel", msg.Channel))
+				c.flush()
+			}
+		case *redis.Message:
+			origin, key, ok := strings.Cut(msg.Payload, ":")
+			if !ok || origin == c.origin {
+				continue
+			}
+			c.drop(ctx, key)
+		}
+	}
+}
+
+// WithInvalidationChannel defines the Redis pub/sub channel the invalidations are broadcast on.
+// Caches sharing the same Redis keys must share the same channel.
+//
+// Channel defaults to "cache:invalidations".
+func WithInvalidationChannel(channel string) NearOption {
+	return func(o *nearOptions) {
+		if len(channel) > 0 {
+			o.channel = channel
+		}
+	}
+}
+
+// WithLocalTTL defines how long a value is kept in the local tier, bounding its staleness
+// when an invalidation is missed. Values expiring earlier in Redis expire earlier locally.
+//
+// LocalTTL defaults to 1 minute.
+func WithLocalTTL(ttl time.Duration) NearOption {
+	return func(o *nearOptions) {
+		if ttl > 0 {
+			o.localTTL = ttl
+		}
+	}
+}
+
+// WithLocalOptions defines the options of the local tier, such as its memory bounds:
+//
+//	redis.WithLocalOptions(memory.WithMaxEntries(1000), memory.WithMaxBytes(64<<20))
+func WithLocalOptions(opts ...memory.Option) NearOption {
+	return func(o *nearOptions) {
+		o.localOptions = append(o.localOptions, opts...)
+	}
+}
+
+// WithNearLogger defines the logger used by the near cache.
+//
+// Logger defaults to the global logger.
+func WithNearLogger(l *log.Logger) NearOption {
+	return func(o *nearOptions) {
+		if l != nil {
+			o.logger = l
+		}
+	}
+}
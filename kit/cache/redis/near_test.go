@@ -0,0 +1,143 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/id"
+	"github.com/mukhtarkv/workspace/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func (r *redisTestSuite) newNearCaches() (*NearCache, *NearCache) {
+	channel := fmt.Sprintf("invalidations_%s", id.New())
+	a, err := NewNearCache(r.cache, WithInvalidationChannel(channel), WithNearLogger(log.NewNop()))
+	if err != nil {
+		r.T().Fatalf("setting up near cache %v", err)
+	}
+	b, err := NewNearCache(r.cache, WithInvalidationChannel(channel), WithNearLogger(log.NewNop()))
+	if err != nil {
+		r.T().Fatalf("setting up near cache %v", err)
+	}
+	return a, b
+}
+
+func (r *redisTestSuite) TestNearCacheSetAndGet() {
+	// Given
+	ctx := context.TODO()
+	a, b := r.newNearCaches()
+	defer a.Close()
+	defer b.Close()
+
+	key := fmt.Sprintf("key_%s", id.New())
+	defer r.cache.Delete(ctx, key)
+
+	// When
+	err := a.Set(ctx, key, "value", time.Minute)
+	assert.NoError(r.T(), err)
+
+	// Then
+	var v string
+	assert.NoError(r.T(), b.Get(ctx, key, &v))
+	assert.Equal(r.T(), "value", v)
+	assert.Equal(r.T(), 1, b.local.Len())
+}
+
+func (r *redisTestSuite) TestNearCacheInvalidation() {
+	// Given
+	ctx := context.TODO()
+	a, b := r.newNearCaches()
+	defer a.Close()
+	defer b.Close()
+
+	key := fmt.Sprintf("key_%s", id.New())
+	defer r.cache.Delete(ctx, key)
+
+	assert.NoError(r.T(), a.Set(ctx, key, "value", time.Minute))
+	var v string
+	assert.NoError(r.T(), b.Get(ctx, key, &v))
+
+	// When
+	assert.NoError(r.T(), a.Set(ctx, key, "updated", time.Minute))
+
+	// Then
+	assert.Eventually(r.T(), func() bool {
+		return b.Get(ctx, key, &v) == nil && v == "updated"
+	}, time.Second, 10*time.Millisecond)
+
+	// When
+	assert.NoError(r.T(), a.Delete(ctx, key))
+
+	// Then
+	assert.Eventually(r.T(), func() bool {
+		return b.Get(ctx, key, &v) == cache.ErrNotFound
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (r *redisTestSuite) TestNearCacheMultiGet() {
+	// Given
+	ctx := context.TODO()
+	a, b := r.newNearCaches()
+	defer a.Close()
+	defer b.Close()
+
+	keys := []string{fmt.Sprintf("key_%s", id.New()), fmt.Sprintf("key_%s", id.New()), fmt.Sprintf("key_%s", id.New())}
+	for _, key := range keys {
+		defer r.cache.Delete(ctx, key)
+	}
+	assert.NoError(r.T(), a.Set(ctx, keys[0], "a", time.Minute))
+	assert.NoError(r.T(), r.cache.Set(ctx, keys[2], "c", time.Minute))
+
+	// When
+	var values []string
+	err := a.MultiGet(ctx, keys, &values)
+
+	// Then
+	assert.NoError(r.T(), err)
+	assert.Equal(r.T(), []string{"a", "c"}, values)
+	assert.Equal(r.T(), 2, a.local.Len())
+}
+
+func (r *redisTestSuite) TestNearCacheRemainingTTL() {
+	// Given a value expiring in Redis before the local TTL
+	ctx := context.TODO()
+	a, b := r.newNearCaches()
+	defer a.Close()
+	defer b.Close()
+
+	key := fmt.Sprintf("key_%s", id.New())
+	defer r.cache.Delete(ctx, key)
+	assert.NoError(r.T(), r.cache.Set(ctx, key, "value", 200*time.Millisecond))
+
+	// When it is read
+	var v string
+	assert.NoError(r.T(), b.Get(ctx, key, &v))
+	var values []string
+	assert.NoError(r.T(), a.MultiGet(ctx, []string{key}, &values))
+
+	// Then it expires locally with its remaining TTL
+	assert.Equal(r.T(), 1, a.local.Len())
+	time.Sleep(300 * time.Millisecond)
+	var raw []byte
+	assert.Error(r.T(), a.local.Get(ctx, key, &raw))
+	assert.Error(r.T(), b.local.Get(ctx, key, &raw))
+}
+
+func (r *redisTestSuite) TestNearCacheStaleFill() {
+	// Given a read of a key in flight
+	ctx := context.TODO()
+	a, _ := r.newNearCaches()
+	defer a.Close()
+
+	key := fmt.Sprintf("key_%s", id.New())
+	version := a.version(key).Load()
+
+	// When the key is invalidated before the read value is kept
+	a.drop(ctx, key)
+	a.fill(ctx, key, []byte("stale"), time.Minute, version)
+
+	// Then the stale value is not kept locally
+	assert.Equal(r.T(), 0, a.local.Len())
+}
@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+// enforce the RateLimiter to implement the ratelimit.Limiter interface.
+var _ ratelimit.Limiter = (*RateLimiter)(nil)
+
+// The rate limit scripts read the time of the Redis server, so the replicas of a service share the same clock.
+// They return whether the request is allowed, the remaining requests and the milliseconds to wait before retrying.
+
+// tokenBucketScript consumes a token of the bucket stored in the hash KEYS[1],
+// ARGV being the rate, the period in milliseconds and the burst.
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + tonumber(time[2]) / 1000
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local interval = period / rate
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1]) or burst
+local last = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) / interval)
+
+if tokens < 1 then
+	return {0, 0, math.ceil((1 - tokens) * interval)}
+end
+tokens = tokens - 1
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * interval))
+return {1, math.floor(tokens), 0}
+`)
+
+// slidingWindowScript counts a request in the sliding window stored in the hash KEYS[1],
+// ARGV being the rate and the period in milliseconds.
+var slidingWindowScript = redis.NewScript(`
+redis.replicate_commands()
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local start = math.floor(now / period)
+local elapsed = (now % period) / period
+
+local state = redis.call("HMGET", KEYS[1], "start", "prev", "curr")
+local prev = tonumber(state[2]) or 0
+local curr = tonumber(state[3]) or 0
+local last = tonumber(state[1]) or start
+if last == start - 1 then
+	prev, curr = curr, 0
+elseif last < start - 1 then
+	prev, curr = 0, 0
+end
+
+local count = prev * (1 - elapsed) + curr
+if count + 1 > rate then
+	local wait
+	if curr + 1 > rate then
+		wait = 1 - elapsed + 1 - (rate - 1) / curr
+	else
+		wait = 1 - (rate - curr - 1) / prev - elapsed
+	end
+	return {0, 0, math.ceil(wait * period)}
+end
+
+redis.call("HSET", KEYS[1], "start", start, "prev", prev, "curr", curr + 1)
+redis.call("PEXPIRE", KEYS[1], 2 * period)
+return {1, math.floor(rate - count - 1), 0}
+`)
+
+// RateLimiter is a distributed ratelimit.Limiter on Redis, sharing the limits between the replicas.
+//
+// The state of each key is a hash updated by a Lua script, rate limiters are supported by Redis Cluster.
+type RateLimiter struct {
+	client redis.UniversalClient
+	limit  ratelimit.Limit
+	script *redis.Script
+	args   []interface{}
+}
+
+// NewTokenBucketLimiter creates a new token bucket RateLimiter, sharing the connection of the given cache.
+// See ratelimit.NewTokenBucket for the local version.
+func NewTokenBucketLimiter(c *Cache, limit ratelimit.Limit) (*RateLimiter, error) {
+	if err := limit.Validate(); err != nil {
+		return nil, err
+	}
+	return newRateLimiter(c, limit, tokenBucketScript, limit.Rate, limit.Period.Milliseconds(), limit.Burst)
+}
+
+// NewSlidingWindowLimiter creates a new sliding window RateLimiter, sharing the connection of the given cache.
+// See ratelimit.NewSlidingWindow for the local version.
+func NewSlidingWindowLimiter(c *Cache, limit ratelimit.Limit) (*RateLimiter, error) {
+	if err := limit.Validate(); err != nil {
+		return nil, err
+	}
+	return newRateLimiter(c, limit, slidingWindowScript, limit.Rate, limit.Period.Milliseconds())
+}
+
+func newRateLimiter(c *Cache, limit ratelimit.Limit, script *redis.Script, args ...interface{}) (*RateLimiter, error) {
+	if c == nil || c.client == nil {
+		return nil, errors.New("no redis client")
+	}
+	if limit.Period < time.Millisecond {
+		return nil, errors.New("rate limit period must be at least 1ms")
+	}
+	return &RateLimiter{
+		client: c.client,
+		limit:  limit,
+		script: script,
+		args:   args,
+	}, nil
+}
+
+// Allow reports whether one more request of the key is allowed, and consumes it if so.
+func (l *RateLimiter) Allow(ctx context.Context, key string) (ratelimit.Result, error) {
+	res, err := l.script.Run(ctx, l.client, []string{"ratelimit:" + key}, l.args...).Int64Slice()
+	if err != nil {
+		return ratelimit.Result{}, errors.Wrapf(err, "checking rate limit of '%s'", key)
+	}
+	if len(res) != 3 {
+		return ratelimit.Result{}, errors.Newf("unexpected rate limit result %v", res)
+	}
+	return ratelimit.Result{
+		Allowed:    res[0] == 1,
+		Limit:      l.limit.Rate,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
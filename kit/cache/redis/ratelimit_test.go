@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/id"
+	"github.com/mukhtarkv/workspace/kit/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func (r *redisTestSuite) TestTokenBucketLimiter() {
+	// Given
+	ctx := context.TODO()
+	l, err := NewTokenBucketLimiter(r.cache, ratelimit.Limit{Rate: 1, Period: time.Minute, Burst: 2})
+	assert.NoError(r.T(), err)
+	key := fmt.Sprintf("key_%s", id.New())
+
+	// When
+	res1, err1 := l.Allow(ctx, key)
+	res2, err2 := l.Allow(ctx, key)
+	res3, err3 := l.Allow(ctx, key)
+
+	// Then
+	assert.NoError(r.T(), err1)
+	assert.NoError(r.T(), err2)
+	assert.NoError(r.T(), err3)
+	assert.Equal(r.T(), ratelimit.Result{Allowed: true, Limit: 1, Remaining: 1}, res1)
+	assert.Equal(r.T(), ratelimit.Result{Allowed: true, Limit: 1, Remaining: 0}, res2)
+	assert.False(r.T(), res3.Allowed)
+	assert.InDelta(r.T(), time.Minute, res3.RetryAfter, float64(time.Second))
+}
+
+func (r *redisTestSuite) TestSlidingWindowLimiter() {
+	// Given
+	ctx := context.TODO()
+	l, err := NewSlidingWindowLimiter(r.cache, ratelimit.PerMinute(2))
+	assert.NoError(r.T(), err)
+	key := fmt.Sprintf("key_%s", id.New())
+
+	// When
+	res1, err1 := l.Allow(ctx, key)
+	res2, err2 := l.Allow(ctx, key)
+	res3, err3 := l.Allow(ctx, key)
+
+	// Then
+	assert.NoError(r.T(), err1)
+	assert.NoError(r.T(), err2)
+	assert.NoError(r.T(), err3)
+	assert.True(r.T(), res1.Allowed)
+	assert.True(r.T(), res2.Allowed)
+	assert.False(r.T(), res3.Allowed)
+	assert.Greater(r.T(), res3.RetryAfter, time.Duration(0))
+}
@@ -3,6 +3,7 @@
 import (
 	"context"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/mukhtarkv/workspace/kit/cache"
@@ -14,19 +15,106 @@
 // enforce the Cache to implement the cache.Cache interface.
 var _ cache.Cache = (*Cache)(nil)
 
+// incrScript increments the number stored at the key, keeping its TTL, so counters can be read with Get.
+// The number is decoded from msgpack, or from the JSON and msgpack values written by a Serializer
+// without compression (see cache.UnmarshalInt), and written back as msgpack.
+// Lua numbers are doubles, counters are exact up to 2^53.
+var incrScript = redis.NewScript(`
+local n = 0
+local v = redis.call("GET", KEYS[1])
+if v then
+	local marker, header = string.byte(v, 1, 2)
+	if marker ~= 0xc1 then
+		n = cmsgpack.unpack(v)
+	elseif header == 0x48 then
+		n = cjson.decode(string.sub(v, 3))
+	elseif header == 0x40 then
+		n = cmsgpack.unpack(string.sub(v, 3))
+	else
+		return redis.error_reply("` + string(cache.ErrIncrEncoding) + `")
+	end
+	if type(n) ~= "number" or n ~= math.floor(n) then
+		return redis.error_reply("cache value is not an integer")
+	end
+end
+n = n + tonumber(ARGV[1])
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], cmsgpack.pack(n), "PX", ttl)
+else
+	redis.call("SET", KEYS[1], cmsgpack.pack(n))
+end
+return n
+`)
+
+// casScript replaces the value of the key only if it is equal to the old value.
+var casScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	if tonumber(ARGV[3]) > 0 then
+		redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	else
+		redis.call("SET", KEYS[1], ARGV[2])
+	end
+	return 1
+end
+return 0
+`)
+
+// Option defines a Cache option.
+type Option func(*options)
+
+// options provides a set of configurable options for the Cache.
+type options struct {
+	serializer *cache.Serializer
+}
+
 // Cache provides a cache based on Redis
 type Cache struct {
-	client *redis.Client
+	client redis.UniversalClient
+	opts   options
 }
 
 // New create a new Cache with the given redis configuration.
-func New(opt *redis.Options) (*Cache, error) {
+func New(opt *redis.Options, opts ...Option) (*Cache, error) {
 	// If there is no options, we should stop and return an error.
 	if opt == nil {
 		return nil, errors.New("redis option missing")
 	}
-	// create the client
-	rdb := redis.NewClient(opt)
+	return NewFromClient(redis.NewClient(opt), opts...)
+}
+
+// NewUniversal creates a new Cache with the given universal redis configuration:
+// a Sentinel failover client if MasterName is set, a Cluster client if several addresses are given,
+// and a single node client otherwise.
+func NewUniversal(opt *redis.UniversalOptions, opts ...Option) (*Cache, error) {
+	if opt == nil {
+		return nil, errors.New("redis option missing")
+	}
+	return NewFromClient(redis.NewUniversalClient(opt), opts...)
+}
+
+// NewRing creates a new Cache sharding the keys over the given Redis nodes, with consistent hashing.
+func NewRing(opt *redis.RingOptions, opts ...Option) (*Cache, error) {
+	if opt == nil {
+		return nil, errors.New("redis option missing")
+	}
+	return NewFromClient(redis.NewRing(opt), opts...)
+}
+
+// NewFromClient creates a new Cache with the given redis client,
+// which is instrumented for tracing and metrics.
+func NewFromClient(rdb redis.UniversalClient, opts ...Option) (*Cache, error) {
+	if rdb == nil {
+		return nil, errors.New("no redis client")
+	}
+
+	// default options
+	o := options{
+		serializer: cache.DefaultSerializer,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	// Enable tracing instrumentation.
 	if err := redisotel.InstrumentTracing(rdb); err != nil {
@@ -40,6 +128,7 @@ func New(opt *redis.Options) (*Cache, error) {
 
 	return &Cache{
 		client: rdb,
+		opts:   o,
 	}, nil
 }
 
@@ -56,6 +145,11 @@ func (c *Cache) Close() error {
 	return c.client.Close()
 }
 
+// Client returns the underlying redis client, for the commands not provided by the Cache.
+func (c *Cache) Client() redis.UniversalClient {
+	return c.client
+}
+
 func (c *Cache) Get(ctx context.Context, key string, value interface{}) error {
 	if len(key) == 0 {
 		return cache.ErrKeyInvalid
@@ -80,47 +174,84 @@ func (c *Cache) MultiGet(ctx context.Context, keys []string, value interface{})
 		return nil
 	}
 
-	// Making sure that we are getting the correct interface
-	// we are expecting to get a &[]myType
-	typeOf := reflect.TypeOf(value)
-	if typeOf.Kind() != reflect.Ptr {
-		return errors.New("value should be a pointer")
+	valueOf, err := sliceValue(value)
+	if err != nil {
+		return err
 	}
 
-	valueOf := reflect.ValueOf(value).Elem()
-	if valueOf.Kind() != reflect.Slice {
-		return errors.New("value should be a pointer of slice")
+	values, err := c.MultiGetRaw(ctx, keys)
+	if err != nil {
+		return err
 	}
+	appendValues(valueOf, values)
 
-	results, err := c.client.MGet(ctx, keys...).Result()
-	if err != nil {
-		return errors.Wrapf(err, "redis MGet error, keys is %+v", keys)
+	return nil
+}
+
+func (c *Cache) MultiGetRaw(ctx context.Context, keys []string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
 	}
 
-	// If not results found
-	// We exist and the slice will be empty.
-	if len(results) == 0 {
+	// keys are read one by one, as they may belong to different cluster slots or ring shards.
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
 		return nil
+	})
+	// the missing keys fail the pipeline with redis.Nil.
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, errors.Wrapf(err, "getting values of keys %+v", keys)
 	}
 
+	values := make([][]byte, len(keys))
+	for i, cmd := range cmds {
+		b, err := cmd.Bytes()
+		if err == nil {
+			values[i] = b
+		} else if !errors.Is(err, redis.Nil) {
+			return nil, errors.Wrapf(err, "getting value of key '%s'", keys[i])
+		}
+	}
+	return values, nil
+}
+
+// sliceValue returns the slice pointed by the value.
+func sliceValue(value interface{}) (reflect.Value, error) {
+	// Making sure that we are getting the correct interface
+	// we are expecting to get a &[]myType
+	typeOf := reflect.TypeOf(value)
+	if typeOf == nil || typeOf.Kind() != reflect.Ptr {
+		return reflect.Value{}, errors.New("value should be a pointer")
+	}
+
+	valueOf := reflect.ValueOf(value).Elem()
+	if valueOf.Kind() != reflect.Slice {
+		return reflect.Value{}, errors.New("value should be a pointer of slice")
+	}
+	return valueOf, nil
+}
+
+// appendValues unmarshals the encoded values and appends them to the slice,
+// skipping the missing values and the values that cannot be unmarshalled.
+func appendValues(slice reflect.Value, values [][]byte) {
 	// type represent the type of the slice
-	typ := reflect.TypeOf(value).Elem().Elem()
-	for _, result := range results {
-		if result == nil {
+	typ := slice.Type().Elem()
+	for _, b := range values {
+		if b == nil {
 			continue
 		}
 
 		// creating a new value of the slice type
 		object := reflect.New(typ).Interface()
-		err = cache.Unmarshal([]byte(result.(string)), object)
-		if err != nil {
+		if err := cache.Unmarshal(b, object); err != nil {
 			continue
 		}
 		// Adding to the slice the value.
-		valueOf.Set(reflect.Append(valueOf, reflect.ValueOf(object).Elem()))
+		slice.Set(reflect.Append(slice, reflect.ValueOf(object).Elem()))
 	}
-
-	return nil
 }
 
 func (c *Cache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
@@ -132,7 +263,7 @@ func (c *Cache) Set(ctx context.Context, key string, value interface{}, expirati
 		return cache.ErrValueInvalid
 	}
 
-	b, err := cache.Marshal(value)
+	b, err := c.opts.serializer.Marshal(value)
 	if err != nil {
 		return errors.Wrapf(err, "marshalling value for key '%s'", key)
 	}
@@ -155,3 +286,196 @@ func (c *Cache) Delete(ctx context.Context, key string) error {
 
 	return nil
 }
+
+func (c *Cache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	b, err := c.marshal(key, value)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := c.client.SetNX(ctx, key, b, expiration).Result()
+	if err != nil {
+		return false, errors.Wrapf(err, "saving value to cache for key '%s'", key)
+	}
+	return ok, nil
+}
+
+func (c *Cache) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	if len(key) == 0 {
+		return 0, cache.ErrKeyInvalid
+	}
+
+	n, err := incrScript.Run(ctx, c.client, []string{key}, delta).Int64()
+	if err != nil && strings.Contains(err.Error(), string(cache.ErrIncrEncoding)) {
+		return 0, errors.Wrapf(cache.ErrIncrEncoding, "incrementing value for key '%s'", key)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "incrementing value for key '%s'", key)
+	}
+	return n, nil
+}
+
+func (c *Cache) Decr(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.Incr(ctx, key, -delta)
+}
+
+func (c *Cache) MultiSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	encoded := make(map[string][]byte, len(values))
+	for key, value := range values {
+		b, err := c.marshal(key, value)
+		if err != nil {
+			return err
+		}
+		encoded[key] = b
+	}
+
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, b := range encoded {
+			pipe.Set(ctx, key, b, expiration)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "saving values to cache")
+	}
+	return nil
+}
+
+func (c *Cache) MultiDelete(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	for _, key := range keys {
+		if len(key) == 0 {
+			return cache.ErrKeyInvalid
+		}
+	}
+
+	// keys are deleted one by one, as they may belong to different cluster slots.
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "deleting values from cache")
+	}
+	return nil
+}
+
+func (c *Cache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	if len(key) == 0 {
+		return false, cache.ErrKeyInvalid
+	}
+
+	if expiration > 0 {
+		ok, err := c.client.PExpire(ctx, key, expiration).Result()
+		if err != nil {
+			return false, errors.Wrapf(err, "setting expiration of key '%s'", key)
+		}
+		return ok, nil
+	}
+
+	// PERSIST returns false for the keys without expiration, their existence is checked as well.
+	var exists *redis.IntCmd
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Persist(ctx, key)
+		exists = pipe.Exists(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "removing expiration of key '%s'", key)
+	}
+	return exists.Val() == 1, nil
+}
+
+func (c *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if len(key) == 0 {
+		return 0, cache.ErrKeyInvalid
+	}
+
+	ttl, err := c.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, errors.Wrapf(err, "getting expiration of key '%s'", key)
+	}
+
+	// -2 means the key doesn't exist, -1 that it has no expiration.
+	switch ttl {
+	case -2:
+		return 0, cache.ErrNotFound
+	case -1:
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, old interface{}, new interface{}, expiration time.Duration) (bool, error) {
+	oldBytes, err := c.marshal(key, old)
+	if err != nil {
+		return false, err
+	}
+	newBytes, err := c.marshal(key, new)
+	if err != nil {
+		return false, err
+	}
+
+	swapped, err := casScript.Run(ctx, c.client, []string{key}, oldBytes, newBytes, expiration.Milliseconds()).Int64()
+	if err != nil {
+		return false, errors.Wrapf(err, "swapping value for key '%s'", key)
+	}
+	return swapped == 1, nil
+}
+
+func (c *Cache) GetSet(ctx context.Context, key string, value interface{}, expiration time.Duration, old interface{}) error {
+	b, err := c.marshal(key, value)
+	if err != nil {
+		return err
+	}
+
+	// SET with the GET option sets the expiration along with the value, unlike GETSET,
+	// it requires Redis 6.2.
+	prev, err := c.client.SetArgs(ctx, key, b, redis.SetArgs{TTL: expiration, Get: true}).Result()
+	if err != nil {
+		// If key didn't exist.
+		if errors.Is(err, redis.Nil) {
+			return cache.ErrNotFound
+		}
+		return errors.Wrapf(err, "saving value to cache for key '%s'", key)
+	}
+
+	return cache.Unmarshal([]byte(prev), old)
+}
+
+// marshal validates the key and value, and returns the encoded value.
+func (c *Cache) marshal(key string, value interface{}) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, cache.ErrKeyInvalid
+	}
+
+	if value == nil {
+		return nil, cache.ErrValueInvalid
+	}
+
+	b, err := c.opts.serializer.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "marshalling value for key '%s'", key)
+	}
+	return b, nil
+}
+
+// WithSerializer defines the serializer encoding the values, such as with another codec or compression.
+// Values are decoded according to their header, the serializer can be changed without flushing the cache.
+//
+// Serializer defaults to cache.DefaultSerializer, encoding with msgpack.
+func WithSerializer(s *cache.Serializer) Option {
+	return func(o *options) {
+		if s != nil {
+			o.serializer = s
+		}
+	}
+}
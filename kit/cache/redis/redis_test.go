@@ -4,7 +4,9 @@
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mukhtarkv/workspace/kit/cache"
 	"github.com/mukhtarkv/workspace/kit/id"
@@ -157,3 +159,162 @@ func (r *redisTestSuite) TestSetAndDelete() {
 	assert.Empty(r.T(), noop)
 	assert.Equal(r.T(), err, cache.ErrNotFound)
 }
+
+func (r *redisTestSuite) TestIncrAndDecr() {
+	// Given
+	ctx := context.TODO()
+	key := fmt.Sprintf("key_%s", id.New())
+	defer r.cache.Delete(ctx, key)
+
+	// When
+	n, err := r.cache.Incr(ctx, key, 5)
+	assert.NoError(r.T(), err)
+	assert.EqualValues(r.T(), 5, n)
+	_, err = r.cache.Expire(ctx, key, time.Minute)
+	assert.NoError(r.T(), err)
+	n, err = r.cache.Decr(ctx, key, 2)
+
+	// Then
+	assert.NoError(r.T(), err)
+	assert.EqualValues(r.T(), 3, n)
+
+	var v int
+	assert.NoError(r.T(), r.cache.Get(ctx, key, &v))
+	assert.Equal(r.T(), 3, v)
+
+	ttl, err := r.cache.TTL(ctx, key)
+	assert.NoError(r.T(), err)
+	assert.Greater(r.T(), ttl, 50*time.Second)
+}
+
+func (r *redisTestSuite) TestIncrSerializer() {
+	// Given a cache encoding the values with JSON
+	ctx := context.TODO()
+	c, err := NewFromClient(r.cache.client, WithSerializer(cache.NewSerializer(cache.WithCodec(cache.JSON))))
+	assert.NoError(r.T(), err)
+	key := fmt.Sprintf("key_%s", id.New())
+	defer r.cache.Delete(ctx, key)
+	assert.NoError(r.T(), c.Set(ctx, key, 41, 0))
+
+	// When
+	n, err := c.Incr(ctx, key, 1)
+
+	// Then
+	assert.NoError(r.T(), err)
+	assert.EqualValues(r.T(), 42, n)
+	var v int
+	assert.NoError(r.T(), c.Get(ctx, key, &v))
+	assert.Equal(r.T(), 42, v)
+
+	// the encodings the script cannot decode are rejected.
+	gob, err := NewFromClient(r.cache.client, WithSerializer(cache.NewSerializer(cache.WithCodec(cache.Gob))))
+	assert.NoError(r.T(), err)
+	assert.NoError(r.T(), gob.Set(ctx, key, 41, 0))
+	_, err = gob.Incr(ctx, key, 1)
+	assert.ErrorIs(r.T(), err, cache.ErrIncrEncoding)
+}
+
+func (r *redisTestSuite) TestSetNXAndGetSet() {
+	ctx := context.TODO()
+	key := fmt.Sprintf("key_%s", id.New())
+	defer r.cache.Delete(ctx, key)
+
+	ok, err := r.cache.SetNX(ctx, key, "a", time.Minute)
+	assert.NoError(r.T(), err)
+	assert.True(r.T(), ok)
+	ok, err = r.cache.SetNX(ctx, key, "b", time.Minute)
+	assert.NoError(r.T(), err)
+	assert.False(r.T(), ok)
+
+	var old string
+	assert.NoError(r.T(), r.cache.GetSet(ctx, key, "c", time.Minute, &old))
+	assert.Equal(r.T(), "a", old)
+}
+
+func (r *redisTestSuite) TestMultiSetAndMultiDelete() {
+	ctx := context.TODO()
+	keys := []string{fmt.Sprintf("key_%s", id.New()), fmt.Sprintf("key_%s", id.New())}
+	defer r.cache.MultiDelete(ctx, keys)
+
+	err := r.cache.MultiSet(ctx, map[string]interface{}{keys[0]: "a", keys[1]: "b"}, time.Minute)
+	assert.NoError(r.T(), err)
+
+	var values []string
+	assert.NoError(r.T(), r.cache.MultiGet(ctx, keys, &values))
+	assert.Equal(r.T(), []string{"a", "b"}, values)
+
+	assert.NoError(r.T(), r.cache.MultiDelete(ctx, keys))
+	_, err = r.cache.TTL(ctx, keys[0])
+	assert.ErrorIs(r.T(), err, cache.ErrNotFound)
+}
+
+func (r *redisTestSuite) TestCompareAndSwap() {
+	ctx := context.TODO()
+	key := fmt.Sprintf("key_%s", id.New())
+	defer r.cache.Delete(ctx, key)
+
+	assert.NoError(r.T(), r.cache.Set(ctx, key, "a", 0))
+	swapped, err := r.cache.CompareAndSwap(ctx, key, "b", "c", time.Minute)
+	assert.NoError(r.T(), err)
+	assert.False(r.T(), swapped)
+
+	swapped, err = r.cache.CompareAndSwap(ctx, key, "a", "c", time.Minute)
+	assert.NoError(r.T(), err)
+	assert.True(r.T(), swapped)
+
+	var v string
+	assert.NoError(r.T(), r.cache.Get(ctx, key, &v))
+	assert.Equal(r.T(), "c", v)
+}
+
+func (r *redisTestSuite) TestMultiGetMap() {
+	ctx := context.TODO()
+	keys := []string{fmt.Sprintf("key_%s", id.New()), fmt.Sprintf("key_%s", id.New())}
+	defer r.cache.MultiDelete(ctx, keys)
+
+	assert.NoError(r.T(), r.cache.Set(ctx, keys[1], "b", time.Minute))
+
+	values, missing, err := cache.MultiGetMap[string](ctx, r.cache, keys)
+	assert.NoError(r.T(), err)
+	assert.Equal(r.T(), map[string]string{keys[1]: "b"}, values)
+	assert.Equal(r.T(), []string{keys[0]}, missing)
+}
+
+func TestClusterMultiGetRaw(t *testing.T) {
+	if os.Getenv("TESTINGREDIS_CLUSTER_URL") == "" {
+		t.Skip("Skipping, no testing redis cluster setup via env variable TESTINGREDIS_CLUSTER_URL")
+	}
+
+	// Given values in different cluster slots
+	conf := Config{Mode: ModeCluster, Addrs: strings.Split(os.Getenv("TESTINGREDIS_CLUSTER_URL"), ",")}
+	c, err := conf.New()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+
+	ctx := context.TODO()
+	suffix := id.New()
+	keys := []string{"{a}key_" + suffix, "{b}key_" + suffix, "{c}key_" + suffix}
+	defer c.MultiDelete(ctx, keys)
+	assert.NoError(t, c.Set(ctx, keys[0], "a", time.Minute))
+	assert.NoError(t, c.Set(ctx, keys[2], "c", time.Minute))
+
+	// When they are read at once
+	var values []string
+	err = c.MultiGet(ctx, keys, &values)
+
+	// Then they are read from their nodes
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, values)
+}
+
+func TestIncrScriptHeaders(t *testing.T) {
+	// the script matches the headers of the JSON and msgpack values written without compression.
+	b, err := cache.NewSerializer(cache.WithCodec(cache.JSON)).Marshal(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xc1, 0x48}, b[:2])
+	n, err := cache.UnmarshalInt(append([]byte{0xc1, 0x40}, 0x2a))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 42, n)
+}
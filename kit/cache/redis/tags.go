@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// enforce the Cache to implement the cache.TaggedCache interface.
+var _ cache.TaggedCache = (*Cache)(nil)
+
+// tagPrefix prefixes the keys of the tag sets.
+const tagPrefix = "tag:"
+
+// tagScript adds the key to the tag set, and extends the tag set expiration to cover the key expiration.
+// A tag set attached to a key without expiration never expires.
+var tagScript = redis.NewScript(`
+local created = redis.call("EXISTS", KEYS[1]) == 0
+redis.call("SADD", KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl <= 0 then
+	redis.call("PERSIST", KEYS[1])
+	return 1
+end
+local current = redis.call("PTTL", KEYS[1])
+if created or (current >= 0 and current < ttl) then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1
+`)
+
+// popTagScript deletes the tag set and returns its keys, so the keys tagged afterward belong to a new set.
+var popTagScript = redis.NewScript(`
+local keys = redis.call("SMEMBERS", KEYS[1])
+redis.call("DEL", KEYS[1])
+return keys
+`)
+
+// SetWithTags sets the value of the key, and adds the key to the set of each tag.
+//
+// Each script only touches the tag set, the keys and tag sets can live in different cluster slots.
+// Scripts are sent with EVAL, EVALSHA cannot fall back on a missing script within a pipeline.
+func (c *Cache) SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error {
+	b, err := c.marshal(key, value)
+	if err != nil {
+		return err
+	}
+	if err := validTags(tags); err != nil {
+		return err
+	}
+
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, b, expiration)
+		for _, tag := range tags {
+			tagScript.Eval(ctx, pipe, []string{tagPrefix + tag}, key, expiration.Milliseconds())
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "saving tagged value to cache for key '%s'", key)
+	}
+	return nil
+}
+
+// InvalidateTags deletes the keys of the tags sets, and the tag sets.
+//
+// The tag sets are not cleaned when their keys expire or are set again without the tag,
+// such keys are deleted as well.
+func (c *Cache) InvalidateTags(ctx context.Context, tags ...string) error {
+	_, err := c.invalidateTags(ctx, tags)
+	return err
+}
+
+// invalidateTags deletes the keys of the tags, and returns them.
+func (c *Cache) invalidateTags(ctx context.Context, tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	if err := validTags(tags); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.Cmd, len(tags))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, tag := range tags {
+			cmds[i] = popTagScript.Eval(ctx, pipe, []string{tagPrefix + tag})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting keys of tags %+v", tags)
+	}
+
+	var keys []string
+	for _, cmd := range cmds {
+		tagKeys, err := cmd.StringSlice()
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting keys of tags %+v", tags)
+		}
+		keys = append(keys, tagKeys...)
+	}
+
+	if err := c.MultiDelete(ctx, keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// validTags checks the tags are not empty.
+func validTags(tags []string) error {
+	for _, tag := range tags {
+		if len(tag) == 0 {
+			return errors.New("cache tag is empty")
+		}
+	}
+	return nil
+}
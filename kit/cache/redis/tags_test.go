@@ -0,0 +1,67 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/id"
+	"github.com/stretchr/testify/assert"
+)
+
+func (r *redisTestSuite) TestInvalidateTags() {
+	// Given
+	ctx := context.TODO()
+	suffix := id.New()
+	todo := fmt.Sprintf("todo_%s", suffix)
+	page := fmt.Sprintf("page_%s", suffix)
+	other := fmt.Sprintf("other_%s", suffix)
+	defer r.cache.MultiDelete(ctx, []string{todo, page, other})
+
+	assert.NoError(r.T(), r.cache.SetWithTags(ctx, todo, "todo", time.Minute, todo))
+	assert.NoError(r.T(), r.cache.SetWithTags(ctx, page, "page", 0, "todos_"+suffix, todo))
+	assert.NoError(r.T(), r.cache.SetWithTags(ctx, other, "other", time.Minute, "todos_"+suffix))
+
+	// the tag set lives as long as its longest key.
+	ttl, err := r.cache.TTL(ctx, tagPrefix+todo)
+	assert.NoError(r.T(), err)
+	assert.Zero(r.T(), ttl)
+
+	// When
+	err = r.cache.InvalidateTags(ctx, todo)
+
+	// Then
+	assert.NoError(r.T(), err)
+	var v string
+	assert.ErrorIs(r.T(), r.cache.Get(ctx, todo, &v), cache.ErrNotFound)
+	assert.ErrorIs(r.T(), r.cache.Get(ctx, page, &v), cache.ErrNotFound)
+	assert.NoError(r.T(), r.cache.Get(ctx, other, &v))
+
+	assert.NoError(r.T(), r.cache.InvalidateTags(ctx, "todos_"+suffix))
+	assert.ErrorIs(r.T(), r.cache.Get(ctx, other, &v), cache.ErrNotFound)
+}
+
+func (r *redisTestSuite) TestNearCacheInvalidateTags() {
+	// Given
+	ctx := context.TODO()
+	a, b := r.newNearCaches()
+	defer a.Close()
+	defer b.Close()
+
+	key := fmt.Sprintf("key_%s", id.New())
+	tag := fmt.Sprintf("tag_%s", id.New())
+	defer r.cache.Delete(ctx, key)
+
+	assert.NoError(r.T(), a.SetWithTags(ctx, key, "value", time.Minute, tag))
+	var v string
+	assert.NoError(r.T(), b.Get(ctx, key, &v))
+
+	// When
+	assert.NoError(r.T(), a.InvalidateTags(ctx, tag))
+
+	// Then
+	assert.Eventually(r.T(), func() bool {
+		return b.Get(ctx, key, &v) == cache.ErrNotFound
+	}, time.Second, 10*time.Millisecond)
+}
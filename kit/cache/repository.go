@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+)
+
+// RepositoryOption defines a Repository option.
+type RepositoryOption func(*repositoryOptions)
+
+// repositoryOptions provides a set of configurable options for the Repository.
+type repositoryOptions struct {
+	prefix         string
+	ttl            time.Duration
+	queries        map[string]time.Duration
+	loadingOptions []LoadingOption
+}
+
+// RepositoryConfig represents the caching configuration of a Repository, it can be loaded with config.From:
+//
+//	todo:
+//	  enabled: true
+//	  ttl: 5m
+//	  staleTTL: 1m
+//	  queries:
+//	    list: 30s
+type RepositoryConfig struct {
+	// Enabled enables the caching of the storage.
+	Enabled bool `yaml:"enabled"`
+	// TTL is how long the fetched entities are cached, 1 minute by default.
+	TTL time.Duration `yaml:"ttl"`
+	// StaleTTL is how long the entities and query results are served stale while they are refreshed.
+	StaleTTL time.Duration `yaml:"staleTTL"`
+	// Queries are how long the results of the named queries are cached, the other queries are not cached.
+	Queries map[string]time.Duration `yaml:"queries"`
+}
+
+// Options returns the Repository options of the configuration.
+func (c *RepositoryConfig) Options() []RepositoryOption {
+	opts := []RepositoryOption{
+		WithEntityTTL(c.TTL),
+		WithRepositoryLoadingOptions(WithStaleTTL(c.StaleTTL)),
+	}
+	for name, ttl := range c.Queries {
+		opts = append(opts, WithQueryTTL(name, ttl))
+	}
+	return opts
+}
+
+// Repository is a read-through, write-invalidate cache decorating a storage of entities of type T.
+//
+// Fetch reads the entities through the cache, and the named queries, such as listing the entities,
+// are cached when a TTL is defined for them. Create, Update and Delete write to the storage first,
+// then invalidate the entity and all the cached query results.
+//
+// The query results are invalidated by incrementing a generation counter part of their keys,
+// the previous results expiring with their TTL, so the Repository works over any Cache.
+//
+//	repo := cache.NewRepository[*ToDo](c, "todo", func(t *ToDo) string { return t.ID })
+//	todo, err := repo.Fetch(ctx, id, storage.Fetch)
+type Repository[T any] struct {
+	cache   Cache
+	loading *LoadingCache
+	id      func(T) string
+	opts    repositoryOptions
+}
+
+// NewRepository creates a new Repository caching the entities of type T in the given cache,
+// under keys prefixed by the name. The id function returns the ID of an entity.
+func NewRepository[T any](c Cache, name string, id func(T) string, opts ...RepositoryOption) *Repository[T] {
+	// default options
+	o := repositoryOptions{
+		prefix:  name,
+		ttl:     time.Minute,
+		queries: map[string]time.Duration{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Repository[T]{
+		cache:   c,
+		loading: NewLoadingCache(c, o.loadingOptions...),
+		id:      id,
+		opts:    o,
+	}
+}
+
+// Fetch returns the entity of the given ID from the cache, or from the fetch function
+// of the storage if it is missing.
+func (r *Repository[T]) Fetch(ctx context.Context, id string, fetch func(ctx context.Context, id string) (T, error)) (T, error) {
+	var v T
+	err := r.loading.GetOrLoad(ctx, r.entityKey(id), &v, r.opts.ttl, func(ctx context.Context) (interface{}, error) {
+		return fetch(ctx, id)
+	})
+	return v, err
+}
+
+// Query unmarshalls the result of the named query into dest, from the cache if a TTL is defined
+// for the query (see WithQueryTTL), otherwise from the query function of the storage.
+//
+//	var todos []ToDo
+//	err := repo.Query(ctx, "list", &todos, func(ctx context.Context) (interface{}, error) {
+//		return storage.List(ctx)
+//	})
+func (r *Repository[T]) Query(ctx context.Context, name string, dest interface{}, query Loader) error {
+	ttl, ok := r.opts.queries[name]
+	if !ok {
+		v, err := query(ctx)
+		if err != nil {
+			return err
+		}
+		// the result goes through the same encoding than the cached ones.
+		b, err := Marshal(v)
+		if err != nil {
+			return errors.Wrapf(err, "marshalling query '%s' result", name)
+		}
+		return Unmarshal(b, dest)
+	}
+
+	key, err := r.queryKey(ctx, name)
+	if err != nil {
+		return err
+	}
+	return r.loading.GetOrLoad(ctx, key, dest, ttl, query)
+}
+
+// Create creates the entity with the create function of the storage, then invalidates the cached queries.
+func (r *Repository[T]) Create(ctx context.Context, v T, create func(ctx context.Context, v T) error) error {
+	if err := create(ctx, v); err != nil {
+		return err
+	}
+	return r.Invalidate(ctx, r.id(v))
+}
+
+// Update updates the entity with the update function of the storage, then invalidates it
+// and the cached queries.
+func (r *Repository[T]) Update(ctx context.Context, v T, update func(ctx context.Context, v T) error) error {
+	if err := update(ctx, v); err != nil {
+		return err
+	}
+	return r.Invalidate(ctx, r.id(v))
+}
+
+// Delete deletes the entity of the given ID with the delete function of the storage, then invalidates it
+// and the cached queries.
+func (r *Repository[T]) Delete(ctx context.Context, id string, del func(ctx context.Context, id string) error) error {
+	if err := del(ctx, id); err != nil {
+		return err
+	}
+	return r.Invalidate(ctx, id)
+}
+
+// Invalidate deletes the cached entities of the given IDs and all the cached query results,
+// for the entities changed without going through the Repository.
+func (r *Repository[T]) Invalidate(ctx context.Context, ids ...string) error {
+	if len(r.opts.queries) > 0 {
+		if _, err := r.cache.Incr(ctx, r.generationKey(), 1); err != nil {
+			return errors.Wrap(err, "invalidating cached queries")
+		}
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, r.entityKey(id))
+	}
+	if err := r.cache.MultiDelete(ctx, keys); err != nil {
+		return errors.Wrap(err, "invalidating cached entities")
+	}
+	return nil
+}
+
+func (r *Repository[T]) entityKey(id string) string {
+	return r.opts.prefix + ":" + id
+}
+
+func (r *Repository[T]) generationKey() string {
+	return r.opts.prefix + ":generation"
+}
+
+// queryKey returns the key of the query result in the current generation.
+func (r *Repository[T]) queryKey(ctx context.Context, name string) (string, error) {
+	var generation int64
+	if err := r.cache.Get(ctx, r.generationKey(), &generation); err != nil && !errors.Is(err, ErrNotFound) {
+		return "", errors.Wrap(err, "getting cached queries generation")
+	}
+	return r.opts.prefix + ":query:" + strconv.FormatInt(generation, 10) + ":" + name, nil
+}
+
+// WithKeyPrefix defines the prefix of the keys of the cached entities and queries.
+//
+// KeyPrefix defaults to the name of the Repository.
+func WithKeyPrefix(prefix string) RepositoryOption {
+	return func(o *repositoryOptions) {
+		if len(prefix) > 0 {
+			o.prefix = prefix
+		}
+	}
+}
+
+// WithEntityTTL defines how long the fetched entities are cached.
+//
+// EntityTTL defaults to 1 minute.
+func WithEntityTTL(d time.Duration) RepositoryOption {
+	return func(o *repositoryOptions) {
+		if d > 0 {
+			o.ttl = d
+		}
+	}
+}
+
+// WithQueryTTL enables the caching of the result of the named query for the given duration,
+// 0 meaning the result is cached until invalidated.
+//
+// Queries are not cached by default.
+func WithQueryTTL(name string, d time.Duration) RepositoryOption {
+	return func(o *repositoryOptions) {
+		if d >= 0 {
+			o.queries[name] = d
+		}
+	}
+}
+
+// WithRepositoryLoadingOptions defines the options of the LoadingCache reading through the storage,
+// such as WithStaleTTL or WithEarlyExpiration.
+func WithRepositoryLoadingOptions(opts ...LoadingOption) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.loadingOptions = append(o.loadingOptions, opts...)
+	}
+}
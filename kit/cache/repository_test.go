@@ -0,0 +1,213 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/cache/memory"
+	"github.com/mukhtarkv/workspace/kit/config"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	ID   string
+	Name string
+}
+
+// storage is an in-memory storage counting its calls.
+type storage struct {
+	items   map[string]item
+	fetches int
+	lists   int
+}
+
+func (s *storage) Fetch(ctx context.Context, id string) (*item, error) {
+	s.fetches++
+	it, ok := s.items[id]
+	if !ok {
+		return nil, errors.New("item not found")
+	}
+	return &it, nil
+}
+
+func (s *storage) List(ctx context.Context) (interface{}, error) {
+	s.lists++
+	res := make([]item, 0, len(s.items))
+	for _, it := range s.items {
+		res = append(res, it)
+	}
+	return res, nil
+}
+
+func (s *storage) Save(ctx context.Context, it *item) error {
+	s.items[it.ID] = *it
+	return nil
+}
+
+func (s *storage) Delete(ctx context.Context, id string) error {
+	delete(s.items, id)
+	return nil
+}
+
+func newRepository(t *testing.T, opts ...cache.RepositoryOption) (*cache.Repository[*item], *storage) {
+	c, err := memory.New()
+	if err != nil {
+		t.Fatalf("setting up memory cache %v", err)
+	}
+	repo := cache.NewRepository(c, "item", func(it *item) string { return it.ID }, opts...)
+	return repo, &storage{items: map[string]item{"1": {ID: "1", Name: "first"}}}
+}
+
+func TestRepositoryFetch(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	repo, s := newRepository(t)
+
+	// When
+	it1, err1 := repo.Fetch(ctx, "1", s.Fetch)
+	it2, err2 := repo.Fetch(ctx, "1", s.Fetch)
+
+	// Then
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, &item{ID: "1", Name: "first"}, it1)
+	assert.Equal(t, it1, it2)
+	assert.Equal(t, 1, s.fetches)
+}
+
+func TestRepositoryFetchError(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	repo, s := newRepository(t)
+
+	// When
+	_, err1 := repo.Fetch(ctx, "2", s.Fetch)
+	_, err2 := repo.Fetch(ctx, "2", s.Fetch)
+
+	// Then
+	assert.EqualError(t, err1, "item not found")
+	assert.EqualError(t, err2, "item not found")
+	assert.Equal(t, 2, s.fetches)
+}
+
+func TestRepositoryUpdateInvalidates(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	repo, s := newRepository(t)
+	_, err := repo.Fetch(ctx, "1", s.Fetch)
+	assert.NoError(t, err)
+
+	// When
+	err = repo.Update(ctx, &item{ID: "1", Name: "updated"}, s.Save)
+	assert.NoError(t, err)
+	it, err := repo.Fetch(ctx, "1", s.Fetch)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", it.Name)
+	assert.Equal(t, 2, s.fetches)
+}
+
+func TestRepositoryDeleteInvalidates(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	repo, s := newRepository(t)
+	_, err := repo.Fetch(ctx, "1", s.Fetch)
+	assert.NoError(t, err)
+
+	// When
+	err = repo.Delete(ctx, "1", s.Delete)
+	assert.NoError(t, err)
+	_, err = repo.Fetch(ctx, "1", s.Fetch)
+
+	// Then
+	assert.EqualError(t, err, "item not found")
+}
+
+func TestRepositoryWriteErrorKeepsCache(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	repo, s := newRepository(t)
+	_, err := repo.Fetch(ctx, "1", s.Fetch)
+	assert.NoError(t, err)
+
+	// When
+	err = repo.Update(ctx, &item{ID: "1"}, func(ctx context.Context, it *item) error {
+		return errors.New("update failed")
+	})
+	_, _ = repo.Fetch(ctx, "1", s.Fetch)
+
+	// Then
+	assert.EqualError(t, err, "update failed")
+	assert.Equal(t, 1, s.fetches)
+}
+
+func TestRepositoryQuery(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	repo, s := newRepository(t, cache.WithQueryTTL("list", time.Minute))
+
+	// When
+	var l1, l2, l3 []item
+	err1 := repo.Query(ctx, "list", &l1, s.List)
+	err2 := repo.Query(ctx, "list", &l2, s.List)
+	err := repo.Create(ctx, &item{ID: "2", Name: "second"}, s.Save)
+	assert.NoError(t, err)
+	err3 := repo.Query(ctx, "list", &l3, s.List)
+
+	// Then
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.NoError(t, err3)
+	assert.Len(t, l1, 1)
+	assert.Equal(t, l1, l2)
+	assert.Len(t, l3, 2)
+	assert.Equal(t, 2, s.lists)
+}
+
+func TestRepositoryQueryNotCached(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	repo, s := newRepository(t)
+
+	// When
+	var l1, l2 []item
+	err1 := repo.Query(ctx, "list", &l1, s.List)
+	err2 := repo.Query(ctx, "list", &l2, s.List)
+
+	// Then
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, []item{{ID: "1", Name: "first"}}, l1)
+	assert.Equal(t, l1, l2)
+	assert.Equal(t, 2, s.lists)
+}
+
+func TestRepositoryConfig(t *testing.T) {
+	// Given
+	rawConf := bytes.NewBufferString(`
+enabled: true
+ttl: 5m
+staleTTL: 1m
+queries:
+  list: 30s
+`)
+
+	// When
+	var c cache.RepositoryConfig
+	err := config.From(rawConf, &c)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, cache.RepositoryConfig{
+		Enabled:  true,
+		TTL:      5 * time.Minute,
+		StaleTTL: time.Minute,
+		Queries:  map[string]time.Duration{"list": 30 * time.Second},
+	}, c)
+	assert.Len(t, c.Options(), 3)
+}
@@ -11,6 +11,7 @@
 	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -18,22 +19,31 @@
 	"github.com/gorilla/mux"
 	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/mukhtarkv/workspace/kit/auth"
 	"github.com/mukhtarkv/workspace/kit/config"
 	"github.com/mukhtarkv/workspace/kit/errors"
 	grpckit "github.com/mukhtarkv/workspace/kit/grpc"
 	"github.com/mukhtarkv/workspace/kit/log"
+	"github.com/mukhtarkv/workspace/kit/mtls"
+	"github.com/mukhtarkv/workspace/kit/ratelimit"
 	"github.com/mukhtarkv/workspace/kit/telemetry"
 	"github.com/rs/cors"
 	metrics "github.com/slok/go-http-metrics/metrics/prometheus"
 	"github.com/slok/go-http-metrics/middleware"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 	"go.uber.org/automaxprocs/maxprocs"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
+// gatewayBufferSize is the buffer size of the in-memory connections of the grpc-gateway.
+const gatewayBufferSize = 1 << 20
+
 // defaultHealthHandler provides a default health function.
 var _defaultHealthHandler = func(writer http.ResponseWriter, _ *http.Request) {
 	writer.WriteHeader(http.StatusOK)
@@ -53,9 +63,11 @@ type Foundation struct {
 	// foundation logger
 	logger *log.Logger
 	// gRPC gateway
-	gw       *runtime.ServeMux
-	gwClient *grpc.ClientConn
-	gwOnce   sync.Once
+	gw         *runtime.ServeMux
+	gwClient   *grpc.ClientConn
+	gwListener *bufconn.Listener
+	gwErr      error
+	gwOnce     sync.Once
 	// gRPC server
 	grpcServer *grpc.Server
 	grpcOnce   sync.Once
@@ -66,6 +78,13 @@ type Foundation struct {
 	// Healths checks
 	livenessProbe  http.HandlerFunc
 	readinessProbe http.HandlerFunc
+	// Shutdown
+	closers      map[ShutdownPhase][]closer
+	closersLock  sync.Mutex
+	shuttingDown atomic.Bool
+	// Background workers
+	workers     []*worker
+	workersLock sync.Mutex
 }
 
 // NewFoundation creates a new foundation service.
@@ -93,6 +112,7 @@ func NewFoundation(name string, options ...Option) (*Foundation, error) {
 	opts := &FoundationOptions{
 		httpAddr:         config.LookupEnv("FOUNDATION_HTTP_ADDRESS", "0.0.0.0:8080"),
 		grpcAddr:         config.LookupEnv("FOUNDATION_GRPC_ADDRESS", "0.0.0.0:8081"),
+		singlePort:       config.LookupEnv("FOUNDATION_SINGLE_PORT", "false") == "true",
 		httpWriteTimeout: 15 * time.Second,
 		httpReadTimeout:  15 * time.Second,
 		logger:           log.NewNop(),
@@ -100,6 +120,16 @@ func NewFoundation(name string, options ...Option) (*Foundation, error) {
 	for _, o := range options {
 		o(opts)
 	}
+	// the credentials of the environment are only loaded without WithTLS, they watch their files once loaded.
+	if opts.tlsCreds == nil {
+		if conf, ok := mtls.ConfigFromEnv(); ok {
+			creds, err := mtls.New(conf)
+			if err != nil {
+				return nil, errors.Wrap(err, "loading tls credentials")
+			}
+			opts.tlsCreds = creds
+		}
+	}
 
 	// Create the Foundation service
 	return &Foundation{
@@ -118,7 +148,32 @@ func NewFoundation(name string, options ...Option) (*Foundation, error) {
 func (f *Foundation) RegisterService(fn RegisterServiceFunc) {
 	// Create GRPC server only once
 	f.grpcOnce.Do(func() {
-		f.grpcServer = grpckit.NewServer(f.opts.grpcServerOpts...)
+		var serverOpts []grpc.ServerOption
+		// in single port mode, the TLS connections are handled by the HTTP server.
+		if f.opts.tlsCreds != nil && !f.opts.singlePort {
+			serverOpts = append(serverOpts, grpc.Creds(&gatewayCredentials{f.opts.tlsCreds.TransportCredentials()}))
+		}
+		// the calls are limited before being authenticated, so the credentials can't be brute forced.
+		if f.opts.rateLimiter != nil {
+			serverOpts = append(serverOpts,
+				grpc.ChainUnaryInterceptor(ratelimit.UnaryServerInterceptor(f.opts.rateLimiter, f.opts.rateLimitOpts...)),
+				grpc.ChainStreamInterceptor(ratelimit.StreamServerInterceptor(f.opts.rateLimiter, f.opts.rateLimitOpts...)),
+			)
+		}
+		if f.opts.authenticator != nil {
+			serverOpts = append(serverOpts,
+				grpc.ChainUnaryInterceptor(auth.UnaryServerInterceptor(f.opts.authenticator, f.opts.authOpts...)),
+				grpc.ChainStreamInterceptor(auth.StreamServerInterceptor(f.opts.authenticator, f.opts.authOpts...)),
+			)
+		}
+		if f.opts.authorizer != nil {
+			serverOpts = append(serverOpts,
+				grpc.ChainUnaryInterceptor(f.opts.authorizer.UnaryServerInterceptor()),
+				grpc.ChainStreamInterceptor(f.opts.authorizer.StreamServerInterceptor()),
+			)
+		}
+		serverOpts = append(serverOpts, f.opts.grpcServerOpts...)
+		f.grpcServer = grpckit.NewServer(serverOpts...)
 	})
 	fn(f.grpcServer)
 }
@@ -161,6 +216,9 @@ func (f *Foundation) initHTTPServerOnce() {
 			WriteTimeout: opts.httpWriteTimeout,
 			ReadTimeout:  opts.httpReadTimeout,
 		}
+		if opts.tlsCreds != nil {
+			f.httpServer.TLSConfig = opts.tlsCreds.ServerConfig()
+		}
 	})
 
 }
@@ -169,15 +227,33 @@ func (f *Foundation) initHTTPServerOnce() {
 type RegisterServiceHandlerFunc func(gw *runtime.ServeMux, conn *grpc.ClientConn)
 
 // RegisterServiceHandler registers a grpc-gateway service handler.
+//
+// The connection of the grpc-gateway calls the gRPC server in-process, through an in-memory listener,
+// so the calls go through the same interceptors (validation, recovery, tracing, authentication...)
+// as the ones of the network, unlike the generated Register*HandlerServer functions.
 func (f *Foundation) RegisterServiceHandler(fn RegisterServiceHandlerFunc, muxOpts ...runtime.ServeMuxOption) {
 	// Make sure we have an HTTP server setup
 	f.initHTTPServerOnce()
 	// Only create one time the gateway and grpc client
 	f.gwOnce.Do(func() {
 		f.logger.Info(context.Background(), "initializing grpc-gateway")
-		conn, err := grpckit.NewClient(f.opts.grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		f.gwListener = bufconn.Listen(gatewayBufferSize)
+		// the in-memory connections are accepted without TLS by the gRPC server,
+		// the gateway calls don't carry the certificate of the service (see gatewayCredentials).
+		dialOpts := []grpc.DialOption{
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return f.gwListener.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		}
+		conn, err := grpckit.NewClient("passthrough:///grpc-gateway", dialOpts...)
 		if err != nil {
-			f.logger.Error(context.Background(), "fail creating grpc client for grpc-gateway", log.Error(err))
+			// the error is returned by Serve.
+			f.gwErr = errors.Wrap(err, "creating grpc client for grpc-gateway")
+		} else {
+			f.RegisterCloser(PhaseResources, "grpc-gateway-client", func(ctx context.Context) error {
+				return conn.Close()
+			})
 		}
 
 		f.gwClient = conn
@@ -225,7 +301,12 @@ func (f *Foundation) RegisterServiceHandler(fn RegisterServiceHandlerFunc, muxOp
 func (f *Foundation) RegisterHTTPHandler(path string, fn http.HandlerFunc, methods ...string) {
 	// make sure the HTTP server has been initialized
 	f.initHTTPServerOnce()
-	f.httpRouter.HandleFunc(path, fn).Methods(methods...)
+	var handler http.Handler = fn
+	// the gateway calls are limited by the gRPC server, only the custom handlers are limited here.
+	if f.opts.rateLimiter != nil {
+		handler = ratelimit.Middleware(f.opts.rateLimiter, f.opts.rateLimitOpts...)(handler)
+	}
+	f.httpRouter.Handle(path, handler).Methods(methods...)
 }
 
 // RegisterLiveness register a liveness function for /healthz
@@ -262,7 +343,14 @@ func handlerClosure(fn func() (string, error)) http.HandlerFunc {
 }
 
 // Serve configure and start serving request for the foundation service.
+//
+// The logger is flushed once the service is shut down. When an error is returned,
+// the logger is left to the caller, to log the error before closing it.
 func (f *Foundation) Serve() error {
+	if f.gwErr != nil {
+		return f.gwErr
+	}
+
 	_, err := maxprocs.Set(maxprocs.Logger(func(s string, i ...interface{}) {
 		f.logger.Info(context.Background(), fmt.Sprintf(s, i))
 	}))
@@ -275,11 +363,7 @@ func (f *Foundation) Serve() error {
 	if err != nil {
 		return errors.Wrap(err, "creating new tracer")
 	}
-	defer func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		_ = tracer.Shutdown(ctx) //nolint
-	}()
+	f.RegisterCloser(PhaseTelemetry, "tracer", tracer.Shutdown)
 
 	_, err = telemetry.NewMeter(f.name)
 	if err != nil {
@@ -287,72 +371,138 @@ func (f *Foundation) Serve() error {
 	}
 
 	// register health probes and profiling
-	internalHTTP(f.logger, f.readinessProbe, f.livenessProbe)
+	internalHTTP(f.logger, f.readinessHandler(), f.livenessProbe)
 
 	// shutdown channel to listen for an interrupt or terminate signal from the OS.
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
-	// Make a channel to listen for errors coming from the listener. Use a
-	// buffered channel so the goroutine can exit if we don't collect this error.
-	serverError := make(chan error, 1)
+	// start the background workers
+	f.startWorkers()
 
-	// start the grpc server
-	go func(serverError chan error) {
-		// No GRPC server set up.
-		if f.grpcServer == nil {
-			return
-		}
+	// Make a channel to listen for errors coming from the listeners. Use a
+	// buffered channel so the goroutines can exit if we don't collect these errors.
+	serverError := make(chan error, 3)
 
+	// in single port mode, the gRPC server is served by the HTTP server.
+	if f.opts.singlePort && f.grpcServer != nil {
+		f.initHTTPServerOnce()
+	}
+
+	// start the grpc server
+	if f.grpcServer != nil {
 		// enable grpc metrics
 		// This operation needs to be done after user register the proto to the server.
 		grpcprometheus.EnableHandlingTimeHistogram()
 		grpcprometheus.Register(f.grpcServer)
 
-		// Create listener for the grpc server
-		listen, err := net.Listen("tcp", f.opts.grpcAddr)
-		if err != nil {
-			serverError <- errors.Wrap(err, "init net listener")
-		}
-		serverError <- f.grpcServer.Serve(listen)
-		_ = listen.Close() //nolint
-	}(serverError)
+		// in single port mode, the gRPC server is stopped with the HTTP server.
+		if !f.opts.singlePort {
+			f.RegisterCloser(PhaseServers, "grpc-server", f.stopGrpcServer)
 
-	// start the http server
-	go func(serverError chan error) {
-		// No HTTP server set up.
-		if f.httpServer == nil {
-			return
+			go func(serverError chan error) {
+				// Create listener for the grpc server
+				listen, err := net.Listen("tcp", f.opts.grpcAddr)
+				if err != nil {
+					serverError <- errors.Wrap(err, "init net listener")
+					return
+				}
+				serverError <- f.grpcServer.Serve(listen)
+				_ = listen.Close() //nolint
+			}(serverError)
 		}
 
-		// init the http server
-		if f.gw != nil {
-			f.httpRouter.PathPrefix("/").Handler(f.gw)
+		if f.gwListener != nil {
+			go func(serverError chan error) {
+				serverError <- f.grpcServer.Serve(f.gwListener)
+			}(serverError)
 		}
-		serverError <- f.httpServer.ListenAndServe()
-	}(serverError)
+	} else if f.gwListener != nil {
+		// without gRPC server, the grpc-gateway calls fail right away.
+		_ = f.gwListener.Close()
+	}
+
+	// start the http server
+	if f.httpServer != nil {
+		f.RegisterCloser(PhaseServers, "http-server", func(ctx context.Context) error {
+			err := f.httpServer.Shutdown(ctx)
+			if !f.opts.singlePort || f.grpcServer == nil {
+				return err
+			}
+			if err != nil {
+				f.grpcServer.Stop()
+				return err
+			}
+			// the gRPC calls of the HTTP server are completed, only the in-process ones are left.
+			return f.stopGrpcServer(ctx)
+		})
+
+		go func(serverError chan error) {
+			// init the http server
+			f.httpServer.Handler = f.httpHandler()
+			if f.httpServer.TLSConfig != nil {
+				// the certificates are provided by the TLS configuration.
+				serverError <- f.httpServer.ListenAndServeTLS("", "")
+				return
+			}
+			serverError <- f.httpServer.ListenAndServe()
+		}(serverError)
+	}
 
 	f.logger.Debug(context.Background(), "service started", log.String("service-name", f.name))
 
 	select {
 	case err := <-serverError:
+		f.shutdown()
 		return errors.Wrap(err, "server error")
 	case <-shutdown:
+		f.shutdown()
+	}
 
-		// Terminate GRPC server if started
-		if f.grpcServer != nil {
-			f.grpcServer.GracefulStop()
-		}
+	f.logger.Close()
+	return nil
+}
 
-		// terminate the HTTP server if started.
-		if f.httpServer != nil {
-			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-			defer cancel()
-			_ = f.httpServer.Shutdown(ctx) //nolint
-		}
+// stopGrpcServer gracefully stops the gRPC server, or stops it once the context is done.
+func (f *Foundation) stopGrpcServer(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		f.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		// pending RPCs did not complete in time.
+		f.grpcServer.Stop()
+		return ctx.Err()
 	}
+}
 
-	return nil
+// httpHandler returns the handler of the HTTP server, once the services and handlers are registered.
+// In single port mode, the gRPC calls are served by the gRPC server, the other requests by the HTTP router.
+func (f *Foundation) httpHandler() http.Handler {
+	if f.gw != nil {
+		f.httpRouter.PathPrefix("/").Handler(f.gw)
+	}
+	if !f.opts.singlePort {
+		return f.httpRouter
+	}
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.grpcServer != nil && r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			f.grpcServer.ServeHTTP(w, r)
+			return
+		}
+		f.httpRouter.ServeHTTP(w, r)
+	})
+	if f.httpServer.TLSConfig == nil {
+		// the gRPC clients use HTTP/2 without TLS.
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+	return handler
 }
 
 // internalHTTP start a new http server for health checks and profiling.
@@ -0,0 +1,127 @@
+package kit
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	pb "github.com/mukhtarkv/workspace/api/todo/todoapp/v1beta1"
+	"github.com/mukhtarkv/workspace/kit/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// todoServer is a ToDoApp server of a single item.
+type todoServer struct {
+	pb.UnimplementedToDoAppServer
+}
+
+func (todoServer) List(context.Context, *pb.ListRequest) (*pb.ListResponse, error) {
+	return &pb.ListResponse{TodoItems: []*pb.ListResponse_ToDoItem{{Id: "1", Title: "write tests"}}}, nil
+}
+
+// newTodoFoundation creates a Foundation serving the ToDoApp server and its gateway,
+// it returns the number of gRPC calls intercepted.
+func newTodoFoundation(t *testing.T, opts ...Option) (*Foundation, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	opts = append(opts, WithGrpcServerOptions(grpc.ChainUnaryInterceptor(
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls.Add(1)
+			return handler(ctx, req)
+		},
+	)))
+	f, err := NewFoundation("test", opts...)
+	require.NoError(t, err)
+
+	f.RegisterService(func(s *grpc.Server) {
+		pb.RegisterToDoAppServer(s, todoServer{})
+	})
+	f.RegisterServiceHandler(func(gw *runtime.ServeMux, conn *grpc.ClientConn) {
+		require.NoError(t, pb.RegisterToDoAppHandler(context.Background(), gw, conn))
+	})
+	f.RegisterHTTPHandler("/custom", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "custom")
+	}, http.MethodGet)
+	t.Cleanup(func() {
+		f.grpcServer.Stop()
+		_ = f.gwClient.Close()
+	})
+	return f, calls
+}
+
+// get returns the body of the GET request.
+func get(t *testing.T, url string) string {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(b)
+}
+
+// clientFrom returns an HTTP client connecting from the local IP address.
+func clientFrom(ip string) *http.Client {
+	dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(ip)}}
+	return &http.Client{Transport: &http.Transport{DialContext: dialer.DialContext}}
+}
+
+// status returns the status code of the GET request of the client.
+func status(t *testing.T, client *http.Client, url string) int {
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestFoundation_SinglePort(t *testing.T) {
+	// Given a Foundation serving gRPC and HTTP on a single port, limiting the calls per client and method
+	limiter, err := ratelimit.NewTokenBucket(ratelimit.PerMinute(2))
+	require.NoError(t, err)
+	f, calls := newTodoFoundation(t, WithSinglePort(), WithRateLimit(limiter, ratelimit.WithKey(ratelimit.ByPeer, ratelimit.ByMethod)))
+	require.NotNil(t, f.gwListener)
+	go f.grpcServer.Serve(f.gwListener) //nolint:errcheck
+	srv := httptest.NewServer(f.httpHandler())
+	defer srv.Close()
+
+	// When the gRPC server is called over h2c
+	conn, err := grpc.Dial(srv.Listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	resp, err := pb.NewToDoAppClient(conn).List(context.Background(), &pb.ListRequest{})
+
+	// Then it is served by the gRPC server
+	require.NoError(t, err)
+	assert.Equal(t, "write tests", resp.TodoItems[0].Title)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// When the grpc-gateway is called
+	body := get(t, srv.URL+"/todos")
+
+	// Then the gRPC server is called in-process, through the interceptors
+	assert.Contains(t, body, `"title":"write tests"`)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// When a custom handler is called
+	body = get(t, srv.URL+"/custom")
+
+	// Then it is served by the HTTP router
+	assert.Equal(t, "custom", body)
+
+	// When the grpc-gateway is called by 2 clients
+	alice, bob := clientFrom("127.0.0.2"), clientFrom("127.0.0.3")
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, status(t, alice, srv.URL+"/todos"))
+	}
+
+	// Then the in-process calls are limited per client address
+	assert.Equal(t, http.StatusTooManyRequests, status(t, alice, srv.URL+"/todos"))
+	assert.Equal(t, http.StatusOK, status(t, bob, srv.URL+"/todos"))
+}
@@ -0,0 +1,42 @@
+package kit
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// enforce the gatewayCredentials to implement the credentials.TransportCredentials interface.
+var _ credentials.TransportCredentials = (*gatewayCredentials)(nil)
+
+// gatewayCredentials are the transport credentials of the gRPC server, the in-memory connections
+// of the grpc-gateway being accepted without TLS.
+//
+// The gateway calls then carry no peer certificate: they are authenticated by the credentials
+// of the HTTP callers, never by the identity of the service itself.
+// The in-memory connections can't be opened from the network.
+type gatewayCredentials struct {
+	credentials.TransportCredentials
+}
+
+func (g *gatewayCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if isGatewayConn(conn) {
+		return insecure.NewCredentials().ServerHandshake(conn)
+	}
+	return g.TransportCredentials.ServerHandshake(conn)
+}
+
+func (g *gatewayCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return g.TransportCredentials.ClientHandshake(ctx, authority, conn)
+}
+
+func (g *gatewayCredentials) Clone() credentials.TransportCredentials {
+	return &gatewayCredentials{TransportCredentials: g.TransportCredentials.Clone()}
+}
+
+// isGatewayConn reports whether the connection is an in-memory connection of the grpc-gateway.
+func isGatewayConn(conn net.Conn) bool {
+	return conn.RemoteAddr().Network() == "bufconn"
+}
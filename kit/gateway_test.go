@@ -0,0 +1,73 @@
+package kit
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/mukhtarkv/workspace/kit/mtls"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// handshakeCredentials records the server handshakes.
+type handshakeCredentials struct {
+	credentials.TransportCredentials
+	handshakes int
+}
+
+func (h *handshakeCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	h.handshakes++
+	return conn, nil, nil
+}
+
+func TestGatewayCredentials(t *testing.T) {
+	creds := &handshakeCredentials{}
+	g := &gatewayCredentials{creds}
+
+	// Given an in-memory connection of the grpc-gateway
+	l := bufconn.Listen(1 << 10)
+	defer l.Close()
+	go func() {
+		conn, err := l.DialContext(context.Background())
+		if err == nil {
+			defer conn.Close()
+		}
+	}()
+	conn, err := l.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// When
+	_, info, err := g.ServerHandshake(conn)
+
+	// Then it is accepted without TLS
+	assert.NoError(t, err)
+	assert.Equal(t, "insecure", info.AuthType())
+	assert.Equal(t, 0, creds.handshakes)
+
+	// the network connections use the credentials of the server.
+	server, client := net.Pipe()
+	defer client.Close()
+	_, _, err = g.ServerHandshake(server)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, creds.handshakes)
+}
+
+func TestNewFoundationTLSFromEnv(t *testing.T) {
+	// Given the credentials files of the environment can't be loaded
+	t.Setenv("FOUNDATION_TLS_CERT_FILE", "/nonexistent/tls.crt")
+	t.Setenv("FOUNDATION_TLS_KEY_FILE", "/nonexistent/tls.key")
+
+	_, err := NewFoundation("test")
+	assert.Error(t, err)
+
+	// When the credentials are given
+	creds := &mtls.Credentials{}
+	f, err := NewFoundation("test", WithTLS(creds))
+
+	// Then the files of the environment are not loaded
+	assert.NoError(t, err)
+	assert.Same(t, creds, f.opts.tlsCreds)
+}
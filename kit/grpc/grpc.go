@@ -9,6 +9,7 @@
 	grpcvalidator "github.com/grpc-ecosystem/go-grpc-middleware/validator"
 	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/mukhtarkv/workspace/kit/log"
+	"github.com/mukhtarkv/workspace/kit/mtls"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -53,6 +54,10 @@ func NewServer(opts ...grpc.ServerOption) *grpc.Server {
 // linear backoff with 10% jitter.
 //
 // See: https://pkg.go.dev/github.com/grpc-ecosystem/go-grpc-middleware/retry
+//
+// The connection is secured with the `grpc.WithTransportCredentials` option, e.g. with TLS or mutual TLS:
+//
+//	grpckit.NewClient("other:8081", grpckit.WithTLS(creds))
 func NewClient(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	// Create a default dial opts and set our default chain of interceptor
 	// if user decide to pass a custom interceptor via `grpc.WithChainXXXInterceptor` or grpc.XXXInterceptor,
@@ -75,6 +80,12 @@ func NewClient(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	return grpc.Dial(addr, dialOps...)
 }
 
+// WithTLS secures the connection with the credentials, presenting their certificate to the server
+// and verifying the server certificate against their certificate authorities.
+func WithTLS(creds *mtls.Credentials) grpc.DialOption {
+	return grpc.WithTransportCredentials(creds.TransportCredentials())
+}
+
 // WithMaxRetries sets the maximum number of retries on this call, or this interceptor.
 func WithMaxRetries(maxRetries uint) grpcretry.CallOption {
 	return grpcretry.WithMax(maxRetries)
@@ -0,0 +1,95 @@
+// Package lock defines the distributed locks used to coordinate the replicas of a service.
+//
+// Implementations are provided by kit/cache/redis (Locker) and kit/sql (AdvisoryLocker).
+//
+//	l, err := locker.TryLock(ctx, "purge-todos", time.Minute)
+//	if errors.Is(err, lock.ErrNotAcquired) {
+//		// another replica holds the lock
+//	}
+//	defer l.Unlock(ctx)
+//
+// Locks are leases, they expire unless extended. A process paused longer than the lease
+// can keep believing it holds the lock: the fencing token of the lock should be passed along
+// to the protected resource, which rejects the writes carrying a token lower than the last one seen.
+package lock
+
+import (
+	"context"
+	"time"
+)
+
+// Lock errors.
+const (
+	ErrNotAcquired = Error("lock not acquired")
+	ErrNotHeld     = Error("lock not held")
+)
+
+// Error represents a lock error.
+type Error string
+
+// Error returns the error message.
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Locker acquires distributed locks.
+type Locker interface {
+	// TryLock acquires the lock identified by the key for the ttl duration, without waiting.
+	// If the lock is held by someone else, ErrNotAcquired is returned.
+	// The lock is released when the ttl expires or Unlock is called, whichever comes first.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (Lock, error)
+}
+
+// ScopedLocker is a Locker whose locks can be scoped, such as to the activation of a scheduled job.
+// The locks of the scopes of a key are independent, but their fencing tokens are drawn from
+// the same counter, so they increase across the scopes.
+type ScopedLocker interface {
+	Locker
+
+	// TryLockScoped acquires the lock identified by the key and the scope for the ttl duration, without waiting.
+	// If the lock is held by someone else, ErrNotAcquired is returned.
+	TryLockScoped(ctx context.Context, key, scope string, ttl time.Duration) (Lock, error)
+}
+
+// Lock is an acquired lock.
+type Lock interface {
+	// Token returns the fencing token of the lock,
+	// it is greater than the tokens of all the previous holders of the lock.
+	Token() int64
+
+	// Extend renews the lease of the lock for the ttl duration.
+	// If the lock expired or was released, ErrNotHeld is returned.
+	Extend(ctx context.Context, ttl time.Duration) error
+
+	// Unlock releases the lock if it is still held.
+	Unlock(ctx context.Context) error
+}
+
+// KeepAlive extends the lock every third of the ttl until the returned context is canceled.
+// The returned context is canceled as soon as extending the lock fails, so the work protected by the lock stops:
+//
+//	ctx, cancel := lock.KeepAlive(ctx, l, 30*time.Second)
+//	defer cancel()
+//	err := process(ctx)
+func KeepAlive(ctx context.Context, l Lock, ttl time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := l.Extend(ctx, ttl); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, cancel
+}
@@ -0,0 +1,57 @@
+package lock
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLock is a Lock failing to extend after a number of extensions.
+type fakeLock struct {
+	extensions atomic.Int32
+	max        int32
+}
+
+func (l *fakeLock) Token() int64 {
+	return 1
+}
+
+func (l *fakeLock) Extend(ctx context.Context, ttl time.Duration) error {
+	if l.extensions.Add(1) > l.max {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+func (l *fakeLock) Unlock(ctx context.Context) error {
+	return nil
+}
+
+func TestKeepAlive(t *testing.T) {
+	l := &fakeLock{max: 2}
+
+	ctx, cancel := KeepAlive(context.Background(), l, 30*time.Millisecond)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		assert.Fail(t, "context not canceled when extending the lock failed")
+	}
+	assert.EqualValues(t, 3, l.extensions.Load())
+}
+
+func TestKeepAliveCancel(t *testing.T) {
+	l := &fakeLock{max: 100}
+
+	ctx, cancel := KeepAlive(context.Background(), l, 30*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	<-ctx.Done()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Greater(t, l.extensions.Load(), int32(0))
+}
@@ -0,0 +1,18 @@
+package log
+
+import "context"
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the logger.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger of the context, or the global Logger when it has none.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return L()
+}
@@ -13,7 +13,8 @@
 // A Logger provides fast, leveled, structured logging.
 // All methods are safe for concurrent use.
 type Logger struct {
-	log *zap.Logger
+	log    *zap.Logger
+	fields []Field
 }
 
 // New is a reasonable production logging configuration.
@@ -82,28 +83,39 @@ func (l *Logger) Close() {
 	_ = l.log.Sync() //nolint
 }
 
+// With returns a Logger adding the fields to the ones of the logger on every message.
+func (l *Logger) With(fields ...Field) *Logger {
+	accumulated := make([]Field, 0, len(l.fields)+len(fields))
+	accumulated = append(accumulated, l.fields...)
+	accumulated = append(accumulated, fields...)
+	return &Logger{
+		log:    l.log,
+		fields: accumulated,
+	}
+}
+
 // Debug logs a message at DebugLevel. The message includes any fields passed
 // at the log site, as well as any fields accumulated on the logger.
 func (l *Logger) Debug(ctx context.Context, message string, fields ...Field) {
-	log(l.log.Debug, ctx, message, fields...)
+	log(l.log.Debug, ctx, message, l.with(fields)...)
 }
 
 // Info logs a message at InfoLevel. The message includes any fields passed
 // at the log site, as well as any fields accumulated on the logger.
 func (l *Logger) Info(ctx context.Context, message string, fields ...Field) {
-	log(l.log.Info, ctx, message, fields...)
+	log(l.log.Info, ctx, message, l.with(fields)...)
 }
 
 // Warn logs a message at WarnLevel. The message includes any fields passed
 // at the log site, as well as any fields accumulated on the logger.
 func (l *Logger) Warn(ctx context.Context, message string, fields ...Field) {
-	log(l.log.Warn, ctx, message, fields...)
+	log(l.log.Warn, ctx, message, l.with(fields)...)
 }
 
 // Error logs a message at ErrorLevel. The message includes any fields passed
 // at the log site, as well as any fields accumulated on the logger.
 func (l *Logger) Error(ctx context.Context, message string, fields ...Field) {
-	log(l.log.Error, ctx, message, fields...)
+	log(l.log.Error, ctx, message, l.with(fields)...)
 }
 
 // Fatal logs a message at FatalLevel. The message includes any fields passed
@@ -112,7 +124,15 @@ func (l *Logger) Error(ctx context.Context, message string, fields ...Field) {
 // The logger then calls os.Exit(1), even if logging at FatalLevel is
 // disabled.
 func (l *Logger) Fatal(ctx context.Context, message string, fields ...Field) {
-	log(l.log.Fatal, ctx, message, fields...)
+	log(l.log.Fatal, ctx, message, l.with(fields)...)
+}
+
+// with returns the fields of the logger followed by the given ones.
+func (l *Logger) with(fields []Field) []Field {
+	if len(l.fields) == 0 {
+		return fields
+	}
+	return append(append([]Field{}, l.fields...), fields...)
 }
 
 func log(fn func(msg string, fields ...Field), ctx context.Context, msg string, fields ...Field) { //nolint
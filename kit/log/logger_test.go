@@ -0,0 +1,36 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLoggerWithContext(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := &Logger{log: zap.New(core)}
+
+	// Given a logger tagged with a field in the context
+	ctx := NewContext(context.Background(), l.With(String("worker", "consumer")))
+
+	// When
+	FromContext(ctx).Info(ctx, "started", Int("attempt", 1))
+
+	// Then the message has the fields of the logger and of the call
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	attributes := entries[0].ContextMap()["Attributes"].(map[string]interface{})
+	assert.Equal(t, "consumer", attributes["worker"])
+	assert.EqualValues(t, 1, attributes["attempt"])
+
+	// the logger itself is left untagged.
+	l.Info(ctx, "untagged")
+	assert.NotContains(t, logs.All()[1].ContextMap()["Attributes"], "worker")
+
+	// without logger, the context returns the global logger.
+	assert.Same(t, L(), FromContext(context.Background()))
+}
@@ -0,0 +1,62 @@
+package mtls
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// enforce the transportCredentials to implement the credentials.TransportCredentials interface.
+var _ credentials.TransportCredentials = (*transportCredentials)(nil)
+
+// transportCredentials are the gRPC transport credentials of Credentials.
+type transportCredentials struct {
+	creds      *Credentials
+	serverName string
+}
+
+// TransportCredentials returns the gRPC transport credentials of the servers and clients:
+//
+//	grpc.NewServer(grpc.Creds(creds.TransportCredentials()))
+//	grpc.Dial("other:8081", grpc.WithTransportCredentials(creds.TransportCredentials()))
+//
+// The clients verify the server certificate against the dialed host, or the authority of grpc.WithAuthority.
+func (c *Credentials) TransportCredentials() credentials.TransportCredentials {
+	return &transportCredentials{creds: c}
+}
+
+func (t *transportCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	serverName := t.serverName
+	if len(serverName) == 0 {
+		serverName = authority
+		if host, _, err := net.SplitHostPort(authority); err == nil {
+			serverName = host
+		}
+	}
+	return credentials.NewTLS(t.creds.ClientConfig(serverName)).ClientHandshake(ctx, authority, conn)
+}
+
+func (t *transportCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return credentials.NewTLS(t.creds.ServerConfig()).ServerHandshake(conn)
+}
+
+func (t *transportCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{
+		SecurityProtocol: "tls",
+		SecurityVersion:  "1.2",
+		ServerName:       t.serverName,
+	}
+}
+
+func (t *transportCredentials) Clone() credentials.TransportCredentials {
+	return &transportCredentials{creds: t.creds, serverName: t.serverName}
+}
+
+// OverrideServerName overrides the server name verified by the clients.
+//
+// Deprecated: use grpc.WithAuthority instead.
+func (t *transportCredentials) OverrideServerName(serverName string) error {
+	t.serverName = serverName
+	return nil
+}
@@ -0,0 +1,91 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/mukhtarkv/workspace/kit/auth"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// enforce the Authenticator to implement the auth.Authenticator interface.
+var _ auth.Authenticator = (*Authenticator)(nil)
+
+// Identity is the identity of a verified peer certificate.
+type Identity struct {
+	// CommonName is the common name of the certificate subject.
+	CommonName string
+	// DNSNames are the DNS names of the certificate.
+	DNSNames []string
+	// URIs are the URIs of the certificate, such as a SPIFFE ID.
+	URIs []string
+}
+
+// Name returns the first URI of the identity, or its common name.
+func (i *Identity) Name() string {
+	if len(i.URIs) > 0 {
+		return i.URIs[0]
+	}
+	return i.CommonName
+}
+
+// identity returns the identity of the first verified chain.
+func identity(chains [][]*x509.Certificate) (*Identity, bool) {
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil, false
+	}
+	cert := chains[0][0]
+	i := &Identity{
+		CommonName: cert.Subject.CommonName,
+		DNSNames:   cert.DNSNames,
+	}
+	for _, uri := range cert.URIs {
+		i.URIs = append(i.URIs, uri.String())
+	}
+	return i, true
+}
+
+// PeerIdentity returns the identity of the verified client certificate of the gRPC call.
+func PeerIdentity(ctx context.Context) (*Identity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, false
+	}
+	return identity(info.State.VerifiedChains)
+}
+
+// RequestIdentity returns the identity of the verified client certificate of the HTTP request.
+func RequestIdentity(r *http.Request) (*Identity, bool) {
+	if r.TLS == nil {
+		return nil, false
+	}
+	return identity(r.TLS.VerifiedChains)
+}
+
+// Authenticator authenticates the callers by their verified client certificate,
+// the subject of their principal being the name of their identity.
+//
+// The calls of the grpc-gateway of kit.Foundation carry no client certificate, the gateway callers
+// must be authenticated by their credentials too:
+//
+//	auth.Authenticators{jwtAuthenticator, mtls.Authenticator{}}
+type Authenticator struct{}
+
+// Authenticate returns the principal of the client certificate of the call.
+func (Authenticator) Authenticate(ctx context.Context, _ metadata.MD) (*auth.Principal, error) {
+	i, ok := PeerIdentity(ctx)
+	if !ok {
+		return nil, auth.ErrNoCredentials
+	}
+	return &auth.Principal{
+		Subject: i.Name(),
+		Method:  auth.MethodCertificate,
+	}, nil
+}
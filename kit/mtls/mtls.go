@@ -0,0 +1,256 @@
+// Package mtls provides the TLS and mutual TLS credentials of the servers and clients,
+// loaded from PEM files and reloaded when the files are rotated, such as by cert-manager.
+//
+//	creds, err := mtls.New(mtls.Config{
+//		CertFile:          "/etc/tls/tls.crt",
+//		KeyFile:           "/etc/tls/tls.key",
+//		CAFile:            "/etc/tls/ca.crt",
+//		RequireClientCert: true,
+//	})
+//	f, err := kit.NewFoundation("myservice", kit.WithTLS(creds))
+//	conn, err := grpckit.NewClient("other:8081", grpckit.WithTLS(creds))
+//
+// The gRPC servers and clients use the credentials with TransportCredentials.
+//
+// The identity of the peer certificate is read from the context of the gRPC calls with PeerIdentity,
+// and Authenticator makes it the auth.Principal of the calls.
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/config"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/log"
+)
+
+// Config represents the TLS configuration, it can be loaded with config.From:
+//
+//	tls:
+//	  certFile: /etc/tls/tls.crt
+//	  keyFile: /etc/tls/tls.key
+//	  caFile: /etc/tls/ca.crt
+//	  requireClientCert: true
+type Config struct {
+	// CertFile and KeyFile are the PEM files of the certificate presented to the peers.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// CAFile is the PEM file of the certificate authorities verifying the peers, the system ones by default.
+	CAFile string `yaml:"caFile"`
+	// RequireClientCert requires and verifies the client certificates, for mutual TLS.
+	RequireClientCert bool `yaml:"requireClientCert"`
+	// ReloadInterval is how often the files are checked for rotation, 1 minute by default.
+	ReloadInterval time.Duration `yaml:"reloadInterval"`
+}
+
+// ConfigFromEnv returns the configuration of the environment variables FOUNDATION_TLS_CERT_FILE,
+// FOUNDATION_TLS_KEY_FILE, FOUNDATION_TLS_CA_FILE and FOUNDATION_TLS_REQUIRE_CLIENT_CERT.
+// It returns false when no certificate file is defined.
+func ConfigFromEnv() (Config, bool) {
+	c := Config{
+		CertFile:          config.LookupEnv("FOUNDATION_TLS_CERT_FILE", ""),
+		KeyFile:           config.LookupEnv("FOUNDATION_TLS_KEY_FILE", ""),
+		CAFile:            config.LookupEnv("FOUNDATION_TLS_CA_FILE", ""),
+		RequireClientCert: config.LookupEnv("FOUNDATION_TLS_REQUIRE_CLIENT_CERT", "false") == "true",
+	}
+	return c, len(c.CertFile) > 0
+}
+
+// Credentials provides the TLS configurations of the servers and clients,
+// using the current certificate and certificate authorities of the files.
+//
+// The files are checked for rotation during the handshakes, at most once per reload interval.
+// If the rotated files can't be loaded, the previous certificates are kept.
+type Credentials struct {
+	conf Config
+	now  func() time.Time
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	leaf    *x509.Certificate
+	pool    *x509.CertPool
+	modTime time.Time
+	checked time.Time
+}
+
+// New creates the Credentials of the configuration.
+func New(conf Config) (*Credentials, error) {
+	if len(conf.CertFile) == 0 || len(conf.KeyFile) == 0 {
+		return nil, errors.New("tls certificate and key files are required")
+	}
+	if conf.RequireClientCert && len(conf.CAFile) == 0 {
+		return nil, errors.New("tls ca file is required to verify the client certificates")
+	}
+	if conf.ReloadInterval <= 0 {
+		conf.ReloadInterval = time.Minute
+	}
+
+	c := &Credentials{conf: conf, now: time.Now}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	c.checked = c.now()
+	return c, nil
+}
+
+// load loads the files.
+func (c *Credentials) load() error {
+	modTime, err := c.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.conf.CertFile, c.conf.KeyFile)
+	if err != nil {
+		return errors.Wrap(err, "loading tls certificate")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return errors.Wrap(err, "parsing tls certificate")
+	}
+
+	var pool *x509.CertPool
+	if len(c.conf.CAFile) > 0 {
+		b, err := os.ReadFile(c.conf.CAFile)
+		if err != nil {
+			return errors.Wrapf(err, "reading tls ca file '%s'", c.conf.CAFile)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return errors.Newf("no certificate found in tls ca file '%s'", c.conf.CAFile)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert, c.leaf, c.pool, c.modTime = &cert, leaf, pool, modTime
+	return nil
+}
+
+// lastModified returns the last modification time of the files.
+func (c *Credentials) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, path := range []string{c.conf.CertFile, c.conf.KeyFile, c.conf.CAFile} {
+		if len(path) == 0 {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(err, "reading tls file '%s'", path)
+		}
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last, nil
+}
+
+// reload reloads the files if they changed, at most once per reload interval.
+func (c *Credentials) reload() {
+	now := c.now()
+	c.mu.Lock()
+	if now.Sub(c.checked) < c.conf.ReloadInterval {
+		c.mu.Unlock()
+		return
+	}
+	c.checked = now
+	previous := c.modTime
+	c.mu.Unlock()
+
+	modTime, err := c.lastModified()
+	if err == nil && modTime.Equal(previous) {
+		return
+	}
+	if err == nil {
+		err = c.load()
+	}
+	if err != nil {
+		log.L().Error(context.Background(), "fail reloading tls certificates", log.Error(err))
+		return
+	}
+	log.L().Info(context.Background(), "tls certificates reloaded")
+}
+
+// current returns the current certificate, its leaf and the certificate authorities.
+func (c *Credentials) current() (*tls.Certificate, *x509.Certificate, *x509.CertPool) {
+	c.reload()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, c.leaf, c.pool
+}
+
+// ServerName returns the first DNS name of the certificate, or its common name,
+// the name the clients of the service are expected to verify.
+func (c *Credentials) ServerName() string {
+	_, leaf, _ := c.current()
+	if len(leaf.DNSNames) > 0 {
+		return leaf.DNSNames[0]
+	}
+	return leaf.Subject.CommonName
+}
+
+// ServerConfig returns the TLS configuration of the servers.
+// The client certificates are required and verified when RequireClientCert is set.
+func (c *Credentials) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, _, pool := c.current()
+			conf := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			if c.conf.RequireClientCert {
+				conf.ClientAuth = tls.RequireAndVerifyClientCert
+				conf.ClientCAs = pool
+			}
+			return conf, nil
+		},
+	}
+}
+
+// ClientConfig returns the TLS configuration of the clients, presenting the certificate
+// and verifying the server certificate against the certificate authorities.
+// The server name verified is the dialed host, or the serverName when the host is an IP address.
+func (c *Credentials) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _, _ := c.current()
+			return cert, nil
+		},
+		// the server certificate is verified by VerifyConnection, against the reloaded certificate authorities.
+		InsecureSkipVerify: true, //nolint:gosec
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("no server certificate")
+			}
+			name := cs.ServerName
+			if len(name) == 0 {
+				// the IP addresses are not sent, nor returned, as server name.
+				name = serverName
+			}
+			if len(name) == 0 {
+				return errors.New("no server name to verify")
+			}
+			_, _, pool := c.current()
+			intermediates := x509.NewCertPool()
+			for _, cert := range cs.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+				Roots:         pool,
+				Intermediates: intermediates,
+				DNSName:       name,
+			})
+			return err
+		},
+	}
+}
@@ -0,0 +1,261 @@
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// authority is a test certificate authority.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes the certificate and key of the common name and URIs in the directory.
+func (a *authority) issue(t *testing.T, dir, cn string, uris ...string) Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		tmpl.URIs = append(tmpl.URIs, u)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	conf := Config{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	require.NoError(t, os.WriteFile(conf.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(conf.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.WriteFile(conf.CAFile, a.pem, 0o600))
+	return conf
+}
+
+// healthServer is a health server recording the identity of the callers.
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	identity  *Identity
+	principal *auth.Principal
+}
+
+func (s *healthServer) Check(ctx context.Context, _ *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	s.identity, _ = PeerIdentity(ctx)
+	s.principal, _ = Authenticator{}.Authenticate(ctx, nil)
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+// serve serves the health server with the credentials, it returns its address.
+func serve(t *testing.T, creds *Credentials, health *healthServer) string {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer(grpc.Creds(creds.TransportCredentials()))
+	grpc_health_v1.RegisterHealthServer(srv, health)
+	go srv.Serve(lis) //nolint:errcheck
+	t.Cleanup(srv.Stop)
+	return net.JoinHostPort("localhost", strconv.Itoa(lis.Addr().(*net.TCPAddr).Port))
+}
+
+// check calls the health server with the transport credentials.
+func check(addr string, creds credentials.TransportCredentials, opts ...grpc.DialOption) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, append(opts, grpc.WithTransportCredentials(creds))...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	return err
+}
+
+func TestNew(t *testing.T) {
+	ca := newAuthority(t)
+	conf := ca.issue(t, t.TempDir(), "localhost")
+
+	_, err := New(Config{CertFile: conf.CertFile})
+	assert.Error(t, err)
+	_, err = New(Config{CertFile: conf.CertFile, KeyFile: conf.KeyFile, RequireClientCert: true})
+	assert.Error(t, err)
+	_, err = New(Config{CertFile: conf.CertFile, KeyFile: filepath.Join(t.TempDir(), "missing.key")})
+	assert.Error(t, err)
+
+	creds, err := New(conf)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", creds.ServerName())
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	_, ok := ConfigFromEnv()
+	assert.False(t, ok)
+
+	t.Setenv("FOUNDATION_TLS_CERT_FILE", "/etc/tls/tls.crt")
+	t.Setenv("FOUNDATION_TLS_KEY_FILE", "/etc/tls/tls.key")
+	t.Setenv("FOUNDATION_TLS_CA_FILE", "/etc/tls/ca.crt")
+	t.Setenv("FOUNDATION_TLS_REQUIRE_CLIENT_CERT", "true")
+	conf, ok := ConfigFromEnv()
+	assert.True(t, ok)
+	assert.Equal(t, Config{
+		CertFile:          "/etc/tls/tls.crt",
+		KeyFile:           "/etc/tls/tls.key",
+		CAFile:            "/etc/tls/ca.crt",
+		RequireClientCert: true,
+	}, conf)
+}
+
+func TestCredentials_MutualTLS(t *testing.T) {
+	// Given a server requiring the client certificates
+	ca := newAuthority(t)
+	serverConf := ca.issue(t, t.TempDir(), "localhost")
+	serverConf.RequireClientCert = true
+	serverCreds, err := New(serverConf)
+	require.NoError(t, err)
+	health := &healthServer{}
+	addr := serve(t, serverCreds, health)
+
+	// When a client presents its certificate
+	clientCreds, err := New(ca.issue(t, t.TempDir(), "billing", "spiffe://example.org/billing"))
+	require.NoError(t, err)
+	err = check(addr, clientCreds.TransportCredentials())
+
+	// Then the call is authenticated by the certificate
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{
+		CommonName: "billing",
+		DNSNames:   []string{"billing"},
+		URIs:       []string{"spiffe://example.org/billing"},
+	}, health.identity)
+	assert.Equal(t, &auth.Principal{Subject: "spiffe://example.org/billing", Method: auth.MethodCertificate}, health.principal)
+
+	// When a client dials the IP address of the server with its name as authority
+	_, port, _ := net.SplitHostPort(addr)
+	err = check(net.JoinHostPort("127.0.0.1", port), clientCreds.TransportCredentials(), grpc.WithAuthority("localhost"))
+
+	// Then the server certificate is verified against its name
+	assert.NoError(t, err)
+	err = check(net.JoinHostPort("127.0.0.1", port), clientCreds.TransportCredentials())
+	assert.Error(t, err)
+
+	// When a client does not present a certificate
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	err = check(addr, credentials.NewTLS(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}))
+
+	// Then the call is rejected
+	assert.Error(t, err)
+
+	// When the server certificate is not issued by the client authorities
+	otherCreds, err := New(newAuthority(t).issue(t, t.TempDir(), "billing"))
+	require.NoError(t, err)
+	err = check(addr, otherCreds.TransportCredentials())
+
+	// Then the call is rejected
+	assert.Error(t, err)
+}
+
+func TestCredentials_TLS(t *testing.T) {
+	// Given a server not requiring the client certificates
+	ca := newAuthority(t)
+	serverCreds, err := New(ca.issue(t, t.TempDir(), "localhost"))
+	require.NoError(t, err)
+	health := &healthServer{}
+	addr := serve(t, serverCreds, health)
+
+	// When a client only verifies the server certificate
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	err = check(addr, credentials.NewTLS(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}))
+
+	// Then the call has no peer identity
+	require.NoError(t, err)
+	assert.Nil(t, health.identity)
+	assert.Nil(t, health.principal)
+}
+
+func TestCredentials_Reload(t *testing.T) {
+	// Given credentials of certificate files
+	ca := newAuthority(t)
+	dir := t.TempDir()
+	creds, err := New(ca.issue(t, dir, "localhost"))
+	require.NoError(t, err)
+	now := time.Now()
+	creds.now = func() time.Time { return now }
+	cert, _, _ := creds.current()
+
+	// When the files are rotated
+	ca.issue(t, dir, "other.localhost")
+	future := time.Now().Add(time.Hour)
+	for _, path := range []string{creds.conf.CertFile, creds.conf.KeyFile, creds.conf.CAFile} {
+		require.NoError(t, os.Chtimes(path, future, future))
+	}
+
+	// Then they are reloaded once the reload interval elapsed
+	assert.Equal(t, "localhost", creds.ServerName())
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, "other.localhost", creds.ServerName())
+	reloaded, _, _ := creds.current()
+	assert.NotEqual(t, cert.Certificate, reloaded.Certificate)
+
+	// When the rotated files are invalid
+	require.NoError(t, os.WriteFile(creds.conf.CertFile, []byte("invalid"), 0o600))
+	future = future.Add(time.Hour)
+	require.NoError(t, os.Chtimes(creds.conf.CertFile, future, future))
+	now = now.Add(2 * time.Minute)
+
+	// Then the previous certificates are kept
+	assert.Equal(t, "other.localhost", creds.ServerName())
+}
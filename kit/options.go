@@ -3,7 +3,11 @@
 import (
 	"time"
 
+	"github.com/mukhtarkv/workspace/kit/auth"
+	"github.com/mukhtarkv/workspace/kit/authz"
 	"github.com/mukhtarkv/workspace/kit/log"
+	"github.com/mukhtarkv/workspace/kit/mtls"
+	"github.com/mukhtarkv/workspace/kit/ratelimit"
 	"github.com/rs/cors"
 	"google.golang.org/grpc"
 )
@@ -18,6 +22,32 @@ type FoundationOptions struct {
 	httpWriteTimeout time.Duration
 	httpReadTimeout  time.Duration
 	logger           *log.Logger
+	shutdownDelay    time.Duration
+	shutdownTimeouts map[ShutdownPhase]time.Duration
+	rateLimiter      ratelimit.Limiter
+	rateLimitOpts    []ratelimit.Option
+	authenticator    auth.Authenticator
+	authOpts         []auth.Option
+	authorizer       *authz.Authorizer
+	tlsCreds         *mtls.Credentials
+	singlePort       bool
+}
+
+// defaultShutdownTimeouts are the default timeouts of the shutdown phases.
+var defaultShutdownTimeouts = map[ShutdownPhase]time.Duration{
+	PhaseReadiness: 5 * time.Second,
+	PhaseDrain:     15 * time.Second,
+	PhaseServers:   15 * time.Second,
+	PhaseResources: 10 * time.Second,
+	PhaseTelemetry: 5 * time.Second,
+}
+
+// shutdownTimeout returns the timeout of the given shutdown phase.
+func (fo *FoundationOptions) shutdownTimeout(phase ShutdownPhase) time.Duration {
+	if timeout, ok := fo.shutdownTimeouts[phase]; ok {
+		return timeout
+	}
+	return defaultShutdownTimeouts[phase]
 }
 
 // Option defines a Foundation option.
@@ -77,3 +107,100 @@ func WithLogger(logger *log.Logger) Option {
 		fo.logger = logger
 	}
 }
+
+// WithShutdownTimeout defines the timeout of a shutdown phase.
+//
+// Timeouts default to 5s for readiness, 15s for drain, 15s for servers, 10s for resources and 5s for telemetry.
+func WithShutdownTimeout(phase ShutdownPhase, timeout time.Duration) Option {
+	return func(fo *FoundationOptions) {
+		if timeout <= 0 {
+			return
+		}
+		if fo.shutdownTimeouts == nil {
+			fo.shutdownTimeouts = map[ShutdownPhase]time.Duration{}
+		}
+		fo.shutdownTimeouts[phase] = timeout
+	}
+}
+
+// WithShutdownDelay defines how long the Foundation waits, once /readyz started failing,
+// before draining and stopping the servers. It gives time to the load balancers to stop
+// sending new traffic.
+//
+// ShutdownDelay defaults to 0.
+func WithShutdownDelay(delay time.Duration) Option {
+	return func(fo *FoundationOptions) {
+		fo.shutdownDelay = delay
+	}
+}
+
+// WithRateLimit limits the rate of the gRPC calls, including the ones of the grpc-gateway,
+// and of the custom HTTP handlers. The requests over the limit are rejected with ResourceExhausted,
+// or the 429 status, and the retry-after metadata.
+//
+//	limiter, err := ratelimit.NewSlidingWindow(ratelimit.PerMinute(600))
+//	kit.NewFoundation("myservice", kit.WithRateLimit(limiter, ratelimit.WithKey(ratelimit.ByPeer, ratelimit.ByMethod)))
+//
+// Rate limiting is disabled by default.
+func WithRateLimit(limiter ratelimit.Limiter, opts ...ratelimit.Option) Option {
+	return func(fo *FoundationOptions) {
+		fo.rateLimiter = limiter
+		fo.rateLimitOpts = opts
+	}
+}
+
+// WithAuth authenticates the gRPC calls, including the ones of the grpc-gateway, with the authenticator.
+// The principal is put in the context of the calls, the calls without valid credentials are rejected
+// with Unauthenticated, unless their method is exempt (see auth.WithExemptMethods).
+//
+//	a, err := authConfig.Authenticator(ctx)
+//	kit.NewFoundation("myservice", kit.WithAuth(a, authConfig.Options()...))
+//
+// Authentication is disabled by default.
+func WithAuth(authenticator auth.Authenticator, opts ...auth.Option) Option {
+	return func(fo *FoundationOptions) {
+		fo.authenticator = authenticator
+		fo.authOpts = opts
+	}
+}
+
+// WithAuthorization authorizes the gRPC calls, including the ones of the grpc-gateway, against the policy
+// of the authorizer, once authenticated (see WithAuth). The denied calls are rejected with PermissionDenied.
+//
+//	policy, err := authz.LoadPolicy("/etc/app/policy.yaml")
+//	authorizer, err := authz.New(policy)
+//	kit.NewFoundation("myservice", kit.WithAuth(a), kit.WithAuthorization(authorizer))
+//
+// Authorization is disabled by default.
+func WithAuthorization(authorizer *authz.Authorizer) Option {
+	return func(fo *FoundationOptions) {
+		fo.authorizer = authorizer
+	}
+}
+
+// WithTLS serves the gRPC and HTTP servers over TLS, or mutual TLS when the credentials require
+// the client certificates. The grpc-gateway calls the gRPC server in-process without TLS,
+// they carry no client certificate and are authenticated by the credentials of the HTTP callers.
+//
+//	creds, err := mtls.New(mtls.Config{CertFile: "/etc/tls/tls.crt", KeyFile: "/etc/tls/tls.key"})
+//	kit.NewFoundation("myservice", kit.WithTLS(creds))
+//
+// TLS defaults to the files of the FOUNDATION_TLS_* environment variables (see mtls.ConfigFromEnv),
+// which are not loaded when the option is given, and is disabled without them.
+func WithTLS(creds *mtls.Credentials) Option {
+	return func(fo *FoundationOptions) {
+		fo.tlsCreds = creds
+	}
+}
+
+// WithSinglePort serves the gRPC calls, the grpc-gateway and the custom HTTP handlers on the HTTP address only,
+// the gRPC calls being told apart by their content-type, over HTTP/2 with TLS or h2c (HTTP/2 in clear text).
+//
+// The HTTP write and read timeouts apply to the gRPC streams too, and are to be disabled for long-lived streams.
+//
+// SinglePort defaults to the FOUNDATION_SINGLE_PORT environment variable, false without it.
+func WithSinglePort() Option {
+	return func(fo *FoundationOptions) {
+		fo.singlePort = true
+	}
+}
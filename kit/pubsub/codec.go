@@ -0,0 +1,107 @@
+package pubsub
+
+import (
+	"encoding/json"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Content types of the codecs.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/protobuf"
+	ContentTypeMsgpack  = "application/msgpack"
+)
+
+// Codec encodes and decodes message payloads.
+type Codec interface {
+	// ContentType returns the content type stamped on the messages encoded by the codec.
+	ContentType() string
+	// Marshal returns the encoded bytes of v.
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes the encoded data and stores the result in the value pointed to by v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Codecs available out of the box.
+var (
+	// JSON encodes values as JSON, protobuf messages are encoded with protojson.
+	JSON Codec = jsonCodec{}
+	// Protobuf encodes protobuf messages in their binary wire format.
+	Protobuf Codec = protobufCodec{}
+	// Msgpack encodes values as MessagePack, the same way kit/cache does.
+	Msgpack Codec = msgpackCodec{}
+)
+
+// codecs is the list of codecs that can be negotiated from a message content type.
+var codecs = map[string]Codec{
+	ContentTypeJSON:     JSON,
+	ContentTypeProtobuf: Protobuf,
+	ContentTypeMsgpack:  Msgpack,
+}
+
+// CodecFor returns the codec of the given content type.
+// If there is no codec for the content type, false is returned.
+func CodecFor(contentType string) (Codec, bool) {
+	c, ok := codecs[contentType]
+	return c, ok
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return protojson.Marshal(m)
+	}
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return protojson.Unmarshal(data, m)
+	}
+	return json.Unmarshal(data, v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.Newf("%T is not a protobuf message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errors.Newf("%T is not a protobuf message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string {
+	return ContentTypeMsgpack
+}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
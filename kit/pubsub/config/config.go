@@ -10,19 +10,24 @@
 	"github.com/mukhtarkv/workspace/kit/log"
 	kitpubsub "github.com/mukhtarkv/workspace/kit/pubsub"
 	kitgcp "github.com/mukhtarkv/workspace/kit/pubsub/gcp"
+	kitinmem "github.com/mukhtarkv/workspace/kit/pubsub/inmem"
 	kitnats "github.com/mukhtarkv/workspace/kit/pubsub/nats"
 	"github.com/nats-io/nats.go"
 )
 
 // Config represent a PubSub configuration, it is defined by its kind.
 type Config struct {
-	Kind           string          `yaml:"kind"`
-	GcpPublisher   *GcpPublisher   `yaml:"gcpPublisher"`
-	GcpSubscriber  *GcpSubscriber  `yaml:"gcpSubscriber"`
-	NatsPublisher  *NatsPublisher  `yaml:"natsPublisher"`
-	NatsSubscriber *NatsSubscriber `yaml:"natsSubscriber"`
+	Kind            string          `yaml:"kind"`
+	GcpPublisher    *GcpPublisher   `yaml:"gcpPublisher"`
+	GcpSubscriber   *GcpSubscriber  `yaml:"gcpSubscriber"`
+	NatsPublisher   *NatsPublisher  `yaml:"natsPublisher"`
+	NatsSubscriber  *NatsSubscriber `yaml:"natsSubscriber"`
+	InmemPublisher  *InmemPubSub    `yaml:"inmemPublisher"`
+	InmemSubscriber *InmemPubSub    `yaml:"inmemSubscriber"`
 }
 
+// Publisher creates the publisher defined by the configuration kind.
+// The returned function closes the publisher, it can be registered as a kit.PhaseResources closer of the Foundation.
 func (c *Config) Publisher(ctx context.Context) (kitpubsub.Publisher, func(), error) {
 	closeFn := func() {}
 	switch c.Kind {
@@ -35,7 +40,15 @@ func (c *Config) Publisher(ctx context.Context) (kitpubsub.Publisher, func(), er
 			return nil, closeFn, err
 		}
 		pub, err := kitgcp.NewPublisher(client)
-		return pub, closeFn, err
+		if err != nil {
+			return nil, closeFn, err
+		}
+		closeFn = func() {
+			if err := pub.Close(); err != nil {
+				log.L().Warn(ctx, "failed to close gcp pub", log.Error(err))
+			}
+		}
+		return pub, closeFn, nil
 	case "nats-publisher":
 		if c.NatsPublisher == nil {
 			return nil, closeFn, errors.New("nats publisher missing")
@@ -46,12 +59,40 @@ func (c *Config) Publisher(ctx context.Context) (kitpubsub.Publisher, func(), er
 			return nil, closeFn, errors.Wrap(err, "")
 		}
 		pub, err := kitnats.NewPublisher(con, js)
-		return pub, closeFn, err
+		if err != nil {
+			return nil, closeFn, err
+		}
+		closeFn = func() {
+			if err := pub.Close(); err != nil {
+				log.L().Warn(ctx, "failed to close nats pub", log.Error(err))
+			}
+		}
+		return pub, closeFn, nil
+	case "inmem-publisher":
+		broker := kitinmem.DefaultBroker()
+		if c.InmemPublisher != nil {
+			if err := c.InmemPublisher.createSubscriptions(broker); err != nil {
+				return nil, closeFn, err
+			}
+		}
+		pub, err := kitinmem.NewPublisher(broker)
+		if err != nil {
+			return nil, closeFn, err
+		}
+		closeFn = func() {
+			if err := pub.Close(); err != nil {
+				log.L().Warn(ctx, "failed to close inmem pub", log.Error(err))
+			}
+		}
+		return pub, closeFn, nil
 	}
 
 	return nil, closeFn, errors.New("unknown pubsub provider")
 }
 
+// Subscriber creates the subscriber defined by the configuration kind.
+// The returned function stops the subscriptions and closes the subscriber,
+// it can be registered as a kit.PhaseDrain closer of the Foundation.
 func (c *Config) Subscriber(ctx context.Context) (kitpubsub.Subscriber, func(), error) {
 	closeFn := func() {}
 	switch c.Kind {
@@ -64,7 +105,15 @@ func (c *Config) Subscriber(ctx context.Context) (kitpubsub.Subscriber, func(),
 			return nil, closeFn, err
 		}
 		sub, err := kitgcp.NewSubscriber(client, c.GcpSubscriber.withOptions()...)
-		return sub, closeFn, err
+		if err != nil {
+			return nil, closeFn, err
+		}
+		closeFn = func() {
+			if err := sub.Close(); err != nil {
+				log.L().Warn(ctx, "failed to close gcp sub", log.Error(err))
+			}
+		}
+		return sub, closeFn, nil
 	case "nats-subscriber":
 		if c.NatsSubscriber == nil {
 			return nil, closeFn, errors.New("nats subscriber missing")
@@ -91,6 +140,23 @@ func (c *Config) Subscriber(ctx context.Context) (kitpubsub.Subscriber, func(),
 			}
 		}
 		return sub, closeFn, nil
+	case "inmem-subscriber":
+		broker := kitinmem.DefaultBroker()
+		if c.InmemSubscriber != nil {
+			if err := c.InmemSubscriber.createSubscriptions(broker); err != nil {
+				return nil, closeFn, err
+			}
+		}
+		sub, err := kitinmem.NewSubscriber(broker)
+		if err != nil {
+			return nil, closeFn, err
+		}
+		closeFn = func() {
+			if err := sub.Close(); err != nil {
+				log.L().Warn(ctx, "failed to close inmem sub", log.Error(err))
+			}
+		}
+		return sub, closeFn, nil
 	}
 
 	return nil, closeFn, errors.New("unknown pubsub subscriber")
@@ -184,3 +250,20 @@ type NatsSubscriber struct {
 	ConsumerName      string `yaml:"consumerName"`
 	ConsumerGroupName string `yaml:"consumerGroupName"`
 }
+
+// InmemPubSub is an in-memory pubsub configuration.
+// Publishers and subscribers of the same process share the same broker,
+// which allows running a service locally without any message broker.
+type InmemPubSub struct {
+	// Subscriptions maps a subscription name to the topic it is attached to.
+	Subscriptions map[string]string `yaml:"subscriptions"`
+}
+
+func (c *InmemPubSub) createSubscriptions(broker *kitinmem.Broker) error {
+	for subscription, topic := range c.Subscriptions {
+		if err := broker.CreateSubscription(subscription, topic); err != nil {
+			return errors.Wrap(err, "failed to create inmem subscription")
+		}
+	}
+	return nil
+}
@@ -10,6 +10,7 @@
 
 	"github.com/mukhtarkv/workspace/kit/config"
 	"github.com/mukhtarkv/workspace/kit/pubsub/gcp"
+	kitinmem "github.com/mukhtarkv/workspace/kit/pubsub/inmem"
 	kitnats "github.com/mukhtarkv/workspace/kit/pubsub/nats"
 	"github.com/stretchr/testify/assert"
 )
@@ -111,3 +112,25 @@ func TestNatsPubConfig(t *testing.T) {
 	assert.NoError(t, err)
 	assert.IsType(t, &kitnats.Publisher{}, p)
 }
+
+func TestInmemConfig(t *testing.T) {
+	rawConf := strings.NewReader(`kind: "inmem-subscriber"
+inmemSubscriber:
+  subscriptions:
+    testinmemsub: "testinmemtopic"`)
+	c := Config{}
+	err := config.From(rawConf, &c)
+	if !assert.NoError(t, err) {
+		return
+	}
+	s, close, err := c.Subscriber(context.TODO())
+	defer close()
+	assert.NoError(t, err)
+	assert.IsType(t, &kitinmem.Subscriber{}, s)
+
+	c = Config{Kind: "inmem-publisher"}
+	p, close, err := c.Publisher(context.TODO())
+	defer close()
+	assert.NoError(t, err)
+	assert.IsType(t, &kitinmem.Publisher{}, p)
+}
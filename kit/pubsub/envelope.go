@@ -0,0 +1,79 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+)
+
+// Well known headers.
+const (
+	// HeaderContentType describes how the data of a message is encoded.
+	HeaderContentType = "content-type"
+)
+
+// Envelope is a message along with its metadata.
+//
+// Headers are user defined key/value pairs carried with the message, they map to
+// NATS headers and Google Cloud Pub/Sub attributes. Keys used internally by the backends
+// for tracing and routing (e.g. trace, span, topic) are reserved and never exposed.
+type Envelope struct {
+	// ID uniquely identifies the message.
+	// When publishing, NATS and the in-memory publisher use the given ID or generate one,
+	// Google Cloud Pub/Sub always assigns its own.
+	// When subscribing, it is the backend native message ID.
+	ID string
+	// Data is the message payload.
+	Data Message
+	// Headers are the user defined headers of the message.
+	Headers map[string]string
+	// PublishTime is the time the message was published.
+	PublishTime time.Time
+	// OrderingKey identifies messages that must be delivered in the order they were published.
+	OrderingKey string
+	// DeliveryAttempt is the number of times the message has been delivered,
+	// zero when the backend doesn't track it.
+	DeliveryAttempt int
+}
+
+// Clone returns a copy of the envelope, the headers are copied too.
+// Publishers set their defaults on a copy so the envelope of the caller can be published again.
+func (e *Envelope) Clone() *Envelope {
+	c := *e
+	if e.Headers != nil {
+		c.Headers = make(map[string]string, len(e.Headers))
+		for k, v := range e.Headers {
+			c.Headers[k] = v
+		}
+	}
+	return &c
+}
+
+// Header returns the value of the header key,
+// if the header doesn't exist, an empty string is returned.
+func (e *Envelope) Header(key string) string {
+	if e.Headers == nil {
+		return ""
+	}
+	return e.Headers[key]
+}
+
+// SetHeader sets the header key to value.
+func (e *Envelope) SetHeader(key, value string) {
+	if e.Headers == nil {
+		e.Headers = make(map[string]string)
+	}
+	e.Headers[key] = value
+}
+
+// EnvelopePublisher publishes an envelope to the given topic.
+type EnvelopePublisher interface {
+	PublishEnvelope(ctx context.Context, topic string, env *Envelope) error
+}
+
+// EnvelopeSubscriber subscribe to a topic subscription and handle the incoming envelopes published to the topic.
+type EnvelopeSubscriber interface {
+	SubscribeEnvelope(ctx context.Context, subscription string, handler EnvelopeHandler) error
+}
+
+// EnvelopeHandler is the handler used to invoke the app handler with the message metadata.
+type EnvelopeHandler func(ctx context.Context, env *Envelope, ack func(), nack func()) error
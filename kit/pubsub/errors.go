@@ -4,6 +4,7 @@
 const (
 	PublisherClosed  = Error("publisher is closed")
 	SubscriberCLosed = Error("subscriber is closed")
+	MessageMalformed = Error("message is malformed")
 )
 
 // Error represents a cache error.
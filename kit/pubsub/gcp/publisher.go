@@ -15,12 +15,13 @@
 )
 
 var _ pubsub.Publisher = (*Publisher)(nil)
+var _ pubsub.EnvelopePublisher = (*Publisher)(nil)
 
 // Publisher publishes a message on a Google Cloud Pub/Sub topic.
 //
 // For more info on how Google Cloud Pub/Sub Publisher work, check https://cloud.google.com/pubsub/docs/publisher.
 type Publisher struct {
-	topics     map[string]*gcppubsub.Topic
+	topics     map[topicKey]*gcppubsub.Topic
 	topicsLock sync.RWMutex
 	closed     bool
 	closeLock  sync.RWMutex
@@ -36,7 +37,7 @@ func NewPublisher(client *gcppubsub.Client) (*Publisher, error) {
 	}
 
 	return &Publisher{
-		topics: map[string]*gcppubsub.Topic{},
+		topics: map[topicKey]*gcppubsub.Topic{},
 		client: client,
 	}, nil
 }
@@ -68,9 +69,25 @@ func (p *Publisher) Close() error {
 //
 // See https://cloud.google.com/pubsub/docs/publisher to find out more about how Google Cloud Pub/Sub Publishers work.
 func (p *Publisher) Publish(ctx context.Context, topic string, msg pubsub.Message) error {
+	return p.PublishEnvelope(ctx, topic, &pubsub.Envelope{Data: msg})
+}
+
+// PublishEnvelope publishes a message and its metadata on a Google Cloud Pub/Sub topic.
+// It blocks until the message is successfully published or an error occurred.
+//
+// The envelope headers are sent as message attributes and the ordering key as the message ordering key.
+// The envelope of the caller is left untouched, the message is published with the ID
+// assigned by Google Cloud Pub/Sub.
+//
+// When a message with an ordering key fails to be published, the publishing of the key is resumed,
+// the following messages of the key are published again instead of failing.
+func (p *Publisher) PublishEnvelope(ctx context.Context, topic string, env *pubsub.Envelope) error {
 	if len(topic) == 0 {
 		return fmt.Errorf("topic is nil")
 	}
+	if env == nil {
+		return fmt.Errorf("envelope is nil")
+	}
 
 	var span trace.Span
 	ctx, span = tracer.Start(ctx, fmt.Sprintf("Publish %s", topic))
@@ -88,12 +105,16 @@ func (p *Publisher) Publish(ctx context.Context, topic string, msg pubsub.Messag
 	}
 
 	// Prepare attributes that will be passed to the pubsub
+	// reserved attributes take precedence over the user defined ones.
 	attributes := make(map[string]string)
+	for k, v := range env.Headers {
+		attributes[k] = v
+	}
 	attributes["topic"] = topic
 	tracingAttributes(span, attributes)
 
 	// Get the topic
-	t, err := p.topic(ctx, topic)
+	t, err := p.topic(ctx, topic, len(env.OrderingKey) > 0)
 	if err != nil {
 		return err
 	}
@@ -101,18 +122,24 @@ func (p *Publisher) Publish(ctx context.Context, topic string, msg pubsub.Messag
 	// Setup a timeout for the publisher to give up and attempt to publish the message to the pubsub.
 	timeoutCtx, fn := context.WithTimeout(context.Background(), 5*time.Second)
 	defer fn()
-	_, err = t.Publish(ctx, &gcppubsub.Message{
-		Data:       msg,
-		Attributes: attributes,
+	serverID, err := t.Publish(ctx, &gcppubsub.Message{
+		Data:        env.Data,
+		Attributes:  attributes,
+		OrderingKey: env.OrderingKey,
 	}).Get(timeoutCtx)
 
 	// in case of error we set the trace to error and return.
 	if err != nil {
+		// the publishing of an ordering key is paused after an error, until it is resumed.
+		if len(env.OrderingKey) > 0 {
+			t.ResumePublish(env.OrderingKey)
+		}
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
 
+	span.SetAttributes(attribute.String("id", serverID))
 	return nil
 }
 
@@ -122,17 +149,27 @@ func (p *Publisher) isClose() bool {
 	return p.closed
 }
 
-func (p *Publisher) topic(ctx context.Context, topic string) (*gcppubsub.Topic, error) {
+// topicKey identifies the topics, the ordered messages being published by their own topic.
+type topicKey struct {
+	name    string
+	ordered bool
+}
+
+// topic returns the topic publishing the messages with ordering keys when ordered, without otherwise.
+func (p *Publisher) topic(ctx context.Context, topic string, ordered bool) (*gcppubsub.Topic, error) {
+	key := topicKey{name: topic, ordered: ordered}
 	p.topicsLock.RLock()
-	t, ok := p.topics[topic]
+	t, ok := p.topics[key]
 	p.topicsLock.RUnlock()
 	if ok {
 		return t, nil
 	}
-	var err error
 
 	p.topicsLock.Lock()
 	defer p.topicsLock.Unlock()
+	if t, ok := p.topics[key]; ok {
+		return t, nil
+	}
 
 	t = p.client.Topic(topic)
 	exists, err := t.Exists(ctx)
@@ -144,6 +181,10 @@ func (p *Publisher) topic(ctx context.Context, topic string) (*gcppubsub.Topic,
 		return nil, errors.Wrap(errors.New("topic does not exist"), topic)
 	}
 
-	p.topics[topic] = t
+	// the ordering is only enabled for the messages with ordering key,
+	// the other ones are still published in batches and concurrently.
+	t.EnableMessageOrdering = ordered
+
+	p.topics[key] = t
 	return t, nil
 }
@@ -0,0 +1,56 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+
+	gcppubsub "cloud.google.com/go/pubsub"
+	pb "cloud.google.com/go/pubsub/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/mukhtarkv/workspace/kit/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestPublishEnvelope_OrderingKeyResumed(t *testing.T) {
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	defer srv.Close()
+	_, err := srv.GServer.CreateTopic(ctx, &pb.Topic{Name: "projects/test/topics/topic"})
+	require.NoError(t, err)
+
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	client, err := gcppubsub.NewClient(ctx, "test", option.WithGRPCConn(conn))
+	require.NoError(t, err)
+	p, err := NewPublisher(client)
+	require.NoError(t, err)
+	defer p.Close()
+
+	// Given a publish of an ordering key failing
+	srv.SetAutoPublishResponse(false)
+	srv.AddPublishResponse(nil, status.Error(codes.InvalidArgument, "rejected"))
+	err = p.PublishEnvelope(ctx, "topic", &pubsub.Envelope{Data: pubsub.Message("1"), OrderingKey: "key"})
+	assert.Error(t, err)
+
+	// When the following message of the key is published
+	srv.AddPublishResponse(&pb.PublishResponse{MessageIds: []string{"2"}}, nil)
+	env := &pubsub.Envelope{Data: pubsub.Message("2"), OrderingKey: "key"}
+	err = p.PublishEnvelope(ctx, "topic", env)
+
+	// Then it is published, leaving the envelope of the caller untouched
+	assert.NoError(t, err)
+	assert.Empty(t, env.ID)
+	assert.True(t, env.PublishTime.IsZero())
+
+	// And the messages without ordering key are published without ordering
+	srv.AddPublishResponse(&pb.PublishResponse{MessageIds: []string{"3"}}, nil)
+	assert.NoError(t, p.Publish(ctx, "topic", pubsub.Message("3")))
+	assert.True(t, p.topics[topicKey{name: "topic", ordered: true}].EnableMessageOrdering)
+	assert.False(t, p.topics[topicKey{name: "topic"}].EnableMessageOrdering)
+}
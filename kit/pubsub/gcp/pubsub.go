@@ -11,6 +11,15 @@
 // tracer represent a GCP pubsub tracer
 var tracer = otel.Tracer("kit/pubsub/gcp")
 
+// reservedAttributes are the attributes used internally, they are not exposed as envelope headers.
+var reservedAttributes = map[string]struct{}{
+	"topic":        {},
+	"trace":        {},
+	"span":         {},
+	"trace-state":  {},
+	"trace-remote": {},
+}
+
 func tracingAttributes(span trace.Span, m map[string]string) {
 
 	m["trace"] = span.SpanContext().TraceID().String()
@@ -16,6 +16,7 @@
 )
 
 var _ pubsub.Subscriber = (*Subscriber)(nil)
+var _ pubsub.EnvelopeSubscriber = (*Subscriber)(nil)
 
 // SubscriberOption defines a Subscriber option.
 type SubscriberOption func(*gcppubsub.ReceiveSettings)
@@ -102,6 +103,17 @@ func (s *Subscriber) Subscribe(ctx context.Context, subscription string, handler
 }
 
 func (s *Subscriber) SubscribeWithAck(ctx context.Context, subscription string, handler pubsub.HandlerWithAck) error {
+	h := func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error {
+		return handler(ctx, env.Data, ack, nack)
+	}
+
+	return s.SubscribeEnvelope(ctx, subscription, h)
+}
+
+// SubscribeEnvelope consumes Google Cloud Pub/Sub, handing the messages along with their metadata to the handler.
+//
+// The delivery attempt is only tracked by Google Cloud Pub/Sub when the subscription has a dead letter policy.
+func (s *Subscriber) SubscribeEnvelope(ctx context.Context, subscription string, handler pubsub.EnvelopeHandler) error {
 	if s.isClosed() {
 		return fmt.Errorf("subscriber is closed")
 	}
@@ -122,7 +134,7 @@ func (s *Subscriber) SubscribeWithAck(ctx context.Context, subscription string,
 
 	receiveFinished := make(chan struct{})
 	s.subscriptionsWaitGroup.Add(1)
-	go func(sub *gcppubsub.Subscription, handler pubsub.HandlerWithAck) {
+	go func(sub *gcppubsub.Subscription, handler pubsub.EnvelopeHandler) {
 
 		// utilise exponential Backoff on the subscription to give room to breeze.
 		exponentialBackoff := backoff.NewExponentialBackOff()
@@ -163,7 +175,7 @@ func (s *Subscriber) SubscribeWithAck(ctx context.Context, subscription string,
 	return nil
 }
 
-func (s *Subscriber) receive(ctx context.Context, sub *gcppubsub.Subscription, handler pubsub.HandlerWithAck) error {
+func (s *Subscriber) receive(ctx context.Context, sub *gcppubsub.Subscription, handler pubsub.EnvelopeHandler) error {
 	err := sub.Receive(ctx, func(ctx context.Context, m *gcppubsub.Message) {
 
 		select {
@@ -184,6 +196,17 @@ func (s *Subscriber) receive(ctx context.Context, sub *gcppubsub.Subscription, h
 		// Add to the context the topic.
 		ctx = pubsub.WithTopic(ctx, topic)
 
+		env := envelope(m)
+
+		// Add to the context the message ID.
+		ctx = pubsub.WithMessageID(ctx, env.ID)
+
+		// Add to the context the delivery attempt,
+		// it is only tracked when the subscription has a dead letter policy.
+		if env.DeliveryAttempt > 0 {
+			ctx = pubsub.WithDeliveryAttempt(ctx, env.DeliveryAttempt)
+		}
+
 		// annotate the span
 		var span trace.Span
 		ctx, span = tracer.Start(ctx, fmt.Sprintf("Subscription %s/%s", topic, sub.ID()))
@@ -200,7 +223,7 @@ func (s *Subscriber) receive(ctx context.Context, sub *gcppubsub.Subscription, h
 
 		// Process the message
 		// in case of error, we record and label the error in the span.
-		if err := handler(ctx, m.Data, ack, nack); err != nil {
+		if err := handler(ctx, env, ack, nack); err != nil {
 			span.RecordError(err)
 			span.SetStatus(codes.Error, err.Error())
 		}
@@ -208,6 +231,26 @@ func (s *Subscriber) receive(ctx context.Context, sub *gcppubsub.Subscription, h
 	return err
 }
 
+// envelope creates an envelope from a Google Cloud Pub/Sub message.
+func envelope(m *gcppubsub.Message) *pubsub.Envelope {
+	env := &pubsub.Envelope{
+		ID:          m.ID,
+		Data:        m.Data,
+		PublishTime: m.PublishTime,
+		OrderingKey: m.OrderingKey,
+	}
+	for k, v := range m.Attributes {
+		if _, ok := reservedAttributes[k]; ok {
+			continue
+		}
+		env.SetHeader(k, v)
+	}
+	if m.DeliveryAttempt != nil {
+		env.DeliveryAttempt = *m.DeliveryAttempt
+	}
+	return env
+}
+
 func (s *Subscriber) subscription(ctx context.Context, subscription string) (*gcppubsub.Subscription, error) {
 	s.activeSubscriptionsLock.RLock()
 	sub, ok := s.activeSubscriptions[subscription]
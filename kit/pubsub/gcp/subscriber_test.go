@@ -5,6 +5,7 @@
 	"time"
 
 	gcppubsub "cloud.google.com/go/pubsub"
+	"github.com/mukhtarkv/workspace/kit/pubsub"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,3 +31,29 @@ func TestSubscriberOption(t *testing.T) {
 		NumGoroutines:          1,
 	}, s.settings)
 }
+
+func TestEnvelope(t *testing.T) {
+	attempt := 2
+	now := time.Now()
+	m := &gcppubsub.Message{
+		ID:          "42",
+		Data:        []byte("test"),
+		PublishTime: now,
+		OrderingKey: "key",
+		Attributes: map[string]string{
+			"topic":                  "topic",
+			"trace":                  "0af7651916cd43dd8448eb211c80319c",
+			pubsub.HeaderContentType: "text/plain",
+		},
+		DeliveryAttempt: &attempt,
+	}
+
+	assert.Equal(t, &pubsub.Envelope{
+		ID:              "42",
+		Data:            pubsub.Message("test"),
+		Headers:         map[string]string{pubsub.HeaderContentType: "text/plain"},
+		PublishTime:     now,
+		OrderingKey:     "key",
+		DeliveryAttempt: 2,
+	}, envelope(m))
+}
@@ -0,0 +1,44 @@
+package inbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/errors"
+)
+
+// enforce the CacheStore to implement the Store interface.
+var _ Store = (*CacheStore)(nil)
+
+// cacheKeyPrefix namespaces the inbox keys in the cache.
+const cacheKeyPrefix = "inbox:"
+
+// CacheStore records the processed messages in a cache, such as Redis (see kit/cache/redis).
+// The records expire with the cache entries.
+type CacheStore struct {
+	cache cache.Cache
+}
+
+// NewCacheStore creates a new CacheStore.
+func NewCacheStore(c cache.Cache) *CacheStore {
+	return &CacheStore{cache: c}
+}
+
+// Processed reports whether the message has been processed.
+func (s *CacheStore) Processed(ctx context.Context, key string) (bool, error) {
+	var processedAt time.Time
+	err := s.cache.Get(ctx, cacheKeyPrefix+key, &processedAt)
+	if errors.Is(err, cache.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MarkProcessed records the message as processed.
+func (s *CacheStore) MarkProcessed(ctx context.Context, key string, ttl time.Duration) error {
+	return s.cache.Set(ctx, cacheKeyPrefix+key, time.Now().UTC(), ttl)
+}
@@ -0,0 +1,195 @@
+// Package inbox implements an idempotent consumer on top of kit/pubsub.
+//
+// NATS JetStream and GCP Pub/Sub deliver messages at least once, the inbox records the IDs
+// of the processed messages in a Store, and acks the redelivered ones without invoking the handler:
+//
+//	store := inbox.NewCacheStore(redisCache)
+//	sub.SubscribeWithAck(ctx, "my-subscription", inbox.WithInbox(handler, store, "my-service"))
+//
+// Messages are identified by the ID assigned by the backend or the publisher (see pubsub.Envelope.ID):
+// the GCP message ID, the NATS `Nats-Msg-Id` header or the JetStream stream sequence.
+//
+// A message is recorded as processed when the handler acks it. Two deliveries of the same message
+// handled concurrently can both be processed, the inbox protects against redeliveries.
+//
+// With a TxStore, such as SQLStore, the handler can record the message within the transaction
+// of its own changes with MarkProcessedTx, so both are committed or rolled back together:
+//
+//	tx, _ := db.BeginTxx(ctx, nil)
+//	// ... write the changes with tx
+//	_ = inbox.MarkProcessedTx(ctx, tx)
+//	_ = tx.Commit()
+//	ack()
+package inbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/pubsub"
+	kitsql "github.com/mukhtarkv/workspace/kit/sql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Store records the processed messages.
+type Store interface {
+	// Processed reports whether the message identified by the key has been processed,
+	// and its record has not expired.
+	Processed(ctx context.Context, key string) (bool, error)
+
+	// MarkProcessed records the message identified by the key as processed for the ttl duration.
+	MarkProcessed(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// TxStore is a Store able to record the processed messages within a transaction.
+type TxStore interface {
+	Store
+
+	// MarkProcessedTx records the message identified by the key as processed for the ttl duration,
+	// within the transaction.
+	MarkProcessedTx(ctx context.Context, tx kitsql.Queryable, key string, ttl time.Duration) error
+}
+
+// message is the message being handled, put in the handler context.
+type message struct {
+	store  Store
+	key    string
+	ttl    time.Duration
+	marked bool
+}
+
+type messageKey struct{}
+
+// MarkProcessedTx records the message being handled as processed within the transaction tx,
+// along with the changes of the handler. The message is then not recorded again when acked.
+//
+// The store of the inbox must be a TxStore, and ctx the context of the handler.
+func MarkProcessedTx(ctx context.Context, tx kitsql.Queryable) error {
+	m, ok := ctx.Value(messageKey{}).(*message)
+	if !ok {
+		return errors.New("no inbox message in context")
+	}
+	store, ok := m.store.(TxStore)
+	if !ok {
+		return errors.New("inbox store does not support transactions")
+	}
+	if err := store.MarkProcessedTx(ctx, tx, m.key, m.ttl); err != nil {
+		return err
+	}
+	m.marked = true
+	return nil
+}
+
+// Option defines an inbox option.
+type Option func(*options)
+
+// options provides a set of configurable options for the inbox.
+type options struct {
+	ttl time.Duration
+	key func(ctx context.Context, env *pubsub.Envelope) string
+}
+
+// WithInbox wraps a HandlerWithAck with an inbox, skipping and acking the messages
+// already processed by the consumer.
+//
+// The consumer name scopes the message IDs, so that services sharing a Store
+// and subscribing to the same topic don't skip each other messages.
+// The message ID is read from the context (see pubsub.GetMessageID),
+// messages without ID are always handled.
+func WithInbox(handler pubsub.HandlerWithAck, store Store, consumer string, opts ...Option) pubsub.HandlerWithAck {
+	h := WithEnvelopeInbox(func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error {
+		return handler(ctx, env.Data, ack, nack)
+	}, store, consumer, opts...)
+
+	return func(ctx context.Context, msg pubsub.Message, ack func(), nack func()) error {
+		return h(ctx, &pubsub.Envelope{ID: pubsub.GetMessageID(ctx), Data: msg}, ack, nack)
+	}
+}
+
+// WithEnvelopeInbox wraps an EnvelopeHandler with an inbox.
+// It behaves as WithInbox, and the message is identified by the envelope ID.
+func WithEnvelopeInbox(handler pubsub.EnvelopeHandler, store Store, consumer string, opts ...Option) pubsub.EnvelopeHandler {
+	// default options
+	o := &options{
+		ttl: 24 * time.Hour,
+		key: func(ctx context.Context, env *pubsub.Envelope) string {
+			return env.ID
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error {
+		id := o.key(ctx, env)
+		if len(id) == 0 {
+			return handler(ctx, env, ack, nack)
+		}
+		key := consumer + ":" + id
+
+		processed, err := store.Processed(ctx, key)
+		if err != nil {
+			// let the backend redeliver the message once the store is available.
+			nack()
+			return errors.Wrapf(err, "checking inbox for message %s", id)
+		}
+
+		span := trace.SpanFromContext(ctx)
+		span.SetAttributes(attribute.Bool("inbox.duplicate", processed))
+		if processed {
+			ack()
+			return nil
+		}
+
+		// record the message before acking it, so a failure in between leads to
+		// a redelivery being skipped rather than the message being processed twice.
+		m := &message{store: store, key: key, ttl: o.ttl}
+		var (
+			markErr error
+			once    sync.Once
+		)
+		markAndAck := func() {
+			once.Do(func() {
+				// the message is already recorded within the transaction of the handler.
+				if !m.marked {
+					markErr = store.MarkProcessed(ctx, key, o.ttl)
+				}
+			})
+			ack()
+		}
+
+		err = handler(context.WithValue(ctx, messageKey{}, m), env, markAndAck, nack)
+		if err == nil && markErr != nil {
+			return errors.Wrapf(markErr, "recording message %s in inbox", id)
+		}
+		return err
+	}
+}
+
+// WithTTL defines how long a processed message is remembered.
+// It should exceed the time the backend might redeliver a message.
+//
+// TTL defaults 24 hours.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl > 0 {
+			o.ttl = ttl
+		}
+	}
+}
+
+// WithKey defines how messages are identified, for instance with a business key
+// carried in the message headers instead of the backend ID.
+// An empty key disables the deduplication of the message.
+//
+// Key defaults to the message ID.
+func WithKey(key func(ctx context.Context, env *pubsub.Envelope) string) Option {
+	return func(o *options) {
+		if key != nil {
+			o.key = key
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package inbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/cache/memory"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/pubsub"
+	"github.com/mukhtarkv/workspace/kit/pubsub/inmem"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCacheStore returns a CacheStore over an in-process cache.
+func newCacheStore(t *testing.T) *CacheStore {
+	c, err := memory.New()
+	if err != nil {
+		t.Fatalf("setting up memory cache %v", err)
+	}
+	return NewCacheStore(c)
+}
+
+func TestWithInbox(t *testing.T) {
+	store := newCacheStore(t)
+	handled := 0
+	h := WithInbox(func(ctx context.Context, msg pubsub.Message, ack func(), nack func()) error {
+		handled++
+		if msg.String() == "fail" {
+			nack()
+			return errors.New("failed")
+		}
+		ack()
+		return nil
+	}, store, "consumer")
+
+	deliver := func(id string, msg string) (acked bool, nacked bool, err error) {
+		ctx := pubsub.WithMessageID(context.Background(), id)
+		err = h(ctx, pubsub.Message(msg), func() { acked = true }, func() { nacked = true })
+		return
+	}
+
+	acked, _, err := deliver("1", "ok")
+	assert.NoError(t, err)
+	assert.True(t, acked)
+	assert.Equal(t, 1, handled)
+
+	// duplicates are acked without being handled.
+	acked, _, err = deliver("1", "ok")
+	assert.NoError(t, err)
+	assert.True(t, acked)
+	assert.Equal(t, 1, handled)
+
+	// failed messages are not recorded.
+	_, nacked, err := deliver("2", "fail")
+	assert.Error(t, err)
+	assert.True(t, nacked)
+	_, nacked, _ = deliver("2", "fail")
+	assert.True(t, nacked)
+	assert.Equal(t, 3, handled)
+
+	// messages without ID are always handled.
+	deliver("", "ok")
+	deliver("", "ok")
+	assert.Equal(t, 5, handled)
+
+	processed, err := store.Processed(context.Background(), "consumer:1")
+	assert.NoError(t, err)
+	assert.True(t, processed)
+	processed, err = store.Processed(context.Background(), "other:1")
+	assert.NoError(t, err)
+	assert.False(t, processed)
+}
+
+func TestWithEnvelopeInboxKey(t *testing.T) {
+	handled := 0
+	h := WithEnvelopeInbox(func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error {
+		handled++
+		ack()
+		return nil
+	}, newCacheStore(t), "consumer", WithKey(func(ctx context.Context, env *pubsub.Envelope) string {
+		return env.Header("order-id")
+	}))
+
+	ctx := context.Background()
+	for _, id := range []string{"1", "2"} {
+		env := &pubsub.Envelope{ID: id, Headers: map[string]string{"order-id": "42"}}
+		assert.NoError(t, h(ctx, env, func() {}, func() {}))
+	}
+	assert.Equal(t, 1, handled)
+}
+
+func TestInboxRedelivery(t *testing.T) {
+	broker := inmem.NewBroker()
+	assert.NoError(t, broker.CreateSubscription("sub", "topic"))
+	pub, err := inmem.NewPublisher(broker)
+	assert.NoError(t, err)
+	sub, err := inmem.NewSubscriber(broker)
+	assert.NoError(t, err)
+	defer sub.Close()
+
+	// the same message is published twice, as a publisher retrying would.
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, pub.PublishEnvelope(ctx, "topic", &pubsub.Envelope{ID: "42", Data: pubsub.Message("msg")}))
+	}
+
+	handled := 0
+	h := WithInbox(func(ctx context.Context, msg pubsub.Message, ack func(), nack func()) error {
+		handled++
+		ack()
+		return nil
+	}, newCacheStore(t), "consumer", WithTTL(time.Hour))
+
+	acked := make(chan struct{}, 2)
+	subCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	go sub.SubscribeWithAck(subCtx, "sub", func(ctx context.Context, msg pubsub.Message, ack func(), nack func()) error { //nolint
+		return h(ctx, msg, func() {
+			ack()
+			acked <- struct{}{}
+		}, nack)
+	})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-acked:
+		case <-subCtx.Done():
+			t.Fatal("message not acked")
+		}
+	}
+	assert.Equal(t, 1, handled)
+}
+
+func TestMarkProcessedTxRequiresTxStore(t *testing.T) {
+	assert.Error(t, MarkProcessedTx(context.Background(), nil))
+
+	h := WithEnvelopeInbox(func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error {
+		return MarkProcessedTx(ctx, nil)
+	}, newCacheStore(t), "consumer")
+	err := h(context.Background(), &pubsub.Envelope{ID: "1"}, func() {}, func() {})
+	assert.Error(t, err)
+}
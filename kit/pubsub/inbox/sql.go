@@ -0,0 +1,79 @@
+package inbox
+
+import (
+	"context"
+	"embed"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	kitsql "github.com/mukhtarkv/workspace/kit/sql"
+)
+
+// enforce the SQLStore to implement the TxStore interface.
+var _ TxStore = (*SQLStore)(nil)
+
+//go:embed db
+var migrations embed.FS
+
+// Migrate creates or updates the inbox table.
+//
+// The migration version is tracked in the table `<service>_inbox_schema_migrations`,
+// separately from the service own migrations.
+func Migrate(db *sqlx.DB, service string) error {
+	if len(service) == 0 {
+		return errors.New("service name is required")
+	}
+	return kitsql.MigrateWithPath(db, migrations, service+"_inbox", "db")
+}
+
+// SQLStore records the processed messages in the Postgres inbox table (see Migrate).
+//
+// The expired records are not deleted automatically, Cleanup should be called periodically.
+type SQLStore struct {
+	db kitsql.Queryable
+}
+
+// NewSQLStore creates a new SQLStore.
+//
+// To record a message within the same transaction as the changes made by the handler,
+// the handler calls MarkProcessedTx with its transaction.
+func NewSQLStore(db kitsql.Queryable) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Processed reports whether the message has been processed.
+func (s *SQLStore) Processed(ctx context.Context, key string) (bool, error) {
+	var processed bool
+	const q = `SELECT EXISTS (SELECT 1 FROM inbox WHERE key = $1 AND expires_at > $2)`
+	if err := s.db.GetContext(ctx, &processed, q, key, time.Now().UTC()); err != nil {
+		return false, errors.Wrap(err, "select inbox message")
+	}
+	return processed, nil
+}
+
+// MarkProcessed records the message as processed.
+func (s *SQLStore) MarkProcessed(ctx context.Context, key string, ttl time.Duration) error {
+	return s.MarkProcessedTx(ctx, s.db, key, ttl)
+}
+
+// MarkProcessedTx records the message as processed within the transaction.
+func (s *SQLStore) MarkProcessedTx(ctx context.Context, tx kitsql.Queryable, key string, ttl time.Duration) error {
+	now := time.Now().UTC()
+	const q = `INSERT INTO inbox (key, processed_at, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET processed_at = EXCLUDED.processed_at, expires_at = EXCLUDED.expires_at`
+	if _, err := tx.ExecContext(ctx, q, key, now, now.Add(ttl)); err != nil {
+		return errors.Wrap(err, "insert inbox message")
+	}
+	return nil
+}
+
+// Cleanup deletes the expired records and returns the number of records deleted.
+func (s *SQLStore) Cleanup(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM inbox WHERE expires_at <= $1`, time.Now().UTC())
+	if err != nil {
+		return 0, errors.Wrap(err, "delete expired inbox messages")
+	}
+	return res.RowsAffected()
+}
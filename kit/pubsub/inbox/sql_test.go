@@ -0,0 +1,100 @@
+package inbox
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/pubsub"
+	kitsql "github.com/mukhtarkv/workspace/kit/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLStore(t *testing.T) {
+	if os.Getenv("TESTINGDB_URL") == "" {
+		t.Skip("Skipping, no testing database setup via env variable TESTINGDB_URL")
+	}
+
+	var tdb kitsql.TestingDB
+	if !assert.NoError(t, tdb.Open()) {
+		return
+	}
+	defer tdb.Close()
+	if !assert.NoError(t, Migrate(tdb.DB, "test")) {
+		return
+	}
+
+	ctx := context.Background()
+	store := NewSQLStore(tdb.DB)
+
+	processed, err := store.Processed(ctx, "consumer:1")
+	assert.NoError(t, err)
+	assert.False(t, processed)
+
+	assert.NoError(t, store.MarkProcessed(ctx, "consumer:1", time.Hour))
+	processed, err = store.Processed(ctx, "consumer:1")
+	assert.NoError(t, err)
+	assert.True(t, processed)
+
+	// expired records are ignored and deleted by the cleanup.
+	assert.NoError(t, store.MarkProcessed(ctx, "consumer:2", time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	processed, err = store.Processed(ctx, "consumer:2")
+	assert.NoError(t, err)
+	assert.False(t, processed)
+
+	deleted, err := store.Cleanup(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestSQLStoreMarkProcessedTx(t *testing.T) {
+	if os.Getenv("TESTINGDB_URL") == "" {
+		t.Skip("Skipping, no testing database setup via env variable TESTINGDB_URL")
+	}
+
+	var tdb kitsql.TestingDB
+	if !assert.NoError(t, tdb.Open()) {
+		return
+	}
+	defer tdb.Close()
+	if !assert.NoError(t, Migrate(tdb.DB, "test")) {
+		return
+	}
+
+	ctx := context.Background()
+	store := NewSQLStore(tdb.DB)
+	rollback := true
+	h := WithEnvelopeInbox(func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error {
+		tx, err := tdb.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := MarkProcessedTx(ctx, tx); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if rollback {
+			nack()
+			return tx.Rollback()
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		ack()
+		return nil
+	}, store, "tx-consumer")
+
+	// the message is not recorded when the transaction of the handler rolls back.
+	assert.NoError(t, h(ctx, &pubsub.Envelope{ID: "1"}, func() {}, func() {}))
+	processed, err := store.Processed(ctx, "tx-consumer:1")
+	assert.NoError(t, err)
+	assert.False(t, processed)
+
+	rollback = false
+	assert.NoError(t, h(ctx, &pubsub.Envelope{ID: "1"}, func() {}, func() {}))
+	processed, err = store.Processed(ctx, "tx-consumer:1")
+	assert.NoError(t, err)
+	assert.True(t, processed)
+}
@@ -0,0 +1,119 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/id"
+	"github.com/mukhtarkv/workspace/kit/pubsub"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ pubsub.Publisher = (*Publisher)(nil)
+var _ pubsub.EnvelopePublisher = (*Publisher)(nil)
+
+// Publisher publishes a message on an in-memory Broker topic.
+//
+// It is meant to be used in tests and local development where no message broker is available.
+type Publisher struct {
+	broker    *Broker
+	closed    bool
+	closeLock sync.RWMutex
+}
+
+// NewPublisher creates a new in-memory publisher on the given broker.
+func NewPublisher(broker *Broker) (*Publisher, error) {
+	if broker == nil {
+		return nil, errors.New("broker is nil")
+	}
+
+	return &Publisher{
+		broker: broker,
+	}, nil
+}
+
+// Close notifies the Publisher to stop publishing messages.
+func (p *Publisher) Close() error {
+	p.closeLock.Lock()
+	defer p.closeLock.Unlock()
+
+	if p.closed {
+		return pubsub.PublisherClosed
+	}
+	p.closed = true
+	return nil
+}
+
+// Publish publishes a message on the broker topic.
+//
+// The message is delivered to every subscription attached to the topic at the time of the call,
+// if the topic has no subscriptions, the message is dropped.
+func (p *Publisher) Publish(ctx context.Context, topic string, msg pubsub.Message) error {
+	return p.PublishEnvelope(ctx, topic, &pubsub.Envelope{Data: msg})
+}
+
+// PublishEnvelope publishes a message and its metadata on the broker topic.
+// If the envelope has no ID, one is generated. The envelope of the caller is left untouched,
+// so it can be published again, or on other topics.
+func (p *Publisher) PublishEnvelope(ctx context.Context, topic string, env *pubsub.Envelope) error {
+	if len(topic) == 0 {
+		return fmt.Errorf("topic is nil")
+	}
+	if env == nil {
+		return fmt.Errorf("envelope is nil")
+	}
+
+	var span trace.Span
+	_, span = tracer.Start(ctx, fmt.Sprintf("Publish %s", topic))
+	span.SetAttributes(attribute.String("topic", topic))
+	defer span.End()
+
+	// if the publisher has been closed
+	// we return an error and annotate the trace with the error.
+	if p.isClosed() {
+		err := pubsub.PublisherClosed
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	// the defaults are set on a copy, the envelope of the caller may be reused.
+	env = env.Clone()
+	if len(env.ID) == 0 {
+		env.ID = id.New()
+	}
+	if env.PublishTime.IsZero() {
+		env.PublishTime = time.Now().UTC()
+	}
+
+	// Prepare attributes that will be passed to the pubsub
+	// reserved attributes take precedence over the user defined ones.
+	attributes := make(map[string]string)
+	for k, v := range env.Headers {
+		attributes[k] = v
+	}
+	attributes["topic"] = topic
+	tracingAttributes(span, attributes)
+
+	p.broker.publish(topic, &message{
+		id:          env.ID,
+		data:        env.Data,
+		attributes:  attributes,
+		publishTime: env.PublishTime,
+		orderingKey: env.OrderingKey,
+	})
+
+	return nil
+}
+
+func (p *Publisher) isClosed() bool {
+	p.closeLock.RLock()
+	defer p.closeLock.RUnlock()
+	return p.closed
+}
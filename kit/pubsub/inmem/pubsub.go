@@ -0,0 +1,284 @@
+package inmem
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/pubsub"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer represents an in-memory pubsub tracer
+var tracer = otel.Tracer("kit/pubsub/inmem")
+
+// reservedAttributes are the attributes used internally, they are not exposed as envelope headers.
+var reservedAttributes = map[string]struct{}{
+	"topic":        {},
+	"trace":        {},
+	"span":         {},
+	"trace-state":  {},
+	"trace-remote": {},
+}
+
+var (
+	defaultBroker     *Broker
+	defaultBrokerOnce sync.Once
+)
+
+// DefaultBroker returns the process wide Broker.
+//
+// It is the broker used by the pubsub configuration so a publisher and a subscriber
+// created separately can exchange messages.
+func DefaultBroker() *Broker {
+	defaultBrokerOnce.Do(func() {
+		defaultBroker = NewBroker()
+	})
+	return defaultBroker
+}
+
+// Broker routes messages published to a topic to every subscription attached to that topic.
+//
+// Like Google Cloud Pub/Sub, a subscription must be created before it can be consumed,
+// and only messages published after the subscription was created are delivered to it.
+// Each message published to a topic is delivered to all its subscriptions (fan-out)
+// and within a subscription to only one of its consumers.
+type Broker struct {
+	mu            sync.RWMutex
+	subscriptions map[string]*subscription
+	topics        map[string][]*subscription
+}
+
+// NewBroker creates a new empty in-memory Broker.
+func NewBroker() *Broker {
+	return &Broker{
+		subscriptions: map[string]*subscription{},
+		topics:        map[string][]*subscription{},
+	}
+}
+
+// CreateSubscription attaches a new subscription to the given topic.
+//
+// Creating a subscription that already exists on the same topic is a no-op,
+// while creating it on a different topic returns an error.
+func (b *Broker) CreateSubscription(name string, topic string) error {
+	if len(name) == 0 {
+		return errors.New("subscription is nil")
+	}
+	if len(topic) == 0 {
+		return errors.New("topic is nil")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if sub, ok := b.subscriptions[name]; ok {
+		if sub.topic != topic {
+			return errors.Newf("subscription %s already exists on topic %s", name, sub.topic)
+		}
+		return nil
+	}
+
+	sub := newSubscription(name, topic)
+	b.subscriptions[name] = sub
+	b.topics[topic] = append(b.topics[topic], sub)
+	return nil
+}
+
+// DeleteSubscription detaches a subscription from its topic and drops its pending messages.
+// if the subscription doesn't exist, nil error will be return.
+func (b *Broker) DeleteSubscription(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub, ok := b.subscriptions[name]
+	if !ok {
+		return nil
+	}
+	delete(b.subscriptions, name)
+
+	subs := b.topics[sub.topic]
+	for i, s := range subs {
+		if s == sub {
+			b.topics[sub.topic] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(b.topics[sub.topic]) == 0 {
+		delete(b.topics, sub.topic)
+	}
+
+	sub.drop()
+	return nil
+}
+
+func (b *Broker) subscription(name string) (*subscription, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	sub, ok := b.subscriptions[name]
+	if !ok {
+		return nil, errors.Wrap(errors.New("subscription does not exist"), name)
+	}
+	return sub, nil
+}
+
+// publish fans out the message to every subscription of the topic.
+func (b *Broker) publish(topic string, m *message) {
+	b.mu.RLock()
+	subs := b.topics[topic]
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		sub.push(m.clone())
+	}
+}
+
+// message is the in-memory representation of a published message.
+type message struct {
+	id              string
+	data            pubsub.Message
+	attributes      map[string]string
+	publishTime     time.Time
+	orderingKey     string
+	deliveryAttempt int
+}
+
+func (m *message) clone() *message {
+	attributes := make(map[string]string, len(m.attributes))
+	for k, v := range m.attributes {
+		attributes[k] = v
+	}
+	data := make(pubsub.Message, len(m.data))
+	copy(data, m.data)
+
+	return &message{
+		id:          m.id,
+		data:        data,
+		attributes:  attributes,
+		publishTime: m.publishTime,
+		orderingKey: m.orderingKey,
+	}
+}
+
+// envelope creates an envelope from the message for its current delivery.
+func (m *message) envelope() *pubsub.Envelope {
+	env := &pubsub.Envelope{
+		ID:              m.id,
+		Data:            m.data,
+		PublishTime:     m.publishTime,
+		OrderingKey:     m.orderingKey,
+		DeliveryAttempt: m.deliveryAttempt,
+	}
+	for k, v := range m.attributes {
+		if _, ok := reservedAttributes[k]; ok {
+			continue
+		}
+		env.SetHeader(k, v)
+	}
+	return env
+}
+
+// subscription is a queue of messages waiting to be delivered to one of its consumers.
+type subscription struct {
+	name   string
+	topic  string
+	mu     sync.Mutex
+	queue  []*message
+	notify chan struct{}
+}
+
+func newSubscription(name, topic string) *subscription {
+	return &subscription{
+		name:   name,
+		topic:  topic,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// push appends the message to the queue and wakes up a consumer.
+func (s *subscription) push(m *message) {
+	s.mu.Lock()
+	s.queue = append(s.queue, m)
+	s.mu.Unlock()
+
+	s.signal()
+}
+
+// pop removes the first message of the queue.
+// If the queue is empty, nil is returned.
+func (s *subscription) pop() *message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		return nil
+	}
+	m := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+
+	// wake up the next consumer if there is more work to do.
+	if len(s.queue) > 0 {
+		s.signal()
+	}
+	return m
+}
+
+func (s *subscription) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = nil
+}
+
+func (s *subscription) signal() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func tracingAttributes(span trace.Span, m map[string]string) {
+
+	m["trace"] = span.SpanContext().TraceID().String()
+	m["span"] = span.SpanContext().SpanID().String()
+	m["trace-state"] = span.SpanContext().TraceState().String()
+	m["trace-remote"] = strconv.FormatBool(span.SpanContext().IsRemote())
+}
+
+func contextFromTracingAttributes(ctx context.Context, m map[string]string) context.Context {
+	traceID, err := trace.TraceIDFromHex(m["trace"])
+	if err != nil {
+		return ctx
+	}
+	spanID, err := trace.SpanIDFromHex(m["span"])
+	if err != nil {
+		return ctx
+	}
+
+	stats, err := trace.ParseTraceState(m["trace-state"])
+	if err != nil {
+		return ctx
+	}
+
+	remote, err := strconv.ParseBool(m["trace-remote"])
+	if err != nil {
+		return ctx
+	}
+
+	scc := trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceState: stats,
+		Remote:     remote,
+	}
+
+	sc := trace.NewSpanContext(scc)
+	if !sc.IsValid() {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
@@ -0,0 +1,268 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/pubsub"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ pubsub.Subscriber = (*Subscriber)(nil)
+var _ pubsub.EnvelopeSubscriber = (*Subscriber)(nil)
+
+// SubscriberOption defines a Subscriber option.
+type SubscriberOption func(*subscriberOptions)
+
+// subscriberOptions provides a set of configurable options for the Subscriber.
+type subscriberOptions struct {
+	ackDeadline   time.Duration
+	nackDelay     time.Duration
+	numGoroutines int
+}
+
+// Subscriber consumes messages from in-memory Broker subscriptions.
+//
+// Messages handed to a HandlerWithAck must be acknowledged by calling ack.
+// A message that is nacked, or that is neither acked nor nacked before the ack deadline,
+// is redelivered to the subscription with its delivery attempt incremented.
+type Subscriber struct {
+	closing                chan struct{}
+	closed                 bool
+	closedLock             sync.Mutex
+	subscriptionsWaitGroup sync.WaitGroup
+	broker                 *Broker
+	opts                   subscriberOptions
+}
+
+// NewSubscriber creates a new in-memory Subscriber on the given broker.
+//
+// it required a call to Close in order to stop processing messages.
+func NewSubscriber(broker *Broker, opts ...SubscriberOption) (*Subscriber, error) {
+	if broker == nil {
+		return nil, errors.New("broker is nil")
+	}
+
+	// default options
+	options := subscriberOptions{
+		ackDeadline:   10 * time.Second,
+		nackDelay:     0,
+		numGoroutines: 1,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &Subscriber{
+		closing: make(chan struct{}),
+		broker:  broker,
+		opts:    options,
+	}, nil
+}
+
+// Close notifies the Subscriber to stop processing messages on all subscriptions
+// and waits for the in-flight handlers to return.
+func (s *Subscriber) Close() error {
+	if s.isClosed() {
+		return nil
+	}
+	s.setClosed(true)
+	close(s.closing)
+
+	// wait for all subscribers
+	s.subscriptionsWaitGroup.Wait()
+	return nil
+}
+
+// Subscribe consumes an in-memory subscription, every message is acked before being handled.
+func (s *Subscriber) Subscribe(ctx context.Context, subscription string, handler pubsub.Handler) error {
+	h := func(ctx context.Context, msg pubsub.Message, ack func(), nack func()) error {
+		// default behavior is to always ack.
+		ack()
+		return handler(ctx, msg)
+	}
+
+	return s.SubscribeWithAck(ctx, subscription, h)
+}
+
+// SubscribeWithAck consumes an in-memory subscription, leaving the handler in charge of acking the messages.
+//
+// The subscription must have been created on the broker beforehand.
+// Consumption stops when the given context is done or the Subscriber is closed.
+func (s *Subscriber) SubscribeWithAck(ctx context.Context, subscription string, handler pubsub.HandlerWithAck) error {
+	h := func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error {
+		return handler(ctx, env.Data, ack, nack)
+	}
+
+	return s.SubscribeEnvelope(ctx, subscription, h)
+}
+
+// SubscribeEnvelope consumes an in-memory subscription, handing the messages along with their metadata to the handler.
+func (s *Subscriber) SubscribeEnvelope(ctx context.Context, subscription string, handler pubsub.EnvelopeHandler) error {
+	if s.isClosed() {
+		return pubsub.SubscriberCLosed
+	}
+
+	if len(subscription) == 0 {
+		return fmt.Errorf("subscription is nil")
+	}
+
+	sub, err := s.broker.subscription(subscription)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < s.opts.numGoroutines; i++ {
+		s.subscriptionsWaitGroup.Add(1)
+		go func() {
+			defer s.subscriptionsWaitGroup.Done()
+			s.consume(ctx, sub, handler)
+		}()
+	}
+
+	return nil
+}
+
+// consume waits for messages on the subscription and hands them to the handler one at a time.
+func (s *Subscriber) consume(ctx context.Context, sub *subscription, handler pubsub.EnvelopeHandler) {
+	for {
+		m := sub.pop()
+		if m == nil {
+			select {
+			case <-s.closing:
+				return
+			case <-ctx.Done():
+				return
+			case <-sub.notify:
+				continue
+			}
+		}
+
+		select {
+		case <-s.closing:
+			sub.push(m)
+			return
+		case <-ctx.Done():
+			sub.push(m)
+			return
+		default:
+			// no-oop: responsibility of the caller
+		}
+
+		s.receive(ctx, sub, m, handler)
+	}
+}
+
+func (s *Subscriber) receive(ctx context.Context, sub *subscription, m *message, handler pubsub.EnvelopeHandler) {
+	m.deliveryAttempt++
+	env := m.envelope()
+
+	// recreate the context with traces
+	ctx = contextFromTracingAttributes(ctx, m.attributes)
+
+	// Add to the context the topic.
+	ctx = pubsub.WithTopic(ctx, sub.topic)
+
+	// Add to the context the delivery attempt.
+	ctx = pubsub.WithDeliveryAttempt(ctx, env.DeliveryAttempt)
+
+	// Add to the context the message ID.
+	ctx = pubsub.WithMessageID(ctx, env.ID)
+
+	// annotate the span
+	var span trace.Span
+	ctx, span = tracer.Start(ctx, fmt.Sprintf("Subscription %s/%s", sub.topic, sub.name))
+	span.SetAttributes(attribute.String("subscription", sub.name))
+	span.SetAttributes(attribute.String("topic", sub.topic))
+	defer span.End()
+
+	// the first of ack, nack or the ack deadline settles the message,
+	// the others become no-op.
+	var once sync.Once
+	deadline := time.AfterFunc(s.opts.ackDeadline, func() {
+		once.Do(func() {
+			sub.push(m)
+		})
+	})
+	ack := func() {
+		once.Do(func() {
+			deadline.Stop()
+		})
+	}
+	nack := func() {
+		once.Do(func() {
+			deadline.Stop()
+			s.redeliver(sub, m)
+		})
+	}
+
+	// Process the message
+	// in case of error, we record and label the error in the span.
+	if err := handler(ctx, env, ack, nack); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// redeliver puts back a nacked message on the subscription after the nack delay.
+func (s *Subscriber) redeliver(sub *subscription, m *message) {
+	if s.opts.nackDelay <= 0 {
+		sub.push(m)
+		return
+	}
+	time.AfterFunc(s.opts.nackDelay, func() {
+		sub.push(m)
+	})
+}
+
+func (s *Subscriber) setClosed(value bool) {
+	s.closedLock.Lock()
+	defer s.closedLock.Unlock()
+
+	s.closed = value
+}
+
+func (s *Subscriber) isClosed() bool {
+	s.closedLock.Lock()
+	defer s.closedLock.Unlock()
+
+	return s.closed
+}
+
+// WithAckDeadline defines how long a delivered message can stay unacknowledged
+// before being redelivered.
+//
+// AckDeadline defaults 10 seconds.
+func WithAckDeadline(d time.Duration) SubscriberOption {
+	return func(o *subscriberOptions) {
+		if d > 0 {
+			o.ackDeadline = d
+		}
+	}
+}
+
+// WithNackDelay defines how long a nacked message waits before being redelivered.
+//
+// NackDelay defaults 0, nacked messages are redelivered immediately.
+func WithNackDelay(d time.Duration) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.nackDelay = d
+	}
+}
+
+// WithNumGoroutines defines the number of goroutines consuming each subscription,
+// and therefore how many messages can be processed concurrently.
+//
+// NumGoroutines defaults 1.
+func WithNumGoroutines(n int) SubscriberOption {
+	return func(o *subscriberOptions) {
+		if n > 0 {
+			o.numGoroutines = n
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package inmem
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishAndSubscribe(t *testing.T) {
+	broker := NewBroker()
+	assert.NoError(t, broker.CreateSubscription("sub-a", "topic"))
+	assert.NoError(t, broker.CreateSubscription("sub-b", "topic"))
+
+	p, err := NewPublisher(broker)
+	assert.NoError(t, err)
+	s, err := NewSubscriber(broker)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	chA := make(chan string, 1)
+	chB := make(chan string, 1)
+	err = s.Subscribe(ctx, "sub-a", func(ctx context.Context, msg pubsub.Message) error {
+		chA <- pubsub.GetTopic(ctx) + ":" + msg.String()
+		return nil
+	})
+	assert.NoError(t, err)
+	err = s.Subscribe(ctx, "sub-b", func(ctx context.Context, msg pubsub.Message) error {
+		chB <- pubsub.GetTopic(ctx) + ":" + msg.String()
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Publish(ctx, "topic", pubsub.Message("test")))
+
+	// every subscription of the topic receives the message.
+	for _, ch := range []chan string{chA, chB} {
+		select {
+		case result := <-ch:
+			assert.Equal(t, "topic:test", result)
+		case <-time.After(time.Second):
+			assert.Fail(t, "timeout waiting")
+		}
+	}
+}
+
+func TestSubscribeWithAckRedelivery(t *testing.T) {
+	broker := NewBroker()
+	assert.NoError(t, broker.CreateSubscription("sub", "topic"))
+
+	p, err := NewPublisher(broker)
+	assert.NoError(t, err)
+	s, err := NewSubscriber(broker, WithAckDeadline(50*time.Millisecond))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	deliveries := make(chan int, 3)
+	attempt := 0
+	err = s.SubscribeWithAck(ctx, "sub", func(ctx context.Context, msg pubsub.Message, ack func(), nack func()) error {
+		attempt++
+		deliveries <- attempt
+		switch attempt {
+		case 1:
+			// nacked messages are redelivered
+			nack()
+		case 2:
+			// messages neither acked nor nacked are redelivered after the ack deadline
+		default:
+			ack()
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Publish(ctx, "topic", pubsub.Message("test")))
+
+	for i := 1; i <= 3; i++ {
+		select {
+		case result := <-deliveries:
+			assert.Equal(t, i, result)
+		case <-time.After(time.Second):
+			assert.Fail(t, "timeout waiting")
+		}
+	}
+
+	// acked messages are not redelivered.
+	select {
+	case result := <-deliveries:
+		assert.Fail(t, "acked message redelivered", result)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestClosedStates(t *testing.T) {
+	broker := NewBroker()
+	assert.NoError(t, broker.CreateSubscription("sub", "topic"))
+
+	p, err := NewPublisher(broker)
+	assert.NoError(t, err)
+	s, err := NewSubscriber(broker)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	err = s.Subscribe(ctx, "unknown", func(ctx context.Context, msg pubsub.Message) error {
+		return nil
+	})
+	assert.Error(t, err)
+
+	assert.NoError(t, s.Close())
+	assert.NoError(t, s.Close())
+	err = s.Subscribe(ctx, "sub", func(ctx context.Context, msg pubsub.Message) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, pubsub.SubscriberCLosed)
+
+	assert.NoError(t, p.Close())
+	err = p.Publish(ctx, "topic", pubsub.Message("test"))
+	assert.ErrorIs(t, err, pubsub.PublisherClosed)
+}
+
+func TestSubscribeEnvelope(t *testing.T) {
+	broker := NewBroker()
+	assert.NoError(t, broker.CreateSubscription("sub", "topic"))
+
+	p, err := NewPublisher(broker)
+	assert.NoError(t, err)
+	s, err := NewSubscriber(broker)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ch := make(chan *pubsub.Envelope, 1)
+	err = s.SubscribeEnvelope(ctx, "sub", func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error {
+		ack()
+		ch <- env
+		return nil
+	})
+	assert.NoError(t, err)
+
+	env := &pubsub.Envelope{
+		Data:        pubsub.Message("test"),
+		OrderingKey: "key",
+		Headers: map[string]string{
+			pubsub.HeaderContentType: "text/plain",
+			// reserved headers cannot be overridden.
+			"topic": "other",
+		},
+	}
+	assert.NoError(t, p.PublishEnvelope(ctx, "topic", env))
+
+	select {
+	case result := <-ch:
+		assert.NotEmpty(t, result.ID)
+		assert.Equal(t, pubsub.Message("test"), result.Data)
+		assert.Equal(t, "key", result.OrderingKey)
+		assert.Equal(t, map[string]string{pubsub.HeaderContentType: "text/plain"}, result.Headers)
+		assert.False(t, result.PublishTime.IsZero())
+		assert.Equal(t, 1, result.DeliveryAttempt)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting")
+	}
+}
+
+func TestPublishEnvelopeReuse(t *testing.T) {
+	broker := NewBroker()
+	assert.NoError(t, broker.CreateSubscription("sub-a", "topic-a"))
+	assert.NoError(t, broker.CreateSubscription("sub-b", "topic-b"))
+
+	p, err := NewPublisher(broker)
+	assert.NoError(t, err)
+	s, err := NewSubscriber(broker)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids := make(chan string, 2)
+	for _, sub := range []string{"sub-a", "sub-b"} {
+		err = s.SubscribeEnvelope(ctx, sub, func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error {
+			ids <- env.ID
+			ack()
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+
+	// the same envelope is published on two topics.
+	env := &pubsub.Envelope{Data: pubsub.Message("test")}
+	assert.NoError(t, p.PublishEnvelope(ctx, "topic-a", env))
+	assert.NoError(t, p.PublishEnvelope(ctx, "topic-b", env))
+	assert.Empty(t, env.ID)
+	assert.True(t, env.PublishTime.IsZero())
+
+	var received []string
+	for i := 0; i < 2; i++ {
+		select {
+		case id := <-ids:
+			received = append(received, id)
+		case <-time.After(time.Second):
+			assert.Fail(t, "timeout waiting")
+		}
+	}
+	assert.Len(t, received, 2)
+	assert.NotEqual(t, received[0], received[1])
+}
@@ -6,6 +6,7 @@
 	"time"
 
 	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/id"
 	"github.com/mukhtarkv/workspace/kit/pubsub"
 	nats "github.com/nats-io/nats.go"
 	"go.opentelemetry.io/otel/attribute"
@@ -14,6 +15,7 @@
 )
 
 var _ pubsub.Publisher = (*Publisher)(nil)
+var _ pubsub.EnvelopePublisher = (*Publisher)(nil)
 
 // Publisher publishes a message on a NATS JetStream Stream's Pub/Sub topic.
 //
@@ -60,9 +62,22 @@ func (p *Publisher) Close() error {
 //
 // See https://docs.nats.io/nats-concepts/jetstream/streams to find out more about how NATS streams work.
 func (p *Publisher) Publish(ctx context.Context, topic string, msg pubsub.Message) error {
+	return p.PublishEnvelope(ctx, topic, &pubsub.Envelope{Data: msg})
+}
+
+// PublishEnvelope publishes a message and its metadata on a NATS Pub/Sub subject (topic).
+//
+// The envelope headers are sent as NATS headers, and the envelope ID as the `Nats-Msg-Id` header
+// which JetStream uses to discard duplicates within the stream duplicate window.
+// If the envelope has no ID, one is generated. The envelope of the caller is left untouched,
+// so it can be published again, or on other subjects.
+func (p *Publisher) PublishEnvelope(ctx context.Context, topic string, env *pubsub.Envelope) error {
 	if len(topic) == 0 {
 		return fmt.Errorf("topic is nil")
 	}
+	if env == nil {
+		return fmt.Errorf("envelope is nil")
+	}
 
 	var span trace.Span
 	_, span = tracer.Start(ctx, fmt.Sprintf("Publish %s", topic))
@@ -79,14 +94,31 @@ func (p *Publisher) Publish(ctx context.Context, topic string, msg pubsub.Messag
 		return err
 	}
 
+	// the defaults are set on a copy, the envelope of the caller may be reused.
+	env = env.Clone()
+	if len(env.ID) == 0 {
+		env.ID = id.New()
+	}
+	if env.PublishTime.IsZero() {
+		env.PublishTime = time.Now().UTC()
+	}
+
 	// Prepare headers that will be passed to the pubsub
+	// reserved headers take precedence over the user defined ones.
 	headers := make(map[string][]string)
+	for k, v := range env.Headers {
+		headers[k] = []string{v}
+	}
 	headers["subject"] = []string{topic}
+	headers[nats.MsgIdHdr] = []string{env.ID}
+	if len(env.OrderingKey) > 0 {
+		headers[orderingKeyHeader] = []string{env.OrderingKey}
+	}
 	tracingAttributes(span, headers)
 	natsMsg := &nats.Msg{
 		Subject: topic,
 		Header:  headers,
-		Data:    msg,
+		Data:    env.Data,
 	}
 
 	timeoutCtx, fn := context.WithTimeout(context.Background(), 5*time.Second)
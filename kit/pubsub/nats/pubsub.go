@@ -12,6 +12,20 @@
 // tracer represents a NATS pubsub tracer
 var tracer = otel.Tracer("kit/pubsub/nats")
 
+// orderingKeyHeader is the header carrying the envelope ordering key.
+const orderingKeyHeader = "ordering-key"
+
+// reservedHeaders are the headers used internally, they are not exposed as envelope headers.
+var reservedHeaders = map[string]struct{}{
+	"subject":         {},
+	"trace":           {},
+	"span":            {},
+	"trace-state":     {},
+	"trace-remote":    {},
+	orderingKeyHeader: {},
+	nats.MsgIdHdr:     {},
+}
+
 func tracingAttributes(span trace.Span, m map[string][]string) {
 
 	m["trace"] = []string{span.SpanContext().TraceID().String()}
@@ -14,6 +14,7 @@
 )
 
 var _ pubsub.Subscriber = (*Subscriber)(nil)
+var _ pubsub.EnvelopeSubscriber = (*Subscriber)(nil)
 
 // Subscriber is our wrapper around NATS subscription.
 // In current implementation, one Subscriber corresponds to one NATS subscription,
@@ -96,6 +97,18 @@ func (s *Subscriber) Subscribe(ctx context.Context, subscription string /* subje
 }
 
 func (s *Subscriber) SubscribeWithAck(ctx context.Context, subscription string /* subject */, handler pubsub.HandlerWithAck) error {
+	h := func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error {
+		return handler(ctx, env.Data, ack, nack)
+	}
+
+	return s.SubscribeEnvelope(ctx, subscription, h)
+}
+
+// SubscribeEnvelope consumes NATS Pub/Sub, handing the messages along with their metadata to the handler.
+//
+// The envelope ID is the `Nats-Msg-Id` header set by the publisher,
+// or the stream sequence when the message was published without it.
+func (s *Subscriber) SubscribeEnvelope(ctx context.Context, subscription string /* subject */, handler pubsub.EnvelopeHandler) error {
 	if s.nc.IsClosed() {
 		return fmt.Errorf("subscriber is closed")
 	}
@@ -121,7 +134,7 @@ func (s *Subscriber) SubscribeWithAck(ctx context.Context, subscription string /
 	return nil
 }
 
-func (s *Subscriber) receive(ctx context.Context, msg *nats.Msg, handler pubsub.HandlerWithAck) {
+func (s *Subscriber) receive(ctx context.Context, msg *nats.Msg, handler pubsub.EnvelopeHandler) {
 
 	select {
 	case <-s.closing:
@@ -144,6 +157,16 @@ func (s *Subscriber) receive(ctx context.Context, msg *nats.Msg, handler pubsub.
 	// Add to the context the topic (subject).
 	ctx = pubsub.WithTopic(ctx, msg.Subject)
 
+	env := envelope(msg)
+
+	// Add to the context the message ID.
+	ctx = pubsub.WithMessageID(ctx, env.ID)
+
+	// Add to the context the delivery attempt tracked by JetStream.
+	if env.DeliveryAttempt > 0 {
+		ctx = pubsub.WithDeliveryAttempt(ctx, env.DeliveryAttempt)
+	}
+
 	// annotate the span
 	var span trace.Span
 	ctx, span = tracer.Start(ctx, fmt.Sprintf("Subscription %s", msg.Subject))
@@ -159,13 +182,37 @@ func (s *Subscriber) receive(ctx context.Context, msg *nats.Msg, handler pubsub.
 
 	// Process the message
 	// in case of error, we record and label the error in the span.
-	err := handler(ctx, msg.Data, ack, nack)
+	err := handler(ctx, env, ack, nack)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 	}
 }
 
+// envelope creates an envelope from a NATS message and its JetStream metadata.
+func envelope(msg *nats.Msg) *pubsub.Envelope {
+	env := &pubsub.Envelope{
+		ID:          msg.Header.Get(nats.MsgIdHdr),
+		Data:        msg.Data,
+		OrderingKey: msg.Header.Get(orderingKeyHeader),
+	}
+	for k, v := range msg.Header {
+		if _, ok := reservedHeaders[k]; ok || len(v) == 0 {
+			continue
+		}
+		env.SetHeader(k, v[0])
+	}
+
+	if meta, err := msg.Metadata(); err == nil {
+		env.PublishTime = meta.Timestamp
+		env.DeliveryAttempt = int(meta.NumDelivered)
+		if len(env.ID) == 0 {
+			env.ID = fmt.Sprintf("%s:%d", meta.Stream, meta.Sequence.Stream)
+		}
+	}
+	return env
+}
+
 func (s *Subscriber) setClosed(value bool) {
 	s.closedLock.Lock()
 	defer s.closedLock.Unlock()
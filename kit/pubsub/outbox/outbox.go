@@ -0,0 +1,116 @@
+// Package outbox implements the transactional outbox pattern on top of kit/sql and kit/pubsub.
+//
+// Messages are enqueued in the outbox table within the same transaction as the domain changes,
+// and a Relay publishes them to the pubsub in the background, with at-least-once guarantees:
+//
+//	tx, _ := db.BeginTxx(ctx, nil)
+//	// ... write the domain changes with tx
+//	_ = outbox.Enqueue(ctx, tx, "todo.created", msg)
+//	_ = tx.Commit()
+//
+// Each message keeps the same ID across publish attempts, so consumers can discard duplicates.
+package outbox
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/id"
+	"github.com/mukhtarkv/workspace/kit/pubsub"
+	kitsql "github.com/mukhtarkv/workspace/kit/sql"
+)
+
+// channel is the Postgres notification channel used to wake up the relay.
+const channel = "outbox"
+
+//go:embed db
+var migrations embed.FS
+
+// Migrate creates or updates the outbox table.
+//
+// The migration version is tracked in the table `<service>_outbox_schema_migrations`,
+// separately from the service own migrations.
+func Migrate(db *sqlx.DB, service string) error {
+	if len(service) == 0 {
+		return errors.New("service name is required")
+	}
+	return kitsql.MigrateWithPath(db, migrations, service+"_outbox", "db")
+}
+
+// Enqueue adds a message to the outbox, it will be published to the topic by the Relay.
+//
+// To be published only if the transaction commits, q should be the transaction (sqlx.Tx)
+// in which the domain changes are written.
+func Enqueue(ctx context.Context, q kitsql.Queryable, topic string, msg pubsub.Message) error {
+	return EnqueueEnvelope(ctx, q, topic, &pubsub.Envelope{Data: msg})
+}
+
+// EnqueueEnvelope adds a message and its metadata to the outbox,
+// it will be published to the topic by the Relay.
+// If the envelope has no ID, one is generated. The envelope of the caller is left untouched.
+func EnqueueEnvelope(ctx context.Context, q kitsql.Queryable, topic string, env *pubsub.Envelope) error {
+	if len(topic) == 0 {
+		return errors.New("topic is nil")
+	}
+	if env == nil {
+		return errors.New("envelope is nil")
+	}
+
+	msgID := env.ID
+	if len(msgID) == 0 {
+		msgID = id.New()
+	}
+	headers := env.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	h, err := json.Marshal(headers)
+	if err != nil {
+		return errors.Wrap(err, "marshal outbox headers")
+	}
+	// the payload column is not nullable, an empty message is stored as an empty payload.
+	payload := []byte(env.Data)
+	if payload == nil {
+		payload = []byte{}
+	}
+
+	const insert = `INSERT INTO outbox (id, topic, payload, headers, ordering_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := q.ExecContext(ctx, insert, msgID, topic, payload, h, env.OrderingKey, time.Now().UTC()); err != nil {
+		return errors.Wrap(err, "enqueue outbox message")
+	}
+
+	// The notification is only delivered once the transaction commits.
+	if _, err := q.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, msgID); err != nil {
+		return errors.Wrap(err, "notify outbox relay")
+	}
+	return nil
+}
+
+// record is a row of the outbox table.
+type record struct {
+	ID          string    `db:"id"`
+	Topic       string    `db:"topic"`
+	Payload     []byte    `db:"payload"`
+	Headers     []byte    `db:"headers"`
+	OrderingKey string    `db:"ordering_key"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func (r *record) envelope() (*pubsub.Envelope, error) {
+	env := &pubsub.Envelope{
+		ID:          r.ID,
+		Data:        r.Payload,
+		OrderingKey: r.OrderingKey,
+	}
+	if len(r.Headers) > 0 {
+		if err := json.Unmarshal(r.Headers, &env.Headers); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal headers of outbox message %s", r.ID)
+		}
+	}
+	return env, nil
+}
@@ -0,0 +1,190 @@
+package outbox
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/id"
+	"github.com/mukhtarkv/workspace/kit/pubsub"
+	"github.com/mukhtarkv/workspace/kit/pubsub/inmem"
+	kitsql "github.com/mukhtarkv/workspace/kit/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+type publisherFunc func(ctx context.Context, topic string, msg pubsub.Message) error
+
+func (f publisherFunc) Publish(ctx context.Context, topic string, msg pubsub.Message) error {
+	return f(ctx, topic, msg)
+}
+
+func (f publisherFunc) Close() error {
+	return nil
+}
+
+func openDB(t *testing.T) *kitsql.TestingDB {
+	if os.Getenv("TESTINGDB_URL") == "" {
+		t.Skip("Skipping, no testing database setup via env variable TESTINGDB_URL")
+	}
+
+	var tdb kitsql.TestingDB
+	if !assert.NoError(t, tdb.Open()) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { tdb.Close() })
+
+	if !assert.NoError(t, Migrate(tdb.DB, "test")) {
+		t.FailNow()
+	}
+	return &tdb
+}
+
+func TestRelayPublishesCommittedMessages(t *testing.T) {
+	tdb := openDB(t)
+	ctx := context.Background()
+
+	broker := inmem.NewBroker()
+	assert.NoError(t, broker.CreateSubscription("sub", "topic"))
+
+	// a rolled back message is never published.
+	tx, err := tdb.BeginTxx(ctx, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, Enqueue(ctx, tx, "topic", pubsub.Message("rolled back")))
+	assert.NoError(t, tx.Rollback())
+
+	tx, err = tdb.BeginTxx(ctx, nil)
+	assert.NoError(t, err)
+	env := &pubsub.Envelope{ID: id.New(), Data: pubsub.Message("committed"), Headers: map[string]string{"key": "value"}}
+	assert.NoError(t, EnqueueEnvelope(ctx, tx, "topic", env))
+	assert.NoError(t, tx.Commit())
+
+	pub, err := inmem.NewPublisher(broker)
+	assert.NoError(t, err)
+	relay, err := NewRelay(tdb.DB, pub, WithBatchSize(1))
+	assert.NoError(t, err)
+	n, err := relay.Flush(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// already published messages are not published again.
+	n, err = relay.Flush(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	received := make(chan *pubsub.Envelope, 1)
+	sub, err := inmem.NewSubscriber(broker)
+	assert.NoError(t, err)
+	subCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	go sub.SubscribeEnvelope(subCtx, "sub", func(ctx context.Context, env *pubsub.Envelope, ack func(), nack func()) error { //nolint
+		ack()
+		received <- env
+		return nil
+	})
+
+	select {
+	case got := <-received:
+		assert.Equal(t, env.ID, got.ID)
+		assert.Equal(t, pubsub.Message("committed"), got.Data)
+		assert.Equal(t, "value", got.Header("key"))
+	case <-subCtx.Done():
+		t.Fatal("message not received")
+	}
+}
+
+func TestRelayRetriesFailedMessages(t *testing.T) {
+	tdb := openDB(t)
+	ctx := context.Background()
+
+	for _, msg := range []string{"first", "second"} {
+		assert.NoError(t, Enqueue(ctx, tdb.DB, "topic", pubsub.Message(msg)))
+	}
+
+	var published []string
+	fail := true
+	relay, err := NewRelay(tdb.DB, publisherFunc(func(ctx context.Context, topic string, msg pubsub.Message) error {
+		if fail {
+			return errors.New("unavailable")
+		}
+		published = append(published, string(msg))
+		return nil
+	}), WithRetention(time.Nanosecond, time.Hour))
+	assert.NoError(t, err)
+
+	n, err := relay.Flush(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+
+	var lastError string
+	assert.NoError(t, tdb.GetContext(ctx, &lastError, `SELECT last_error FROM outbox ORDER BY created_at LIMIT 1`))
+	assert.Equal(t, "unavailable", lastError)
+
+	fail = false
+	n, err = relay.Flush(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"first", "second"}, published)
+
+	deleted, err := relay.Cleanup(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+}
+
+func TestRelayBlocksOnlyTheOrderingKeyOfFailedMessages(t *testing.T) {
+	tdb := openDB(t)
+	ctx := context.Background()
+
+	for _, env := range []*pubsub.Envelope{
+		{Data: pubsub.Message("poison"), OrderingKey: "a"},
+		{Data: pubsub.Message("a-second"), OrderingKey: "a"},
+		{Data: pubsub.Message("b-first"), OrderingKey: "b"},
+		{Data: nil},
+	} {
+		assert.NoError(t, EnqueueEnvelope(ctx, tdb.DB, "topic", env))
+	}
+
+	var published []string
+	relay, err := NewRelay(tdb.DB, publisherFunc(func(ctx context.Context, topic string, msg pubsub.Message) error {
+		if string(msg) == "poison" {
+			return errors.New("rejected")
+		}
+		published = append(published, string(msg))
+		return nil
+	}), WithMaxAttempts(2))
+	assert.NoError(t, err)
+
+	// the messages of the other keys are published, the ones of the failed key wait.
+	n, err := relay.Flush(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"b-first", ""}, published)
+
+	// once dead, the failed message is no longer retried and its key is unblocked.
+	n, err = relay.Flush(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	n, err = relay.Flush(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"b-first", "", "a-second"}, published)
+
+	var dead struct {
+		Attempts  int    `db:"attempts"`
+		LastError string `db:"last_error"`
+	}
+	assert.NoError(t, tdb.GetContext(ctx, &dead, `SELECT attempts, last_error FROM outbox WHERE dead_at IS NOT NULL`))
+	assert.Equal(t, 2, dead.Attempts)
+	assert.Equal(t, "rejected", dead.LastError)
+
+	// dead messages are kept by the cleanup, unless they have a retention.
+	deleted, err := relay.Cleanup(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+	relay, err = NewRelay(tdb.DB, relay.publisher, WithRetention(0, time.Hour), WithDeadRetention(time.Nanosecond))
+	assert.NoError(t, err)
+	deleted, err = relay.Cleanup(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
@@ -46,3 +46,47 @@ func GetTopic(ctx context.Context) string {
 	}
 	return subject
 }
+
+// Context type for delivery attempt
+type deliveryAttemptCtxKeyType string
+
+const deliveryAttemptCtxKey deliveryAttemptCtxKeyType = "delivery-attempt"
+
+// WithDeliveryAttempt inject to the given context the number of times
+// the message being handled has been delivered, including the current delivery.
+func WithDeliveryAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, deliveryAttemptCtxKey, attempt)
+}
+
+// GetDeliveryAttempt get the delivery attempt from the context.
+// If the context doesnt have a deliveryAttemptCtxKey set, meaning the backend
+// does not track delivery attempts, then the value returned will be zero.
+func GetDeliveryAttempt(ctx context.Context) int {
+	attempt, ok := ctx.Value(deliveryAttemptCtxKey).(int)
+	if !ok {
+		return 0
+	}
+	return attempt
+}
+
+// Context type for message ID
+type messageIDCtxKeyType string
+
+const messageIDCtxKey messageIDCtxKeyType = "message-id"
+
+// WithMessageID inject to the given context the ID of the message being handled,
+// as assigned by the backend or the publisher (see Envelope.ID).
+func WithMessageID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, messageIDCtxKey, id)
+}
+
+// GetMessageID get the message ID from the context.
+// If the context doesnt have a messageIDCtxKey set,
+// then the value returned will be an empty string.
+func GetMessageID(ctx context.Context) string {
+	id, ok := ctx.Value(messageIDCtxKey).(string)
+	if !ok {
+		return ""
+	}
+	return id
+}
@@ -13,3 +13,25 @@ func TestCtx(t *testing.T) {
 
 	assert.Equal(t, "a.topic", topic)
 }
+
+func TestDeliveryAttemptCtx(t *testing.T) {
+	assert.Equal(t, 0, GetDeliveryAttempt(context.Background()))
+
+	ctx := WithDeliveryAttempt(context.Background(), 3)
+	assert.Equal(t, 3, GetDeliveryAttempt(ctx))
+}
+
+func TestMessageIDCtx(t *testing.T) {
+	assert.Equal(t, "", GetMessageID(context.Background()))
+
+	ctx := WithMessageID(context.Background(), "42")
+	assert.Equal(t, "42", GetMessageID(ctx))
+}
+
+func TestEnvelopeHeader(t *testing.T) {
+	env := Envelope{}
+	assert.Equal(t, "", env.Header(HeaderContentType))
+
+	env.SetHeader(HeaderContentType, "application/json")
+	assert.Equal(t, "application/json", env.Header(HeaderContentType))
+}
@@ -0,0 +1,236 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+)
+
+// RetryOption defines a retry policy option.
+type RetryOption func(*retryPolicy)
+
+// retryPolicy provides a set of configurable options for WithRetry.
+type retryPolicy struct {
+	maxAttempts     int
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	multiplier      float64
+	deadLetter      Publisher
+	deadLetterTopic string
+}
+
+// DeadLetter is the message published to the dead letter topic
+// once a message exhausted its delivery attempts.
+type DeadLetter struct {
+	// ID is the ID of the original message, if known.
+	ID string `json:"id,omitempty"`
+	// Topic is the topic the original message was published to.
+	Topic string `json:"topic"`
+	// Headers are the headers of the original message, if any.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Payload is the original message.
+	Payload Message `json:"payload"`
+	// Error is the error returned by the handler on the last attempt.
+	Error string `json:"error"`
+	// DeliveryAttempt is the number of times the message has been handled.
+	DeliveryAttempt int `json:"delivery_attempt"`
+	// FailedAt is the time the last attempt failed.
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// UnmarshalDeadLetter decodes a message consumed from a dead letter topic.
+func UnmarshalDeadLetter(msg Message) (*DeadLetter, error) {
+	var dl DeadLetter
+	if err := json.Unmarshal(msg, &dl); err != nil {
+		return nil, errors.Wrap(err, "unmarshal dead letter")
+	}
+	return &dl, nil
+}
+
+// WithRetry wraps a HandlerWithAck with a retry and dead letter policy.
+//
+// When the handler returns an error without having acked or nacked the message:
+//
+//   - if the error is MessageMalformed (see DecodeError), retrying is pointless and the message
+//     is dead lettered right away;
+//   - if the backend tracks delivery attempts (see GetDeliveryAttempt), the message is nacked
+//     after an exponential backoff so the backend redelivers it;
+//   - otherwise, the handler is retried in place with an exponential backoff.
+//
+// Once the message failed on its last attempt, it is published with the error metadata
+// to the dead letter topic (see WithDeadLetter) and acked.
+// If no dead letter topic is configured, the message is acked and dropped.
+// If publishing to the dead letter topic fails, the message is nacked.
+//
+//	sub.SubscribeWithAck(ctx, "my-subscription", pubsub.WithRetry(handler,
+//		pubsub.WithMaxAttempts(5),
+//		pubsub.WithDeadLetter(publisher, "my-topic.dead-letter"),
+//	))
+func WithRetry(handler HandlerWithAck, opts ...RetryOption) HandlerWithAck {
+	h := WithEnvelopeRetry(func(ctx context.Context, env *Envelope, ack func(), nack func()) error {
+		return handler(ctx, env.Data, ack, nack)
+	}, opts...)
+
+	return func(ctx context.Context, msg Message, ack func(), nack func()) error {
+		return h(ctx, &Envelope{Data: msg}, ack, nack)
+	}
+}
+
+// WithEnvelopeRetry wraps an EnvelopeHandler with a retry and dead letter policy.
+// It behaves as WithRetry, and the dead letter also carries the ID and headers of the message.
+func WithEnvelopeRetry(handler EnvelopeHandler, opts ...RetryOption) EnvelopeHandler {
+	// default policy
+	policy := &retryPolicy{
+		maxAttempts:     5,
+		initialInterval: 100 * time.Millisecond,
+		maxInterval:     10 * time.Second,
+		multiplier:      2,
+	}
+	for _, o := range opts {
+		o(policy)
+	}
+
+	return func(ctx context.Context, env *Envelope, ack func(), nack func()) error {
+		attempt := GetDeliveryAttempt(ctx)
+		backendAttempts := attempt > 0
+		if !backendAttempts {
+			attempt = 1
+		}
+
+		for {
+			s := &settlement{ack: ack, nack: nack}
+			err := handler(ctx, env, s.doAck, s.doNack)
+
+			// the handler succeeded or took responsibility for the message.
+			if err == nil || s.isSettled() {
+				return err
+			}
+
+			if attempt >= policy.maxAttempts || errors.Is(err, MessageMalformed) {
+				return policy.deadLetterMessage(ctx, env, attempt, err, ack, nack)
+			}
+
+			// wait before the next attempt, unless the handler is being stopped.
+			select {
+			case <-ctx.Done():
+				nack()
+				return err
+			case <-time.After(policy.backoff(attempt)):
+			}
+
+			// let the backend redeliver the message.
+			if backendAttempts {
+				nack()
+				return err
+			}
+			attempt++
+		}
+	}
+}
+
+// backoff returns the time to wait after the given failed attempt.
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	interval := float64(p.initialInterval)
+	for i := 1; i < attempt; i++ {
+		interval *= p.multiplier
+		if interval >= float64(p.maxInterval) {
+			return p.maxInterval
+		}
+	}
+	return time.Duration(interval)
+}
+
+func (p *retryPolicy) deadLetterMessage(ctx context.Context, env *Envelope, attempt int, err error, ack func(), nack func()) error {
+	if p.deadLetter == nil {
+		ack()
+		return errors.Wrapf(err, "message dropped after %d attempts", attempt)
+	}
+
+	b, mErr := json.Marshal(DeadLetter{
+		ID:              env.ID,
+		Topic:           GetTopic(ctx),
+		Headers:         env.Headers,
+		Payload:         env.Data,
+		Error:           err.Error(),
+		DeliveryAttempt: attempt,
+		FailedAt:        time.Now().UTC(),
+	})
+	if mErr != nil {
+		nack()
+		return errors.Wrap(mErr, "marshal dead letter")
+	}
+
+	if pErr := p.deadLetter.Publish(ctx, p.deadLetterTopic, b); pErr != nil {
+		nack()
+		return errors.Wrapf(pErr, "publish to dead letter topic %s", p.deadLetterTopic)
+	}
+
+	ack()
+	return errors.Wrapf(err, "message sent to dead letter topic %s after %d attempts", p.deadLetterTopic, attempt)
+}
+
+// settlement records whether the handler acked or nacked the message.
+type settlement struct {
+	ack     func()
+	nack    func()
+	settled bool
+	mu      sync.Mutex
+}
+
+func (s *settlement) doAck() {
+	s.mu.Lock()
+	s.settled = true
+	s.mu.Unlock()
+	s.ack()
+}
+
+func (s *settlement) doNack() {
+	s.mu.Lock()
+	s.settled = true
+	s.mu.Unlock()
+	s.nack()
+}
+
+func (s *settlement) isSettled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settled
+}
+
+// WithMaxAttempts defines the number of times a message is handled before being dead lettered.
+//
+// MaxAttempts defaults 5.
+func WithMaxAttempts(n int) RetryOption {
+	return func(p *retryPolicy) {
+		if n > 0 {
+			p.maxAttempts = n
+		}
+	}
+}
+
+// WithBackoff defines the exponential backoff between two attempts.
+// The first retry waits initial, and each following retry waits multiplier times longer,
+// up to max.
+//
+// Backoff defaults to 100ms initial interval, 10s max interval and a multiplier of 2.
+func WithBackoff(initial time.Duration, max time.Duration, multiplier float64) RetryOption {
+	return func(p *retryPolicy) {
+		p.initialInterval = initial
+		p.maxInterval = max
+		if multiplier >= 1 {
+			p.multiplier = multiplier
+		}
+	}
+}
+
+// WithDeadLetter defines the publisher and topic where messages are sent
+// once they exhausted their delivery attempts.
+func WithDeadLetter(publisher Publisher, topic string) RetryOption {
+	return func(p *retryPolicy) {
+		p.deadLetter = publisher
+		p.deadLetterTopic = topic
+	}
+}
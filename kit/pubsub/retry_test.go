@@ -0,0 +1,95 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type publisherFunc func(ctx context.Context, topic string, msg Message) error
+
+func (f publisherFunc) Publish(ctx context.Context, topic string, msg Message) error {
+	return f(ctx, topic, msg)
+}
+
+func TestWithRetryInPlace(t *testing.T) {
+	var published []Message
+	dlq := publisherFunc(func(ctx context.Context, topic string, msg Message) error {
+		assert.Equal(t, "dlq", topic)
+		published = append(published, msg)
+		return nil
+	})
+
+	calls := 0
+	h := WithRetry(func(ctx context.Context, msg Message, ack func(), nack func()) error {
+		calls++
+		return errors.New("boom")
+	}, WithMaxAttempts(3), WithBackoff(time.Millisecond, time.Millisecond, 2), WithDeadLetter(dlq, "dlq"))
+
+	acked, nacked := 0, 0
+	ctx := WithTopic(context.Background(), "topic")
+	err := h(ctx, Message("payload"), func() { acked++ }, func() { nacked++ })
+
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 1, acked)
+	assert.Equal(t, 0, nacked)
+	if assert.Len(t, published, 1) {
+		dl, err := UnmarshalDeadLetter(published[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "topic", dl.Topic)
+		assert.Equal(t, Message("payload"), dl.Payload)
+		assert.Equal(t, "boom", dl.Error)
+		assert.Equal(t, 3, dl.DeliveryAttempt)
+	}
+}
+
+func TestWithRetryBackendAttempts(t *testing.T) {
+	h := WithRetry(func(ctx context.Context, msg Message, ack func(), nack func()) error {
+		return errors.New("boom")
+	}, WithMaxAttempts(3), WithBackoff(time.Millisecond, time.Millisecond, 2))
+
+	// not the last attempt, the message is nacked to be redelivered by the backend.
+	acked, nacked := 0, 0
+	ctx := WithDeliveryAttempt(context.Background(), 2)
+	err := h(ctx, Message("payload"), func() { acked++ }, func() { nacked++ })
+	assert.Error(t, err)
+	assert.Equal(t, 0, acked)
+	assert.Equal(t, 1, nacked)
+
+	// last attempt without dead letter topic, the message is dropped.
+	acked, nacked = 0, 0
+	ctx = WithDeliveryAttempt(context.Background(), 3)
+	err = h(ctx, Message("payload"), func() { acked++ }, func() { nacked++ })
+	assert.Error(t, err)
+	assert.Equal(t, 1, acked)
+	assert.Equal(t, 0, nacked)
+}
+
+func TestWithRetrySettledByHandler(t *testing.T) {
+	calls := 0
+	h := WithRetry(func(ctx context.Context, msg Message, ack func(), nack func()) error {
+		calls++
+		nack()
+		return errors.New("boom")
+	}, WithMaxAttempts(3))
+
+	acked, nacked := 0, 0
+	err := h(context.Background(), Message("payload"), func() { acked++ }, func() { nacked++ })
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 0, acked)
+	assert.Equal(t, 1, nacked)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := &retryPolicy{initialInterval: time.Second, maxInterval: 5 * time.Second, multiplier: 2}
+
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 4*time.Second, p.backoff(3))
+	assert.Equal(t, 5*time.Second, p.backoff(4))
+}
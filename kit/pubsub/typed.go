@@ -0,0 +1,164 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+)
+
+// validator is implemented by the messages generated by protoc-gen-validate.
+type validator interface {
+	Validate() error
+}
+
+// DecodeError is returned when a consumed message cannot be decoded or is not valid.
+//
+// Retrying such a message will never succeed, errors.Is(err, MessageMalformed)
+// reports whether an error is a DecodeError, and WithRetry dead letters them
+// without retrying.
+type DecodeError struct {
+	ContentType string
+	Err         error
+}
+
+// Error returns the error message.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decoding message (%s): %v", e.ContentType, e.Err)
+}
+
+// Unwrap returns the decoding or validation error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is MessageMalformed.
+func (e *DecodeError) Is(target error) bool {
+	return target == MessageMalformed
+}
+
+// TypedPublisher publishes values of type T to a topic.
+//
+// Values are encoded with the publisher codec and stamped with its content type header.
+// If the value implements `Validate() error`, as protoc-gen-validate messages do,
+// it is validated before being published.
+type TypedPublisher[T any] struct {
+	publisher EnvelopePublisher
+	topic     string
+	codec     Codec
+}
+
+// NewTypedPublisher creates a new TypedPublisher publishing to the given topic.
+func NewTypedPublisher[T any](publisher EnvelopePublisher, topic string, codec Codec) (*TypedPublisher[T], error) {
+	if publisher == nil {
+		return nil, errors.New("publisher is nil")
+	}
+	if len(topic) == 0 {
+		return nil, errors.New("topic is nil")
+	}
+	if codec == nil {
+		return nil, errors.New("codec is nil")
+	}
+
+	return &TypedPublisher[T]{
+		publisher: publisher,
+		topic:     topic,
+		codec:     codec,
+	}, nil
+}
+
+// Publish validates, encodes and publishes the value.
+func (p *TypedPublisher[T]) Publish(ctx context.Context, v T) error {
+	return p.PublishEnvelope(ctx, v, &Envelope{})
+}
+
+// PublishEnvelope validates, encodes and publishes the value with the metadata of the given envelope.
+// The envelope data and content type header are overridden on a copy, the envelope of the caller is left untouched.
+func (p *TypedPublisher[T]) PublishEnvelope(ctx context.Context, v T, env *Envelope) error {
+	if env == nil {
+		return errors.New("envelope is nil")
+	}
+	if err := validate(v); err != nil {
+		return errors.Wrap(err, "invalid message")
+	}
+
+	b, err := p.codec.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "encoding message")
+	}
+
+	env = env.Clone()
+	env.Data = b
+	env.SetHeader(HeaderContentType, p.codec.ContentType())
+	return p.publisher.PublishEnvelope(ctx, p.topic, env)
+}
+
+// TypedHandler is the handler used to invoke the app handler with the decoded message.
+type TypedHandler[T any] func(ctx context.Context, msg T, ack func(), nack func()) error
+
+// NewTypedHandler adapts a TypedHandler into an EnvelopeHandler.
+//
+// The message is decoded with the codec matching its content type header,
+// or with the given codec when the header is missing. If the decoded value implements
+// `Validate() error`, it is validated before the handler is invoked.
+//
+// When the message cannot be decoded or is not valid, the handler is not invoked and
+// a DecodeError is returned, leaving the message unacknowledged so a retry policy can act on it:
+//
+//	sub.SubscribeEnvelope(ctx, "my-subscription", pubsub.WithEnvelopeRetry(
+//		pubsub.NewTypedHandler[*pb.Event](handler, pubsub.Protobuf),
+//		pubsub.WithDeadLetter(publisher, "my-topic.dead-letter"),
+//	))
+func NewTypedHandler[T any](handler TypedHandler[T], codec Codec) EnvelopeHandler {
+	return func(ctx context.Context, env *Envelope, ack func(), nack func()) error {
+		c := codec
+		contentType := env.Header(HeaderContentType)
+		if len(contentType) > 0 {
+			var ok bool
+			c, ok = CodecFor(contentType)
+			if !ok {
+				return &DecodeError{ContentType: contentType, Err: errors.New("unsupported content type")}
+			}
+		}
+
+		v, err := decode[T](c, env.Data)
+		if err != nil {
+			return &DecodeError{ContentType: c.ContentType(), Err: err}
+		}
+		if err := validate(v); err != nil {
+			return &DecodeError{ContentType: c.ContentType(), Err: err}
+		}
+
+		return handler(ctx, v, ack, nack)
+	}
+}
+
+// decode decodes the data into a new value of type T.
+// If T is a pointer type, the value it points to is allocated.
+func decode[T any](codec Codec, data []byte) (T, error) {
+	var v T
+	typ := reflect.TypeOf(v)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		v = reflect.New(typ.Elem()).Interface().(T)
+		return v, codec.Unmarshal(data, v)
+	}
+	return v, codec.Unmarshal(data, &v)
+}
+
+// validate validates the value if it implements the validator interface.
+func validate(v interface{}) error {
+	if val, ok := v.(validator); ok {
+		return val.Validate()
+	}
+	// the Validate method might be defined on the pointer receiver.
+	rv := reflect.ValueOf(v)
+	if rv.IsValid() && rv.Kind() != reflect.Ptr {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		if val, ok := ptr.Interface().(validator); ok {
+			return val.Validate()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mukhtarkv/workspace/api/errdetails"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+type envelopePublisherFunc func(ctx context.Context, topic string, env *Envelope) error
+
+func (f envelopePublisherFunc) PublishEnvelope(ctx context.Context, topic string, env *Envelope) error {
+	return f(ctx, topic, env)
+}
+
+type event struct {
+	Name string
+}
+
+func (e event) Validate() error {
+	if len(e.Name) == 0 {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestTypedPublishAndHandle(t *testing.T) {
+	for _, codec := range []Codec{JSON, Msgpack} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			var published *Envelope
+			p, err := NewTypedPublisher[event](envelopePublisherFunc(func(ctx context.Context, topic string, env *Envelope) error {
+				assert.Equal(t, "topic", topic)
+				published = env
+				return nil
+			}), "topic", codec)
+			assert.NoError(t, err)
+
+			ctx := context.Background()
+			assert.NoError(t, p.Publish(ctx, event{Name: "created"}))
+			assert.Equal(t, codec.ContentType(), published.Header(HeaderContentType))
+
+			// the envelope of the caller is left untouched.
+			env := &Envelope{Headers: map[string]string{"key": "value"}}
+			assert.NoError(t, p.PublishEnvelope(ctx, event{Name: "created"}, env))
+			assert.Nil(t, env.Data)
+			assert.Equal(t, map[string]string{"key": "value"}, env.Headers)
+			assert.Equal(t, "value", published.Header("key"))
+
+			// invalid values are not published.
+			err = p.Publish(ctx, event{})
+			assert.Error(t, err)
+
+			var received event
+			h := NewTypedHandler[event](func(ctx context.Context, msg event, ack func(), nack func()) error {
+				received = msg
+				return nil
+			}, Protobuf)
+			// the content type header takes precedence over the default codec.
+			assert.NoError(t, h(ctx, published, func() {}, func() {}))
+			assert.Equal(t, event{Name: "created"}, received)
+		})
+	}
+}
+
+func TestTypedProtobuf(t *testing.T) {
+	var published *Envelope
+	p, err := NewTypedPublisher[*errdetails.ErrorInfo](envelopePublisherFunc(func(ctx context.Context, topic string, env *Envelope) error {
+		published = env
+		return nil
+	}), "topic", Protobuf)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	info := &errdetails.ErrorInfo{Reason: "REASON", Metadata: map[string]string{"key": "value"}}
+	assert.NoError(t, p.Publish(ctx, info))
+	assert.Equal(t, ContentTypeProtobuf, published.Header(HeaderContentType))
+
+	var received *errdetails.ErrorInfo
+	h := NewTypedHandler[*errdetails.ErrorInfo](func(ctx context.Context, msg *errdetails.ErrorInfo, ack func(), nack func()) error {
+		received = msg
+		return nil
+	}, Protobuf)
+	assert.NoError(t, h(ctx, published, func() {}, func() {}))
+	assert.True(t, proto.Equal(info, received))
+}
+
+func TestTypedHandlerDecodeError(t *testing.T) {
+	called := false
+	h := NewTypedHandler[event](func(ctx context.Context, msg event, ack func(), nack func()) error {
+		called = true
+		return nil
+	}, JSON)
+
+	ctx := context.Background()
+	cases := map[string]*Envelope{
+		"malformed payload":    {Data: Message("{")},
+		"invalid payload":      {Data: Message(`{"Name":""}`)},
+		"unknown content type": {Data: Message(`{"Name":"created"}`), Headers: map[string]string{HeaderContentType: "text/plain"}},
+	}
+	for name, env := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := h(ctx, env, func() {}, func() {})
+			assert.ErrorIs(t, err, MessageMalformed)
+			var decodeErr *DecodeError
+			assert.True(t, errors.As(err, &decodeErr))
+			assert.False(t, called)
+		})
+	}
+}
+
+func TestWithEnvelopeRetryMalformed(t *testing.T) {
+	var published []Message
+	dlq := publisherFunc(func(ctx context.Context, topic string, msg Message) error {
+		published = append(published, msg)
+		return nil
+	})
+
+	h := WithEnvelopeRetry(NewTypedHandler[event](func(ctx context.Context, msg event, ack func(), nack func()) error {
+		return nil
+	}, JSON), WithMaxAttempts(5), WithDeadLetter(dlq, "dlq"))
+
+	acked := 0
+	env := &Envelope{ID: "42", Data: Message("{")}
+	err := h(context.Background(), env, func() { acked++ }, func() {})
+	assert.ErrorIs(t, err, MessageMalformed)
+	assert.Equal(t, 1, acked)
+	if assert.Len(t, published, 1) {
+		dl, err := UnmarshalDeadLetter(published[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "42", dl.ID)
+		assert.Equal(t, 1, dl.DeliveryAttempt)
+	}
+}
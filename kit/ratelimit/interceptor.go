@@ -0,0 +1,202 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mukhtarkv/workspace/api/errdetails"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ReasonRateLimitExceeded is the reason of the errdetails.ErrorInfo of the requests over the limit.
+const ReasonRateLimitExceeded = "RATE_LIMIT_EXCEEDED"
+
+// RetryAfterKey is the metadata key, and HTTP header, holding the seconds to wait before retrying
+// a request over the limit.
+const RetryAfterKey = "retry-after"
+
+// KeyFunc returns the key identifying the requests limited together,
+// from the context of the request and the full method name of the gRPC call.
+type KeyFunc func(ctx context.Context, method string) string
+
+// ByMethod limits the requests per method.
+func ByMethod(_ context.Context, method string) string {
+	return method
+}
+
+// ByPeer limits the requests per client IP address.
+//
+// The grpc-gateway forwards the calls from the loopback address or the in-memory bufconn listener.
+// These calls are limited by the last address of their x-forwarded-for metadata, the one appended by the gateway.
+// The addresses before it are sent by the client and cannot be trusted.
+func ByPeer(ctx context.Context, _ string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+
+	ip := net.ParseIP(host)
+	if p.Addr.Network() == "bufconn" || (ip != nil && ip.IsLoopback()) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("x-forwarded-for"); len(values) > 0 {
+				addrs := strings.Split(values[len(values)-1], ",")
+				return strings.TrimSpace(addrs[len(addrs)-1])
+			}
+		}
+	}
+	return host
+}
+
+// ByMetadata limits the requests per value of the metadata key, such as an API key or a tenant.
+// The requests without the metadata share the same limit.
+func ByMetadata(key string) KeyFunc {
+	return func(ctx context.Context, _ string) string {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return ""
+		}
+		return strings.Join(md.Get(key), ",")
+	}
+}
+
+// Option defines a rate limit interceptor option.
+type Option func(*options)
+
+// options provides a set of configurable options for the rate limit interceptors.
+type options struct {
+	keys []KeyFunc
+}
+
+// WithKey defines the keys identifying the requests limited together,
+// they are combined when several are given, e.g. WithKey(ByPeer, ByMethod) limits each client per method.
+//
+// Key defaults to ByPeer.
+func WithKey(keys ...KeyFunc) Option {
+	return func(o *options) {
+		if len(keys) > 0 {
+			o.keys = keys
+		}
+	}
+}
+
+func newOptions(opts []Option) options {
+	// default options
+	o := options{
+		keys: []KeyFunc{ByPeer},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// key returns the key of the request.
+func (o options) key(ctx context.Context, method string) string {
+	parts := make([]string, 0, len(o.keys))
+	for _, fn := range o.keys {
+		parts = append(parts, fn(ctx, method))
+	}
+	return strings.Join(parts, "|")
+}
+
+// allow checks the limit of the request, the requests are allowed when the limiter fails.
+func allow(ctx context.Context, l Limiter, key string) Result {
+	res, err := l.Allow(ctx, key)
+	if err != nil {
+		log.L().Warn(ctx, "rate limiter failed, request allowed", log.Error(err))
+		return Result{Allowed: true}
+	}
+	return res
+}
+
+// retryAfter returns the seconds to wait before retrying, rounded up.
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(d.Seconds()))))
+}
+
+// exhausted returns the ResourceExhausted error of a request over the limit.
+func exhausted(res Result) error {
+	return errors.Status(codes.ResourceExhausted, "rate limit exceeded", &errdetails.ErrorInfo{
+		Reason: ReasonRateLimitExceeded,
+		Metadata: map[string]string{
+			"limit":       strconv.Itoa(res.Limit),
+			"retry_after": retryAfter(res.RetryAfter),
+		},
+	})
+}
+
+// UnaryServerInterceptor returns a unary server interceptor rejecting the calls over the limit
+// with a ResourceExhausted error, and the retry-after header metadata.
+func UnaryServerInterceptor(l Limiter, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		res := allow(ctx, l, o.key(ctx, info.FullMethod))
+		if !res.Allowed {
+			_ = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterKey, retryAfter(res.RetryAfter))) //nolint
+			return nil, exhausted(res)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a stream server interceptor rejecting the streams over the limit
+// with a ResourceExhausted error, and the retry-after header metadata.
+func StreamServerInterceptor(l Limiter, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		res := allow(ctx, l, o.key(ctx, info.FullMethod))
+		if !res.Allowed {
+			_ = ss.SetHeader(metadata.Pairs(RetryAfterKey, retryAfter(res.RetryAfter))) //nolint
+			return exhausted(res)
+		}
+		return handler(srv, ss)
+	}
+}
+
+// httpAddr is the net.Addr of an HTTP client.
+type httpAddr string
+
+func (a httpAddr) Network() string { return "tcp" }
+func (a httpAddr) String() string  { return string(a) }
+
+// Middleware returns an HTTP middleware rejecting the requests over the limit with a 429 status
+// and the Retry-After header.
+//
+// The keys are computed as for gRPC: the method is the HTTP method and path, the peer is the remote address
+// and the metadata are the request headers.
+func Middleware(l Limiter, opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			md := metadata.MD{}
+			for name, values := range r.Header {
+				md.Append(name, values...)
+			}
+			ctx := peer.NewContext(r.Context(), &peer.Peer{Addr: httpAddr(r.RemoteAddr)})
+			ctx = metadata.NewIncomingContext(ctx, md)
+
+			res := allow(r.Context(), l, o.key(ctx, r.Method+" "+r.URL.Path))
+			if !res.Allowed {
+				w.Header().Set(RetryAfterKey, retryAfter(res.RetryAfter))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
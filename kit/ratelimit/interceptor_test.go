@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/api/errdetails"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// limiterFunc is a Limiter calling the function.
+type limiterFunc func(ctx context.Context, key string) (Result, error)
+
+func (f limiterFunc) Allow(ctx context.Context, key string) (Result, error) {
+	return f(ctx, key)
+}
+
+func peerContext(addr string) context.Context {
+	tcp, _ := net.ResolveTCPAddr("tcp", addr)
+	return peer.NewContext(context.TODO(), &peer.Peer{Addr: tcp})
+}
+
+func TestKeys(t *testing.T) {
+	ctx := metadata.NewIncomingContext(peerContext("10.0.0.1:5000"), metadata.Pairs("x-api-key", "secret"))
+	assert.Equal(t, "10.0.0.1", ByPeer(ctx, "/svc/Method"))
+	assert.Equal(t, "/svc/Method", ByMethod(ctx, "/svc/Method"))
+	assert.Equal(t, "secret", ByMetadata("x-api-key")(ctx, "/svc/Method"))
+	assert.Equal(t, "", ByMetadata("x-tenant")(ctx, "/svc/Method"))
+
+	// the calls of the gateway are limited per address appended by the gateway.
+	gw := metadata.NewIncomingContext(peerContext("127.0.0.1:5000"), metadata.Pairs("x-forwarded-for", "10.0.0.2"))
+	assert.Equal(t, "10.0.0.2", ByPeer(gw, "/svc/Method"))
+
+	// the addresses sent by the client don't change the key.
+	spoofed := metadata.NewIncomingContext(peerContext("127.0.0.1:5000"), metadata.Pairs("x-forwarded-for", "1.2.3.4, 10.0.0.2"))
+	assert.Equal(t, "10.0.0.2", ByPeer(spoofed, "/svc/Method"))
+	spoofed = metadata.NewIncomingContext(peerContext("127.0.0.1:5000"), metadata.Pairs("x-forwarded-for", "5.6.7.8, 10.0.0.2"))
+	assert.Equal(t, ByPeer(gw, "/svc/Method"), ByPeer(spoofed, "/svc/Method"))
+
+	// the calls of the in-process gateway come from the bufconn listener.
+	lis := bufconn.Listen(1)
+	inProcess := metadata.NewIncomingContext(
+		peer.NewContext(context.TODO(), &peer.Peer{Addr: lis.Addr()}),
+		metadata.Pairs("x-forwarded-for", "10.0.0.4"),
+	)
+	assert.Equal(t, "10.0.0.4", ByPeer(inProcess, "/svc/Method"))
+
+	o := newOptions([]Option{WithKey(ByPeer, ByMethod)})
+	assert.Equal(t, "10.0.0.1|/svc/Method", o.key(ctx, "/svc/Method"))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	// Given
+	l, err := NewTokenBucket(PerMinute(1))
+	assert.NoError(t, err)
+	interceptor := UnaryServerInterceptor(l)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Method"}
+
+	// When
+	res1, err1 := interceptor(peerContext("10.0.0.1:5000"), nil, info, handler)
+	_, err2 := interceptor(peerContext("10.0.0.1:5001"), nil, info, handler)
+	res3, err3 := interceptor(peerContext("10.0.0.2:5000"), nil, info, handler)
+
+	// Then
+	assert.NoError(t, err1)
+	assert.Equal(t, "ok", res1)
+	assert.NoError(t, err3)
+	assert.Equal(t, "ok", res3)
+
+	st := status.Convert(err2)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		info := st.Details()[0].(*errdetails.ErrorInfo)
+		assert.Equal(t, ReasonRateLimitExceeded, info.Reason)
+		assert.Equal(t, "1", info.Metadata["limit"])
+		assert.Equal(t, "60", info.Metadata["retry_after"])
+	}
+}
+
+func TestInterceptorAllowsOnLimiterError(t *testing.T) {
+	// Given
+	l := limiterFunc(func(ctx context.Context, key string) (Result, error) {
+		return Result{}, context.DeadlineExceeded
+	})
+	interceptor := UnaryServerInterceptor(l)
+
+	// When
+	res, err := interceptor(context.TODO(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Method"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return "ok", nil
+		})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", res)
+}
+
+func TestMiddleware(t *testing.T) {
+	// Given
+	var keys []string
+	l := limiterFunc(func(ctx context.Context, key string) (Result, error) {
+		keys = append(keys, key)
+		return Result{Allowed: len(keys) == 1, Limit: 1, RetryAfter: 1500 * time.Millisecond}, nil
+	})
+	h := Middleware(l, WithKey(ByPeer, ByMethod, ByMetadata("x-api-key")))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/custom?q=1", nil)
+		r.RemoteAddr = "10.0.0.1:5000"
+		r.Header.Set("X-Api-Key", "secret")
+		return r
+	}
+
+	// When
+	w1 := httptest.NewRecorder()
+	h.ServeHTTP(w1, newRequest())
+	w2 := httptest.NewRecorder()
+	h.ServeHTTP(w2, newRequest())
+
+	// Then
+	assert.Equal(t, http.StatusNoContent, w1.Code)
+	assert.Equal(t, http.StatusTooManyRequests, w2.Code)
+	assert.Equal(t, "2", w2.Header().Get("Retry-After"))
+	assert.Equal(t, []string{"10.0.0.1|GET /custom|secret", "10.0.0.1|GET /custom|secret"}, keys)
+}
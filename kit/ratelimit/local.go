@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// enforce the local limiters to implement the Limiter interface.
+var (
+	_ Limiter = (*TokenBucket)(nil)
+	_ Limiter = (*SlidingWindow)(nil)
+)
+
+// bucket is the state of the token bucket of a key.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// TokenBucket is a token bucket Limiter local to the process.
+//
+// Each key has a bucket of Burst tokens, refilled at Rate tokens per Period, and each request consumes a token.
+type TokenBucket struct {
+	limit Limit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewTokenBucket creates a new TokenBucket of the given limit.
+func NewTokenBucket(limit Limit) (*TokenBucket, error) {
+	if err := limit.Validate(); err != nil {
+		return nil, err
+	}
+	return &TokenBucket{
+		limit:   limit,
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}, nil
+}
+
+// Allow reports whether one more request of the key is allowed, and consumes a token if so.
+func (l *TokenBucket) Allow(_ context.Context, key string) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	// interval is the time to refill one token.
+	interval := float64(l.limit.Period) / float64(l.limit.Rate)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+float64(now.Sub(b.last))/interval)
+	b.last = now
+
+	if b.tokens < 1 {
+		return Result{
+			Limit:      l.limit.Rate,
+			RetryAfter: time.Duration((1 - b.tokens) * interval),
+		}, nil
+	}
+	b.tokens--
+	return Result{Allowed: true, Limit: l.limit.Rate, Remaining: int(b.tokens)}, nil
+}
+
+// sweep removes the buckets refilled to their capacity, once the time to refill a bucket elapsed.
+func (l *TokenBucket) sweep(now time.Time) {
+	refill := time.Duration(float64(l.limit.Period) * float64(l.limit.Burst) / float64(l.limit.Rate))
+	if now.Sub(l.lastSweep) < refill {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// window is the state of the sliding window of a key.
+type window struct {
+	start int64
+	prev  int
+	curr  int
+}
+
+// SlidingWindow is a sliding window Limiter local to the process.
+//
+// Each key is allowed Rate requests over any Period, the count of the previous period being weighted
+// by its overlap with the sliding window.
+type SlidingWindow struct {
+	limit Limit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastSweep int64
+}
+
+// NewSlidingWindow creates a new SlidingWindow of the given limit.
+func NewSlidingWindow(limit Limit) (*SlidingWindow, error) {
+	if err := limit.Validate(); err != nil {
+		return nil, err
+	}
+	return &SlidingWindow{
+		limit:   limit,
+		now:     time.Now,
+		windows: map[string]*window{},
+	}, nil
+}
+
+// Allow reports whether one more request of the key is allowed, and counts it if so.
+func (l *SlidingWindow) Allow(_ context.Context, key string) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now().UnixNano()
+	period := int64(l.limit.Period)
+	start := now / period
+	l.sweep(start)
+
+	w, ok := l.windows[key]
+	if !ok {
+		w = &window{start: start}
+		l.windows[key] = w
+	}
+	switch {
+	case w.start == start-1:
+		w.prev, w.curr = w.curr, 0
+	case w.start < start-1:
+		w.prev, w.curr = 0, 0
+	}
+	w.start = start
+
+	res := slidingWindow(l.limit, w.prev, w.curr, float64(now%period)/float64(period))
+	if res.Allowed {
+		w.curr++
+	}
+	return res, nil
+}
+
+// sweep removes the windows older than the previous one, once per window.
+func (l *SlidingWindow) sweep(start int64) {
+	if start == l.lastSweep {
+		return
+	}
+	l.lastSweep = start
+	for key, w := range l.windows {
+		if w.start < start-1 {
+			delete(l.windows, key)
+		}
+	}
+}
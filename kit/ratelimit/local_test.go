@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clock is a manual clock for the limiters.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestLimitValidate(t *testing.T) {
+	l := PerSecond(10)
+	assert.NoError(t, l.Validate())
+	assert.Equal(t, 10, l.Burst)
+
+	assert.Error(t, (&Limit{Rate: 0, Period: time.Second}).Validate())
+	assert.Error(t, (&Limit{Rate: 1}).Validate())
+}
+
+func TestTokenBucket(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c := &clock{t: time.Unix(1000, 0)}
+	l, err := NewTokenBucket(Limit{Rate: 1, Period: time.Second, Burst: 2})
+	assert.NoError(t, err)
+	l.now = c.now
+
+	// When
+	res1, _ := l.Allow(ctx, "key")
+	res2, _ := l.Allow(ctx, "key")
+	res3, _ := l.Allow(ctx, "key")
+	other, _ := l.Allow(ctx, "other")
+	c.t = c.t.Add(500 * time.Millisecond)
+	res4, _ := l.Allow(ctx, "key")
+	c.t = c.t.Add(500 * time.Millisecond)
+	res5, _ := l.Allow(ctx, "key")
+
+	// Then
+	assert.Equal(t, Result{Allowed: true, Limit: 1, Remaining: 1}, res1)
+	assert.Equal(t, Result{Allowed: true, Limit: 1, Remaining: 0}, res2)
+	assert.Equal(t, Result{Limit: 1, RetryAfter: time.Second}, res3)
+	assert.True(t, other.Allowed)
+	assert.Equal(t, Result{Limit: 1, RetryAfter: 500 * time.Millisecond}, res4)
+	assert.Equal(t, Result{Allowed: true, Limit: 1, Remaining: 0}, res5)
+}
+
+func TestTokenBucketSweep(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c := &clock{t: time.Unix(1000, 0)}
+	l, err := NewTokenBucket(PerSecond(1))
+	assert.NoError(t, err)
+	l.now = c.now
+	_, _ = l.Allow(ctx, "key")
+
+	// When
+	c.t = c.t.Add(time.Second)
+	_, _ = l.Allow(ctx, "other")
+
+	// Then
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	// Given
+	ctx := context.TODO()
+	c := &clock{t: time.Unix(1000, 0)}
+	l, err := NewSlidingWindow(PerSecond(2))
+	assert.NoError(t, err)
+	l.now = c.now
+
+	// When
+	res1, _ := l.Allow(ctx, "key")
+	res2, _ := l.Allow(ctx, "key")
+	res3, _ := l.Allow(ctx, "key")
+	// half of the previous window still counts.
+	c.t = c.t.Add(1500 * time.Millisecond)
+	res4, _ := l.Allow(ctx, "key")
+	res5, _ := l.Allow(ctx, "key")
+	// the previous window no longer counts.
+	c.t = c.t.Add(2 * time.Second)
+	res6, _ := l.Allow(ctx, "key")
+
+	// Then
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1}, res1)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0}, res2)
+	assert.Equal(t, Result{Limit: 2, RetryAfter: 1500 * time.Millisecond}, res3)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0}, res4)
+	assert.Equal(t, Result{Limit: 2, RetryAfter: 500 * time.Millisecond}, res5)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1}, res6)
+}
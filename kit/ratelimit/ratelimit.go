@@ -0,0 +1,99 @@
+// Package ratelimit limits the rate of the requests served, to protect the services from abusive clients.
+//
+// Two algorithms are provided, each with a local backend (NewTokenBucket, NewSlidingWindow)
+// limiting each replica on its own, and a distributed one, in kit/cache/redis, sharing the limits
+// between all the replicas:
+//
+//   - the token bucket allows bursts of requests up to its capacity, refilled at a steady rate;
+//   - the sliding window allows a number of requests over a rolling period, approximated by
+//     weighting the count of the previous window.
+//
+// The limiters are enforced by the gRPC interceptors and the HTTP middleware of this package,
+// see kit.WithRateLimit to enable them in the Foundation:
+//
+//	limiter, err := ratelimit.NewTokenBucket(ratelimit.PerSecond(100))
+//	f, err := kit.NewFoundation("myservice", kit.WithRateLimit(limiter, ratelimit.WithKey(ratelimit.ByPeer)))
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+)
+
+// Limit defines the rate of the requests allowed.
+type Limit struct {
+	// Rate is the number of requests allowed per period.
+	Rate int
+	// Period is the period of the rate.
+	Period time.Duration
+	// Burst is the number of requests the token bucket allows at once, Rate by default.
+	// It is ignored by the sliding window.
+	Burst int
+}
+
+// PerSecond returns a Limit of rate requests per second.
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+// PerMinute returns a Limit of rate requests per minute.
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+// Validate validates the limit and sets the default burst.
+func (l *Limit) Validate() error {
+	if l.Rate <= 0 {
+		return errors.New("rate limit rate must be positive")
+	}
+	if l.Period <= 0 {
+		return errors.New("rate limit period must be positive")
+	}
+	if l.Burst <= 0 {
+		l.Burst = l.Rate
+	}
+	return nil
+}
+
+// Result is the decision of a Limiter.
+type Result struct {
+	// Allowed reports whether the request is allowed.
+	Allowed bool
+	// Limit is the number of requests allowed per period.
+	Limit int
+	// Remaining is the number of requests still allowed right now.
+	Remaining int
+	// RetryAfter is how long to wait before the request is allowed, 0 when allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter limits the rate of the requests identified by a key.
+type Limiter interface {
+	// Allow reports whether one more request of the key is allowed, and consumes it if so.
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// slidingWindow decides whether a request is allowed by the sliding window of the given limit,
+// from the counts of the previous and current windows and the elapsed fraction of the current window.
+func slidingWindow(limit Limit, prev, curr int, elapsed float64) Result {
+	rate := float64(limit.Rate)
+	count := float64(prev)*(1-elapsed) + float64(curr)
+	if count+1 <= rate {
+		return Result{Allowed: true, Limit: limit.Rate, Remaining: int(rate - count - 1)}
+	}
+
+	// the weight of the previous window must decrease enough to allow one more request.
+	var wait float64
+	if float64(curr)+1 > rate {
+		// not before the next window, where the current window becomes the previous one.
+		wait = 1 - elapsed + 1 - (rate-1)/float64(curr)
+	} else {
+		wait = 1 - (rate-float64(curr)-1)/float64(prev) - elapsed
+	}
+	return Result{
+		Limit:      limit.Rate,
+		RetryAfter: time.Duration(wait * float64(limit.Period)),
+	}
+}
@@ -0,0 +1,267 @@
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+)
+
+// Schedule defines when a job runs.
+type Schedule interface {
+	// Next returns the next activation time, later than the given time.
+	Next(t time.Time) time.Time
+}
+
+// Every returns a Schedule activating at a fixed interval, aligned on the interval
+// (eg. every 5 minutes runs at 00:00, 00:05, ...).
+func Every(interval time.Duration) Schedule {
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return every(interval)
+}
+
+// every is a fixed interval Schedule.
+type every time.Duration
+
+// Next returns the next activation time.
+func (e every) Next(t time.Time) time.Time {
+	return t.Truncate(time.Duration(e)).Add(time.Duration(e))
+}
+
+// field is the range of values of a cron field.
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minutes  = field{name: "minute", min: 0, max: 59}
+	hours    = field{name: "hour", min: 0, max: 23}
+	days     = field{name: "day of month", min: 1, max: 31}
+	months   = field{name: "month", min: 1, max: 12, names: map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}}
+	weekdays = field{name: "day of week", min: 0, max: 7, names: map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}}
+)
+
+// descriptors are the predefined cron expressions.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cron is a Schedule defined by a cron expression,
+// each field is a bit set of the matching values.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record whether the day fields are unrestricted,
+	// when both are restricted, a day matches if either of them matches.
+	domStar, dowStar bool
+	location         *time.Location
+}
+
+// Cron parses a standard cron expression made of 5 fields: minute, hour, day of month, month and day of week.
+//
+// Fields accept `*`, values, ranges (`1-5`), steps (`*/15`, `0-30/10`) and lists (`1,15`);
+// months and days of week also accept their 3 letters names (`JAN`, `MON`).
+// The descriptors `@yearly`, `@monthly`, `@weekly`, `@daily`, `@hourly` and `@every <duration>` are supported.
+//
+// The expression is evaluated in the location of the time passed to Next, unless prefixed
+// with `CRON_TZ=<location>` (eg. `CRON_TZ=Europe/Paris 0 3 * * *`).
+func Cron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	var location *time.Location
+	if strings.HasPrefix(expr, "CRON_TZ=") {
+		i := strings.Index(expr, " ")
+		if i < 0 {
+			return nil, errors.Newf("invalid cron expression '%s'", expr)
+		}
+		loc, err := time.LoadLocation(expr[len("CRON_TZ="):i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cron location '%s'", expr)
+		}
+		location = loc
+		expr = strings.TrimSpace(expr[i:])
+	}
+
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cron expression '%s'", expr)
+		}
+		return Every(d), nil
+	}
+	if d, ok := descriptors[expr]; ok {
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.Newf("invalid cron expression '%s': expected 5 fields, got %d", expr, len(fields))
+	}
+
+	c := &cron{
+		domStar:  fields[2] == "*" || fields[2] == "?",
+		dowStar:  fields[4] == "*" || fields[4] == "?",
+		location: location,
+	}
+	var err error
+	for i, f := range []struct {
+		bits  *uint64
+		field field
+	}{
+		{&c.minute, minutes},
+		{&c.hour, hours},
+		{&c.dom, days},
+		{&c.month, months},
+		{&c.dow, weekdays},
+	} {
+		if *f.bits, err = parseField(fields[i], f.field); err != nil {
+			return nil, errors.Wrapf(err, "invalid cron expression '%s'", expr)
+		}
+	}
+
+	// 7 is an alias of sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// MustCron is like Cron but panics if the expression cannot be parsed.
+func MustCron(expr string) Schedule {
+	s, err := Cron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// parseField parses a comma separated list of ranges into a bit set.
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		b, err := parseRange(part, f)
+		if err != nil {
+			return 0, err
+		}
+		bits |= b
+	}
+	return bits, nil
+}
+
+// parseRange parses `*`, `n`, `n-m`, optionally followed by `/step`, into a bit set.
+func parseRange(expr string, f field) (uint64, error) {
+	rangeExpr, stepExpr, hasStep := strings.Cut(expr, "/")
+
+	start, end := f.min, f.max
+	switch {
+	case rangeExpr == "*" || rangeExpr == "?":
+	case strings.Contains(rangeExpr, "-"):
+		lo, hi, _ := strings.Cut(rangeExpr, "-")
+		var err error
+		if start, err = parseValue(lo, f); err != nil {
+			return 0, err
+		}
+		if end, err = parseValue(hi, f); err != nil {
+			return 0, err
+		}
+	default:
+		v, err := parseValue(rangeExpr, f)
+		if err != nil {
+			return 0, err
+		}
+		start = v
+		// `n/step` means from n to the max.
+		if !hasStep {
+			end = v
+		}
+	}
+	if start > end {
+		return 0, errors.Newf("%s range '%s' is inverted", f.name, expr)
+	}
+
+	step := 1
+	if hasStep {
+		var err error
+		step, err = strconv.Atoi(stepExpr)
+		if err != nil || step <= 0 {
+			return 0, errors.Newf("%s step '%s' is invalid", f.name, expr)
+		}
+	}
+
+	var bits uint64
+	for v := start; v <= end; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits, nil
+}
+
+// parseValue parses a value or a name of the field.
+func parseValue(expr string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(expr)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(expr)
+	if err != nil {
+		return 0, errors.Newf("%s value '%s' is invalid", f.name, expr)
+	}
+	if v < f.min || v > f.max {
+		return 0, errors.Newf("%s value '%d' is out of range [%d-%d]", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the next activation time.
+func (c *cron) Next(t time.Time) time.Time {
+	origLocation := t.Location()
+	if c.location != nil {
+		t = t.In(c.location)
+	}
+
+	// start at the next minute.
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	loc := t.Location()
+
+	// give up after 5 years, the expression can never match (eg. February 30th).
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t.In(origLocation)
+	}
+	return time.Time{}
+}
+
+// matchDay reports whether the day matches the day of month and day of week fields.
+func (c *cron) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,71 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	// Monday 15 January 2024, 10:32:20
+	now := time.Date(2024, time.January, 15, 10, 32, 20, 0, time.UTC)
+
+	cases := map[string]time.Time{
+		"* * * * *":             time.Date(2024, time.January, 15, 10, 33, 0, 0, time.UTC),
+		"*/15 * * * *":          time.Date(2024, time.January, 15, 10, 45, 0, 0, time.UTC),
+		"0 3 * * *":             time.Date(2024, time.January, 16, 3, 0, 0, 0, time.UTC),
+		"30 9-17/4 * * *":       time.Date(2024, time.January, 15, 13, 30, 0, 0, time.UTC),
+		"0 0 1 * *":             time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 * * SUN":           time.Date(2024, time.January, 21, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":             time.Date(2024, time.January, 21, 0, 0, 0, 0, time.UTC),
+		"0 12 29 FEB *":         time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC),
+		"0 0 13 * FRI":          time.Date(2024, time.January, 19, 0, 0, 0, 0, time.UTC),
+		"5,10 0 1 jan-mar *":    time.Date(2024, time.February, 1, 0, 5, 0, 0, time.UTC),
+		"@hourly":               time.Date(2024, time.January, 15, 11, 0, 0, 0, time.UTC),
+		"@weekly":               time.Date(2024, time.January, 21, 0, 0, 0, 0, time.UTC),
+		"@every 10m":            time.Date(2024, time.January, 15, 10, 40, 0, 0, time.UTC),
+		"CRON_TZ=UTC 0 3 * * *": time.Date(2024, time.January, 16, 3, 0, 0, 0, time.UTC),
+	}
+	for expr, expected := range cases {
+		t.Run(expr, func(t *testing.T) {
+			s, err := Cron(expr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, expected, s.Next(now))
+		})
+	}
+}
+
+func TestCronNeverMatches(t *testing.T) {
+	s, err := Cron("0 0 30 FEB *")
+	assert.NoError(t, err)
+	assert.True(t, s.Next(time.Now()).IsZero())
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"10-5 * * * *",
+		"*/0 * * * *",
+		"* * * FOO *",
+		"@every forever",
+		"CRON_TZ=Nowhere/Nothing * * * * *",
+	} {
+		_, err := Cron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestEvery(t *testing.T) {
+	now := time.Date(2024, time.January, 15, 10, 32, 20, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, time.January, 15, 10, 35, 0, 0, time.UTC), Every(5*time.Minute).Next(now))
+	assert.Equal(t, now.Add(time.Second), Every(time.Millisecond).Next(now))
+}
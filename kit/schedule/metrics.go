@@ -0,0 +1,71 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/telemetry/metric"
+)
+
+// Metric names.
+const (
+	runsMetric        = "schedule_runs_total"
+	missedMetric      = "schedule_missed_activations_total"
+	durationMetric    = "schedule_run_duration_seconds"
+	lastSuccessMetric = "schedule_last_success_timestamp_seconds"
+)
+
+// Run statuses.
+const (
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+	statusSkipped   = "skipped"
+)
+
+var (
+	_metrics     *metrics
+	_metricsOnce sync.Once
+)
+
+// metrics are the Prometheus metrics of the scheduled jobs.
+// They are registered once and shared by all the schedulers of the process.
+type metrics struct {
+	m *metric.Metrics
+}
+
+// defaultMetrics returns the metrics shared by the schedulers.
+func defaultMetrics() *metrics {
+	_metricsOnce.Do(func() {
+		m := metric.New()
+		// registration only fails if the metrics are already registered,
+		// metrics are then silently not recorded.
+		_ = m.Register(runsMetric, "Number of scheduled job runs by status.", metric.Labels("job", "status"))                       //nolint
+		_ = m.Register(missedMetric, "Number of scheduled job activations missed while the job was running.", metric.Labels("job")) //nolint
+		_ = m.Register(durationMetric, "Duration of the scheduled job runs.", metric.Labels("job"),                                 //nolint
+			metric.Histogram(0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 600))
+		_ = m.Register(lastSuccessMetric, "Time of the last successful run of the scheduled job.", metric.Labels("job"), metric.Gauge()) //nolint
+		_metrics = &metrics{m: m}
+	})
+	return _metrics
+}
+
+func (m *metrics) succeeded(job string) {
+	_ = m.m.Increment(runsMetric, 1, job, statusSucceeded)          //nolint
+	_ = m.m.Set(lastSuccessMetric, float64(time.Now().Unix()), job) //nolint
+}
+
+func (m *metrics) failed(job string) {
+	_ = m.m.Increment(runsMetric, 1, job, statusFailed) //nolint
+}
+
+func (m *metrics) skipped(job string) {
+	_ = m.m.Increment(runsMetric, 1, job, statusSkipped) //nolint
+}
+
+func (m *metrics) missed(job string, n int) {
+	_ = m.m.Increment(missedMetric, float64(n), job) //nolint
+}
+
+func (m *metrics) observe(job string, d time.Duration) {
+	_ = m.m.Observe(durationMetric, d.Seconds(), job) //nolint
+}
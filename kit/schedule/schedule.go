@@ -0,0 +1,390 @@
+// Package schedule runs periodic jobs, such as purging completed todos or refreshing caches,
+// on exactly one replica of a service.
+//
+// Jobs are scheduled with cron expressions (see Cron) or fixed intervals (see Every).
+// When a lock.ScopedLocker is configured, every replica wakes up on each activation and competes
+// for a lock of the job scoped to the activation time, only the one acquiring it runs the job.
+// The lock is kept alive while the job runs, and its fencing token, increasing across the activations
+// of the job, is available with LockToken:
+//
+//	locker, _ := kitsql.NewAdvisoryLocker(db)
+//	s := schedule.New(schedule.WithLocker(locker))
+//	_ = s.Register("purge-completed", schedule.MustCron("0 3 * * *"), purgeCompleted,
+//		schedule.WithJitter(30*time.Second),
+//	)
+//	foundation.RegisterWorker("scheduler", s.Run)
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/lock"
+	"github.com/mukhtarkv/workspace/kit/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// tracer represents the schedule tracer
+var tracer = otel.Tracer("kit/schedule")
+
+// Job is a function run on each activation of its schedule.
+// The context is canceled when the scheduler stops, the job timeout expires or the lock of the run is lost.
+type Job func(ctx context.Context) error
+
+type lockTokenKey struct{}
+
+// LockToken returns the fencing token of the lock of the run, to pass along to the resources
+// the job writes to (see lock.Lock). The tokens increase across the activations of the job,
+// so the writes of a stalled earlier run can be rejected. It returns false when the scheduler has no locker.
+func LockToken(ctx context.Context) (int64, bool) {
+	token, ok := ctx.Value(lockTokenKey{}).(int64)
+	return token, ok
+}
+
+// MissedRunPolicy defines what happens to the activations missed while the previous run of a job
+// was still running.
+type MissedRunPolicy int
+
+const (
+	// SkipMissed skips the missed activations, the job runs on the next activation.
+	SkipMissed MissedRunPolicy = iota
+	// RunOnceMissed runs the job once right away for all the missed activations.
+	RunOnceMissed
+)
+
+// Option defines a Scheduler option.
+type Option func(*options)
+
+// options provides a set of configurable options for the Scheduler.
+type options struct {
+	locker lock.ScopedLocker
+	logger *log.Logger
+}
+
+// JobOption defines a job option.
+type JobOption func(*jobOptions)
+
+// jobOptions provides a set of configurable options for a job.
+type jobOptions struct {
+	jitter     time.Duration
+	timeout    time.Duration
+	lockTTL    time.Duration
+	missedRuns MissedRunPolicy
+}
+
+// job is a registered Job.
+type job struct {
+	name     string
+	schedule Schedule
+	fn       Job
+	opts     jobOptions
+}
+
+// Scheduler runs jobs according to their schedule.
+type Scheduler struct {
+	opts    options
+	metrics *metrics
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	running bool
+}
+
+// New creates a new Scheduler.
+func New(opts ...Option) *Scheduler {
+	// default options
+	o := options{
+		logger: log.L(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Scheduler{
+		opts:    o,
+		metrics: defaultMetrics(),
+		jobs:    map[string]*job{},
+	}
+}
+
+// Register registers a job under a unique name, the name scopes the job lock and labels its metrics.
+// Jobs must be registered before calling Run.
+func (s *Scheduler) Register(name string, schedule Schedule, fn Job, opts ...JobOption) error {
+	if len(name) == 0 {
+		return errors.New("job name is required")
+	}
+	if schedule == nil {
+		return errors.New("schedule is nil")
+	}
+	if fn == nil {
+		return errors.New("job is nil")
+	}
+
+	// default options
+	o := jobOptions{
+		lockTTL:    time.Minute,
+		missedRuns: SkipMissed,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return errors.New("scheduler is running")
+	}
+	if _, ok := s.jobs[name]; ok {
+		return errors.Newf("job '%s' already registered", name)
+	}
+	s.jobs[name] = &job{
+		name:     name,
+		schedule: schedule,
+		fn:       fn,
+		opts:     o,
+	}
+	return nil
+}
+
+// Run runs the registered jobs until the context is canceled.
+// The running jobs contexts are canceled, and Run returns once they all returned.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return errors.New("scheduler is running")
+	}
+	s.running = true
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+	wg.Wait()
+	return nil
+}
+
+// loop runs the job on each activation until the context is canceled.
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	activation := j.schedule.Next(time.Now())
+	for !activation.IsZero() {
+		wait := time.Until(activation)
+		if j.opts.jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(j.opts.jitter))) //nolint:gosec
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.run(ctx, j, activation)
+
+		// find out the activations missed while the job was running.
+		now := time.Now()
+		next := j.schedule.Next(activation)
+		missed := 0
+		var lastMissed time.Time
+		for !next.IsZero() && !next.After(now) && missed < 1000 {
+			lastMissed = next
+			missed++
+			next = j.schedule.Next(next)
+		}
+		if missed > 0 {
+			s.metrics.missed(j.name, missed)
+			s.opts.logger.Warn(ctx, "scheduled job missed activations",
+				log.String("job", j.name),
+				log.Int("missed", missed))
+
+			if j.opts.missedRuns == RunOnceMissed {
+				if ctx.Err() != nil {
+					return
+				}
+				s.run(ctx, j, lastMissed)
+				next = j.schedule.Next(time.Now())
+			}
+		}
+		activation = next
+	}
+}
+
+// run runs the job for the given activation, if the lock is acquired.
+func (s *Scheduler) run(ctx context.Context, j *job, activation time.Time) {
+	ctx, span := tracer.Start(ctx, fmt.Sprintf("Schedule %s", j.name))
+	span.SetAttributes(
+		attribute.String("job", j.name),
+		attribute.String("job.activation", activation.UTC().Format(time.RFC3339)),
+	)
+	defer span.End()
+
+	// the lease is scoped to the activation, while the tokens are shared by all the activations of the job.
+	// It is kept alive while the job runs, and held until the other replicas woke up for the activation (see release).
+	if s.opts.locker != nil {
+		ttl := j.opts.lockTTL + j.opts.jitter
+		l, err := s.opts.locker.TryLockScoped(ctx, "schedule:"+j.name, strconv.FormatInt(activation.UnixMilli(), 10), ttl)
+		if err != nil {
+			if errors.Is(err, lock.ErrNotAcquired) {
+				span.SetAttributes(attribute.Bool("job.skipped", true))
+				s.metrics.skipped(j.name)
+				return
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "acquiring lock")
+			s.metrics.failed(j.name)
+			s.opts.logger.Error(ctx, "scheduled job lock failed", log.String("job", j.name), log.Error(err))
+			return
+		}
+		defer s.release(j, activation, l)
+
+		var cancel context.CancelFunc
+		ctx, cancel = lock.KeepAlive(ctx, l, ttl)
+		defer cancel()
+		ctx = context.WithValue(ctx, lockTokenKey{}, l.Token())
+	}
+
+	if j.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.opts.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := safeRun(ctx, j.fn)
+	duration := time.Since(start)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		s.metrics.failed(j.name)
+		s.metrics.observe(j.name, duration)
+		s.opts.logger.Error(ctx, "scheduled job failed",
+			log.String("job", j.name),
+			log.Duration("duration", duration),
+			log.Error(err))
+		return
+	}
+
+	s.metrics.succeeded(j.name)
+	s.metrics.observe(j.name, duration)
+	s.opts.logger.Info(ctx, "scheduled job succeeded",
+		log.String("job", j.name),
+		log.Duration("duration", duration))
+}
+
+// release releases the lock of the activation once its run returned and the activation window passed:
+// the lock TTL and jitter after the activation, or the next activation if earlier, so the locks
+// of frequent jobs, and the connections of the AdvisoryLocker, are not held longer than the interval.
+func (s *Scheduler) release(j *job, activation time.Time, l lock.Lock) {
+	end := activation.Add(j.opts.lockTTL + j.opts.jitter)
+	if next := j.schedule.Next(activation); !next.IsZero() && next.Before(end) {
+		end = next
+	}
+
+	unlock := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := l.Unlock(ctx); err != nil {
+			s.opts.logger.Warn(ctx, "scheduled job unlock failed", log.String("job", j.name), log.Error(err))
+		}
+	}
+	if wait := time.Until(end); wait > 0 {
+		time.AfterFunc(wait, unlock)
+		return
+	}
+	unlock()
+}
+
+// safeRun runs the job, turning panics into errors.
+func safeRun(ctx context.Context, fn Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Newf("job panicked: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// WithLocker defines the locker electing the replica running each activation.
+// Without locker, the jobs run on every replica.
+func WithLocker(locker lock.ScopedLocker) Option {
+	return func(o *options) {
+		o.locker = locker
+	}
+}
+
+// WithLogger defines the logger used by the scheduler.
+//
+// Logger defaults to the global logger.
+func WithLogger(l *log.Logger) Option {
+	return func(o *options) {
+		if l != nil {
+			o.logger = l
+		}
+	}
+}
+
+// WithJitter delays each run by a random duration up to jitter,
+// spreading the load of jobs sharing the same schedule.
+func WithJitter(jitter time.Duration) JobOption {
+	return func(o *jobOptions) {
+		if jitter > 0 {
+			o.jitter = jitter
+		}
+	}
+}
+
+// WithTimeout defines the maximum duration of a run, its context is canceled afterward.
+func WithTimeout(timeout time.Duration) JobOption {
+	return func(o *jobOptions) {
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
+}
+
+// WithLockTTL defines how long the lock of an activation is held, on top of the jitter,
+// unless the next activation comes earlier. It should exceed the clock skew between the replicas.
+// The lock is extended while the job runs longer.
+//
+// LockTTL defaults 1 minute.
+func WithLockTTL(ttl time.Duration) JobOption {
+	return func(o *jobOptions) {
+		if ttl > 0 {
+			o.lockTTL = ttl
+		}
+	}
+}
+
+// WithMissedRunPolicy defines what happens to the activations missed while the previous run was still running.
+//
+// MissedRunPolicy defaults to SkipMissed.
+func WithMissedRunPolicy(policy MissedRunPolicy) JobOption {
+	return func(o *jobOptions) {
+		o.missedRuns = policy
+	}
+}
@@ -0,0 +1,210 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/lock"
+	"github.com/mukhtarkv/workspace/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// interval is a Schedule activating at a fixed interval, shorter than Every allows.
+type interval time.Duration
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Truncate(time.Duration(i)).Add(time.Duration(i))
+}
+
+// localLocker is a lock.ScopedLocker shared by the schedulers of a test,
+// with a fencing counter per key as the Redis locker.
+type localLocker struct {
+	mu       sync.Mutex
+	locks    map[string]time.Time
+	tokens   map[string]int64
+	unlocked []string
+}
+
+func newLocalLocker() *localLocker {
+	return &localLocker{locks: map[string]time.Time{}, tokens: map[string]int64{}}
+}
+
+func (l *localLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (lock.Lock, error) {
+	return l.TryLockScoped(ctx, key, "", ttl)
+}
+
+func (l *localLocker) TryLockScoped(ctx context.Context, key, scope string, ttl time.Duration) (lock.Lock, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens[key]++
+	lockKey := key + "/" + scope
+	if expiry, ok := l.locks[lockKey]; ok && time.Now().Before(expiry) {
+		return nil, lock.ErrNotAcquired
+	}
+	l.locks[lockKey] = time.Now().Add(ttl)
+	return &localLock{locker: l, key: lockKey, token: l.tokens[key]}, nil
+}
+
+// unlockedKeys returns the keys unlocked so far.
+func (l *localLocker) unlockedKeys() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.unlocked...)
+}
+
+type localLock struct {
+	locker *localLocker
+	key    string
+	token  int64
+}
+
+func (l *localLock) Token() int64 {
+	return l.token
+}
+
+func (l *localLock) Extend(ctx context.Context, ttl time.Duration) error {
+	return nil
+}
+
+func (l *localLock) Unlock(ctx context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+	delete(l.locker.locks, l.key)
+	l.locker.unlocked = append(l.locker.unlocked, l.key)
+	return nil
+}
+
+func TestSchedulerRunsOnOneReplica(t *testing.T) {
+	locker := newLocalLocker()
+
+	var runs atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		s := New(WithLocker(locker), WithLogger(log.NewNop()))
+		assert.NoError(t, s.Register("job", interval(50*time.Millisecond), func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		}, WithJitter(10*time.Millisecond)))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.Run(ctx))
+		}()
+	}
+
+	time.Sleep(230 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	// 4 activations, each run by a single replica.
+	assert.InDelta(t, 4, runs.Load(), 1)
+}
+
+func TestSchedulerLockRelease(t *testing.T) {
+	// Given a scheduler with a lock TTL longer than the interval
+	locker := newLocalLocker()
+	s := New(WithLocker(locker), WithLogger(log.NewNop()))
+	tokens := make(chan int64, 10)
+	assert.NoError(t, s.Register("job", interval(50*time.Millisecond), func(ctx context.Context) error {
+		token, ok := LockToken(ctx)
+		assert.True(t, ok)
+		tokens <- token
+		return nil
+	}, WithLockTTL(time.Minute)))
+
+	// When the job runs
+	time.Sleep(time.Until(interval(50 * time.Millisecond).Next(time.Now())))
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, s.Run(ctx))
+
+	// Then it has the token of the lock
+	assert.Greater(t, <-tokens, int64(0))
+
+	// Then the lock of an activation is released on the next activation
+	assert.Eventually(t, func() bool {
+		return len(locker.unlockedKeys()) >= 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSchedulerLockTokens(t *testing.T) {
+	// Given a job scheduled on several replicas
+	locker := newLocalLocker()
+	var mu sync.Mutex
+	var tokens []int64
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		s := New(WithLocker(locker), WithLogger(log.NewNop()))
+		assert.NoError(t, s.Register("job", interval(50*time.Millisecond), func(ctx context.Context) error {
+			token, _ := LockToken(ctx)
+			mu.Lock()
+			tokens = append(tokens, token)
+			mu.Unlock()
+			return nil
+		}, WithJitter(10*time.Millisecond)))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.Run(ctx))
+		}()
+	}
+
+	// When it runs on consecutive activations
+	time.Sleep(230 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	// Then the tokens strictly increase across the activations
+	assert.GreaterOrEqual(t, len(tokens), 3)
+	for i := 1; i < len(tokens); i++ {
+		assert.Greater(t, tokens[i], tokens[i-1])
+	}
+}
+
+func TestSchedulerMissedRuns(t *testing.T) {
+	for name, tc := range map[string]struct {
+		policy MissedRunPolicy
+		runs   int32
+	}{
+		"skip":     {policy: SkipMissed, runs: 2},
+		"run once": {policy: RunOnceMissed, runs: 3},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := New(WithLogger(log.NewNop()))
+			var runs atomic.Int32
+			assert.NoError(t, s.Register("job", interval(50*time.Millisecond), func(ctx context.Context) error {
+				// the first run overlaps the next 2 activations.
+				if runs.Add(1) == 1 {
+					time.Sleep(120 * time.Millisecond)
+				}
+				return nil
+			}, WithMissedRunPolicy(tc.policy)))
+
+			// align the test on the activations.
+			time.Sleep(time.Until(interval(50 * time.Millisecond).Next(time.Now())))
+			ctx, cancel := context.WithTimeout(context.Background(), 225*time.Millisecond)
+			defer cancel()
+			assert.NoError(t, s.Run(ctx))
+
+			assert.Equal(t, tc.runs, runs.Load())
+		})
+	}
+}
+
+func TestSchedulerRegister(t *testing.T) {
+	s := New()
+	job := func(ctx context.Context) error { return nil }
+
+	assert.NoError(t, s.Register("job", Every(time.Minute), job))
+	assert.Error(t, s.Register("job", Every(time.Minute), job))
+	assert.Error(t, s.Register("", Every(time.Minute), job))
+	assert.Error(t, s.Register("other", nil, job))
+	assert.Error(t, s.Register("other", Every(time.Minute), nil))
+}
@@ -0,0 +1,157 @@
+package kit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/log"
+)
+
+// ShutdownPhase represents a step of the Foundation graceful shutdown.
+//
+// On SIGTERM, the phases are run in order, each of them bounded by its own timeout
+// (see WithShutdownTimeout). The closers registered within the same phase are run concurrently.
+type ShutdownPhase int
+
+const (
+	// PhaseReadiness is the first phase, /readyz starts failing so the service stops receiving new traffic.
+	// The Foundation then waits for the shutdown delay (see WithShutdownDelay).
+	PhaseReadiness ShutdownPhase = iota
+	// PhaseDrain stops the background consumers, such as pubsub subscribers.
+	PhaseDrain
+	// PhaseServers gracefully stops the gRPC and HTTP servers.
+	PhaseServers
+	// PhaseResources closes the resources used to serve requests, such as databases, caches and publishers.
+	PhaseResources
+	// PhaseTelemetry flushes the tracer. Once this phase completes, the logger is flushed when Serve returns no error,
+	// and otherwise left to the caller logging the error.
+	PhaseTelemetry
+)
+
+// shutdownPhases are the shutdown phases in order.
+var shutdownPhases = []ShutdownPhase{PhaseReadiness, PhaseDrain, PhaseServers, PhaseResources, PhaseTelemetry}
+
+// String returns the phase name.
+func (p ShutdownPhase) String() string {
+	switch p {
+	case PhaseReadiness:
+		return "readiness"
+	case PhaseDrain:
+		return "drain"
+	case PhaseServers:
+		return "servers"
+	case PhaseResources:
+		return "resources"
+	case PhaseTelemetry:
+		return "telemetry"
+	}
+	return fmt.Sprintf("phase(%d)", int(p))
+}
+
+// CloseFunc represents a function releasing a resource during the shutdown.
+// The context is canceled once the phase timeout expires.
+type CloseFunc func(ctx context.Context) error
+
+// closer is a named CloseFunc.
+type closer struct {
+	name string
+	fn   CloseFunc
+}
+
+// RegisterCloser registers a function to be called during the given shutdown phase.
+//
+//	sub, closeFn, err := conf.Subscriber(ctx)
+//	foundation.RegisterCloser(kit.PhaseDrain, "subscriber", func(ctx context.Context) error {
+//		closeFn()
+//		return nil
+//	})
+//	foundation.RegisterCloser(kit.PhaseResources, "db", func(ctx context.Context) error {
+//		return db.Close()
+//	})
+func (f *Foundation) RegisterCloser(phase ShutdownPhase, name string, fn CloseFunc) {
+	f.closersLock.Lock()
+	defer f.closersLock.Unlock()
+
+	if f.closers == nil {
+		f.closers = map[ShutdownPhase][]closer{}
+	}
+	f.closers[phase] = append(f.closers[phase], closer{name: name, fn: fn})
+}
+
+// shutdown runs the shutdown phases in order.
+func (f *Foundation) shutdown() {
+	// fail the readiness as soon as the shutdown begins.
+	f.shuttingDown.Store(true)
+	f.logger.Info(context.Background(), "shutdown started", log.String("service-name", f.name))
+
+	for _, phase := range shutdownPhases {
+		f.closersLock.Lock()
+		closers := f.closers[phase]
+		f.closersLock.Unlock()
+
+		start := time.Now()
+		f.runShutdownPhase(phase, closers)
+
+		// let the load balancers notice the readiness failure before draining.
+		if phase == PhaseReadiness && f.opts.shutdownDelay > 0 {
+			if remaining := f.opts.shutdownDelay - time.Since(start); remaining > 0 {
+				time.Sleep(remaining)
+			}
+		}
+	}
+
+	f.logger.Info(context.Background(), "shutdown completed", log.String("service-name", f.name))
+}
+
+// runShutdownPhase runs concurrently the closers of a phase, until they all returned or the phase timed out.
+func (f *Foundation) runShutdownPhase(phase ShutdownPhase, closers []closer) {
+	if len(closers) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.opts.shutdownTimeout(phase))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, c := range closers {
+		wg.Add(1)
+		go func(c closer) {
+			defer wg.Done()
+			if err := c.fn(ctx); err != nil {
+				f.logger.Warn(ctx, "fail closing",
+					log.String("shutdown.phase", phase.String()),
+					log.String("closer", c.name),
+					log.Error(err))
+			}
+		}(c)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		f.logger.Warn(ctx, "shutdown phase timed out", log.String("shutdown.phase", phase.String()))
+	}
+}
+
+// readinessHandler fails once the shutdown began or while a worker waits to be restarted,
+// and otherwise calls the readiness probe.
+func (f *Foundation) readinessHandler() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if f.shuttingDown.Load() {
+			writer.Header().Set("Content-Type", "text/plain")
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(writer, "shutting down") //nolint
+			return
+		}
+		f.workersHandler(f.readinessProbe)(writer, request)
+	}
+}
@@ -0,0 +1,71 @@
+package kit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownPhases(t *testing.T) {
+	f, err := NewFoundation("test", WithShutdownTimeout(PhaseDrain, 50*time.Millisecond))
+	assert.NoError(t, err)
+
+	var (
+		mu     sync.Mutex
+		closed []string
+	)
+	record := func(name string) CloseFunc {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			closed = append(closed, name)
+			return nil
+		}
+	}
+
+	// registered in reverse order to check phases are run in order.
+	f.RegisterCloser(PhaseTelemetry, "tracer", record("tracer"))
+	f.RegisterCloser(PhaseResources, "db", func(ctx context.Context) error {
+		_ = record("db")(ctx)
+		return errors.New("already closed")
+	})
+	f.RegisterCloser(PhaseServers, "server", record("server"))
+	f.RegisterCloser(PhaseDrain, "subscriber", record("subscriber"))
+	// a closer exceeding its phase timeout doesn't block the shutdown.
+	f.RegisterCloser(PhaseDrain, "stuck", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	f.RegisterCloser(PhaseReadiness, "readiness", func(ctx context.Context) error {
+		_ = record("readiness")(ctx)
+		assert.True(t, f.shuttingDown.Load())
+		return nil
+	})
+
+	f.shutdown()
+	assert.Equal(t, []string{"readiness", "subscriber", "server", "db", "tracer"}, closed)
+}
+
+func TestReadinessFailsOnShutdown(t *testing.T) {
+	f, err := NewFoundation("test")
+	assert.NoError(t, err)
+	f.RegisterReadiness(func() (string, error) {
+		return "ready", nil
+	})
+
+	rec := httptest.NewRecorder()
+	f.readinessHandler()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	f.shutdown()
+
+	rec = httptest.NewRecorder()
+	f.readinessHandler()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
@@ -0,0 +1,175 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/lock"
+)
+
+// enforce the AdvisoryLocker to implement the lock.ScopedLocker interface.
+var _ lock.ScopedLocker = (*AdvisoryLocker)(nil)
+
+// AdvisoryLocker provides lease based distributed locks on Postgres session advisory locks.
+//
+// Each lock holds a connection of the pool until it is released or its lease expires,
+// and is released by Postgres if the connection is lost.
+// The fencing token of a lock is drawn from the `kit_lock_token_seq` sequence once the lock is acquired,
+// so it increases with each acquisition across all the connections. The sequence is created on first use.
+type AdvisoryLocker struct {
+	db *sqlx.DB
+
+	mu       sync.Mutex
+	sequence bool
+}
+
+// tokenSequence is the sequence the fencing tokens are drawn from.
+const tokenSequence = "kit_lock_token_seq"
+
+// NewAdvisoryLocker creates a new AdvisoryLocker.
+func NewAdvisoryLocker(db *sqlx.DB) (*AdvisoryLocker, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &AdvisoryLocker{db: db}, nil
+}
+
+// TryLock acquires the lock identified by the key for the ttl duration, without waiting.
+// The key is hashed into the 64 bits advisory lock key.
+func (l *AdvisoryLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (lock.Lock, error) {
+	if len(key) == 0 {
+		return nil, errors.New("lock key is empty")
+	}
+	return l.tryLock(ctx, key, advisoryKey(key), ttl)
+}
+
+// TryLockScoped acquires the lock identified by the key and the scope for the ttl duration, without waiting.
+// The tokens are drawn from the same sequence as the ones of the other locks.
+func (l *AdvisoryLocker) TryLockScoped(ctx context.Context, key, scope string, ttl time.Duration) (lock.Lock, error) {
+	if len(key) == 0 {
+		return nil, errors.New("lock key is empty")
+	}
+	return l.tryLock(ctx, key, advisoryKey(key+"\x00"+scope), ttl)
+}
+
+// tryLock acquires the advisory lock of the key.
+func (l *AdvisoryLocker) tryLock(ctx context.Context, key string, lockKey int64, ttl time.Duration) (lock.Lock, error) {
+	if err := l.createSequence(ctx); err != nil {
+		return nil, err
+	}
+
+	// session advisory locks belong to a connection, it is kept until the lock is released.
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "acquiring connection")
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, lockKey).Scan(&acquired); err != nil {
+		_ = conn.Close() //nolint
+		return nil, errors.Wrapf(err, "acquiring lock '%s'", key)
+	}
+	if !acquired {
+		_ = conn.Close() //nolint
+		return nil, lock.ErrNotAcquired
+	}
+
+	al := &advisoryLock{
+		conn: conn,
+		key:  lockKey,
+	}
+	// the token is drawn after the lock is acquired, so a later holder always gets a greater one.
+	if err := conn.QueryRowContext(ctx, `SELECT nextval('`+tokenSequence+`')`).Scan(&al.token); err != nil {
+		_ = al.releaseLocked(context.Background()) //nolint
+		return nil, errors.Wrapf(err, "drawing token of lock '%s'", key)
+	}
+	al.timer = time.AfterFunc(ttl, func() {
+		_ = al.release(context.Background()) //nolint
+	})
+	return al, nil
+}
+
+// createSequence creates the sequence of the fencing tokens, once.
+func (l *AdvisoryLocker) createSequence(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sequence {
+		return nil
+	}
+	if _, err := l.db.ExecContext(ctx, `CREATE SEQUENCE IF NOT EXISTS `+tokenSequence); err != nil {
+		return errors.Wrap(err, "creating lock token sequence")
+	}
+	l.sequence = true
+	return nil
+}
+
+// advisoryKey hashes the key into an advisory lock key.
+func advisoryKey(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key)) //nolint
+	return int64(h.Sum64())
+}
+
+// advisoryLock is a lock acquired by the AdvisoryLocker.
+type advisoryLock struct {
+	conn  *sql.Conn
+	key   int64
+	token int64
+	timer *time.Timer
+
+	mu       sync.Mutex
+	released bool
+	err      error
+}
+
+// Token returns the fencing token of the lock.
+func (l *advisoryLock) Token() int64 {
+	return l.token
+}
+
+// Extend renews the lease of the lock, after checking its connection is still alive.
+func (l *advisoryLock) Extend(ctx context.Context, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.released || !l.timer.Stop() {
+		return lock.ErrNotHeld
+	}
+	if err := l.conn.PingContext(ctx); err != nil {
+		// the lock is released with the connection.
+		_ = l.releaseLocked(ctx) //nolint
+		return lock.ErrNotHeld
+	}
+	l.timer.Reset(ttl)
+	return nil
+}
+
+// Unlock releases the lock if it is still held, and returns its connection to the pool.
+func (l *advisoryLock) Unlock(ctx context.Context) error {
+	l.timer.Stop()
+	return l.release(ctx)
+}
+
+func (l *advisoryLock) release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.releaseLocked(ctx)
+}
+
+func (l *advisoryLock) releaseLocked(ctx context.Context) error {
+	if l.released {
+		return l.err
+	}
+	l.released = true
+	defer l.conn.Close() //nolint
+
+	if _, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		l.err = errors.Wrap(err, "releasing lock")
+	}
+	return l.err
+}
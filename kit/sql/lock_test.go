@@ -0,0 +1,99 @@
+package sql
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/lock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdvisoryLocker(t *testing.T) {
+	if os.Getenv("TESTINGDB_URL") == "" {
+		t.Skip("Skipping, no testing database setup via env variable TESTINGDB_URL")
+	}
+
+	var tdb TestingDB
+	err := tdb.Open()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer tdb.Close()
+
+	db, err := Open(tdb.DSN)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	locker, err := NewAdvisoryLocker(db)
+	assert.NoError(t, err)
+
+	t.Run("contention", func(t *testing.T) {
+		l, err := locker.TryLock(ctx, "contention", time.Minute)
+		assert.NoError(t, err)
+
+		_, err = locker.TryLock(ctx, "contention", time.Minute)
+		assert.ErrorIs(t, err, lock.ErrNotAcquired)
+
+		assert.NoError(t, l.Unlock(ctx))
+		l2, err := locker.TryLock(ctx, "contention", time.Minute)
+		assert.NoError(t, err)
+		assert.Greater(t, l2.Token(), l.Token())
+		assert.NoError(t, l2.Unlock(ctx))
+	})
+
+	t.Run("tokens", func(t *testing.T) {
+		other, err := NewAdvisoryLocker(db)
+		assert.NoError(t, err)
+
+		// the tokens increase across the connections, in the order of acquisition.
+		l, err := locker.TryLock(ctx, "tokens-1", time.Minute)
+		assert.NoError(t, err)
+		l2, err := other.TryLock(ctx, "tokens-2", time.Minute)
+		assert.NoError(t, err)
+		assert.Greater(t, l2.Token(), l.Token())
+		assert.NoError(t, l.Unlock(ctx))
+		assert.NoError(t, l2.Unlock(ctx))
+	})
+
+	t.Run("scoped", func(t *testing.T) {
+		l1, err := locker.TryLockScoped(ctx, "scoped", "1", time.Minute)
+		assert.NoError(t, err)
+		_, err = locker.TryLockScoped(ctx, "scoped", "1", time.Minute)
+		assert.ErrorIs(t, err, lock.ErrNotAcquired)
+
+		// the scopes are locked independently, with increasing tokens.
+		l2, err := locker.TryLockScoped(ctx, "scoped", "2", time.Minute)
+		assert.NoError(t, err)
+		assert.Greater(t, l2.Token(), l1.Token())
+		assert.NoError(t, l1.Unlock(ctx))
+		assert.NoError(t, l2.Unlock(ctx))
+	})
+
+	t.Run("extend", func(t *testing.T) {
+		l, err := locker.TryLock(ctx, "extend", 100*time.Millisecond)
+		assert.NoError(t, err)
+		assert.NoError(t, l.Extend(ctx, time.Minute))
+
+		time.Sleep(200 * time.Millisecond)
+		_, err = locker.TryLock(ctx, "extend", time.Minute)
+		assert.ErrorIs(t, err, lock.ErrNotAcquired)
+		assert.NoError(t, l.Unlock(ctx))
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		l, err := locker.TryLock(ctx, "expiration", 50*time.Millisecond)
+		assert.NoError(t, err)
+
+		time.Sleep(100 * time.Millisecond)
+		assert.ErrorIs(t, l.Extend(ctx, time.Minute), lock.ErrNotHeld)
+
+		l2, err := locker.TryLock(ctx, "expiration", time.Minute)
+		assert.NoError(t, err)
+		assert.NoError(t, l2.Unlock(ctx))
+	})
+}
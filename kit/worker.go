@@ -0,0 +1,260 @@
+package kit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// workerTracer represents the workers tracer.
+var workerTracer = otel.Tracer("kit/worker")
+
+// WorkerFunc represents a long-lived background loop, such as a pubsub consumer,
+// a periodic reconciler or a cache warmer.
+//
+// It should run until the context is canceled, returning an error restarts it after a backoff.
+type WorkerFunc func(ctx context.Context) error
+
+// WorkerState represents the state of a worker.
+type WorkerState string
+
+// Worker states.
+const (
+	// WorkerPending is the state of a worker registered and not started yet.
+	WorkerPending WorkerState = "pending"
+	// WorkerRunning is the state of a running worker.
+	WorkerRunning WorkerState = "running"
+	// WorkerBackoff is the state of a worker that failed and waits to be restarted.
+	WorkerBackoff WorkerState = "backoff"
+	// WorkerStopped is the state of a worker that returned without error or has been canceled.
+	WorkerStopped WorkerState = "stopped"
+)
+
+// WorkerOption defines a worker option.
+type WorkerOption func(*workerOptions)
+
+// workerOptions provides a set of configurable options for a worker.
+type workerOptions struct {
+	initialInterval time.Duration
+	maxInterval     time.Duration
+}
+
+// worker is a registered WorkerFunc and its state.
+type worker struct {
+	name string
+	fn   WorkerFunc
+	opts workerOptions
+
+	mu       sync.Mutex
+	state    WorkerState
+	restarts int
+	lastErr  error
+}
+
+// RegisterWorker registers a background worker, started by Serve and supervised by the Foundation.
+//
+// Each run of the worker gets its own span, and a logger tagged with the worker name,
+// read from its context with log.FromContext. The context is canceled during the kit.PhaseDrain
+// shutdown phase. When the worker returns an error or panics, it is restarted with an exponential backoff,
+// and /readyz fails until it runs again.
+//
+//	foundation.RegisterWorker("outbox-relay", func(ctx context.Context) error {
+//		return relay.Run(ctx)
+//	})
+//
+// Workers must be registered before calling Serve.
+func (f *Foundation) RegisterWorker(name string, fn WorkerFunc, opts ...WorkerOption) {
+	// default options
+	o := workerOptions{
+		initialInterval: time.Second,
+		maxInterval:     time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	f.workersLock.Lock()
+	defer f.workersLock.Unlock()
+	f.workers = append(f.workers, &worker{
+		name:  name,
+		fn:    fn,
+		opts:  o,
+		state: WorkerPending,
+	})
+}
+
+// startWorkers starts the registered workers, and registers their cancellation as a drain closer.
+func (f *Foundation) startWorkers() {
+	f.workersLock.Lock()
+	workers := f.workers
+	f.workersLock.Unlock()
+	if len(workers) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			f.superviseWorker(ctx, w)
+		}(w)
+	}
+
+	f.RegisterCloser(PhaseDrain, "workers", func(closeCtx context.Context) error {
+		cancel()
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-closeCtx.Done():
+			return closeCtx.Err()
+		}
+	})
+}
+
+// superviseWorker runs the worker until the context is canceled, restarting it on failure.
+func (f *Foundation) superviseWorker(ctx context.Context, w *worker) {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = w.opts.initialInterval
+	b.MaxInterval = w.opts.maxInterval
+	b.MaxElapsedTime = 0 // 0 means it never expires
+
+	for {
+		w.setState(WorkerRunning, nil)
+		start := time.Now()
+		err := f.runWorker(ctx, w)
+
+		if ctx.Err() != nil || err == nil {
+			w.setState(WorkerStopped, err)
+			f.logger.Info(ctx, "worker stopped", log.String("worker", w.name))
+			return
+		}
+
+		// the worker ran long enough to be considered healthy again.
+		if time.Since(start) > w.opts.maxInterval {
+			b.Reset()
+		}
+		wait := b.NextBackOff()
+		w.setState(WorkerBackoff, err)
+		f.logger.Error(ctx, "worker failed",
+			log.String("worker", w.name),
+			log.Duration("restart.backoff", wait),
+			log.Error(err))
+
+		select {
+		case <-ctx.Done():
+			w.setState(WorkerStopped, err)
+			return
+		case <-time.After(wait):
+		}
+		w.restart()
+	}
+}
+
+// runWorker runs the worker once within a span, turning panics into errors.
+func (f *Foundation) runWorker(ctx context.Context, w *worker) (err error) {
+	ctx, span := workerTracer.Start(ctx, fmt.Sprintf("Worker %s", w.name))
+	span.SetAttributes(
+		attribute.String("worker", w.name),
+		attribute.Int("worker.restarts", w.restartCount()),
+	)
+	defer span.End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Newf("worker panicked: %v", r)
+		}
+		if err != nil && ctx.Err() == nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}()
+
+	logger := f.logger.With(log.String("worker", w.name))
+	ctx = log.NewContext(ctx, logger)
+	logger.Info(ctx, "worker started")
+	return w.fn(ctx)
+}
+
+// unhealthyWorkers returns the status of the workers waiting to be restarted.
+func (f *Foundation) unhealthyWorkers() []string {
+	f.workersLock.Lock()
+	workers := f.workers
+	f.workersLock.Unlock()
+
+	var unhealthy []string
+	for _, w := range workers {
+		w.mu.Lock()
+		if w.state == WorkerBackoff {
+			unhealthy = append(unhealthy, fmt.Sprintf("worker %s %s after %d restarts: %v", w.name, w.state, w.restarts, w.lastErr))
+		}
+		w.mu.Unlock()
+	}
+	sort.Strings(unhealthy)
+	return unhealthy
+}
+
+// workersHandler fails when a worker waits to be restarted, and otherwise calls next.
+func (f *Foundation) workersHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if unhealthy := f.unhealthyWorkers(); len(unhealthy) > 0 {
+			writer.Header().Set("Content-Type", "text/plain")
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(writer, strings.Join(unhealthy, "\n")) //nolint
+			return
+		}
+		next(writer, request)
+	}
+}
+
+func (w *worker) setState(state WorkerState, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.state = state
+	w.lastErr = err
+}
+
+func (w *worker) restart() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.restarts++
+}
+
+func (w *worker) restartCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.restarts
+}
+
+// WithWorkerBackoff defines the exponential backoff between two restarts of a worker.
+//
+// Backoff defaults to 1s initial interval and 1m max interval.
+func WithWorkerBackoff(initial time.Duration, max time.Duration) WorkerOption {
+	return func(o *workerOptions) {
+		if initial > 0 {
+			o.initialInterval = initial
+		}
+		if max > 0 {
+			o.maxInterval = max
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package kit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mukhtarkv/workspace/kit/errors"
+	"github.com/mukhtarkv/workspace/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerRestartAndCancel(t *testing.T) {
+	f, err := NewFoundation("test")
+	assert.NoError(t, err)
+
+	var runs atomic.Int32
+	var scoped atomic.Bool
+	release := make(chan struct{})
+	f.RegisterWorker("consumer", func(ctx context.Context) error {
+		// the worker logs with its own logger.
+		scoped.Store(log.FromContext(ctx) != log.L())
+		switch runs.Add(1) {
+		case 1:
+			return errors.New("connection lost")
+		case 2:
+			// stay in backoff until released.
+			<-release
+			panic("unexpected message")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithWorkerBackoff(50*time.Millisecond, 100*time.Millisecond))
+
+	readiness := func() int {
+		rec := httptest.NewRecorder()
+		f.readinessHandler()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code
+	}
+
+	f.startWorkers()
+
+	// the worker is restarted after failing.
+	assert.Eventually(t, func() bool { return runs.Load() == 2 }, time.Second, time.Millisecond)
+	assert.True(t, scoped.Load())
+	assert.Equal(t, http.StatusOK, readiness())
+
+	// the readiness fails while the worker waits to be restarted after a panic.
+	close(release)
+	assert.Eventually(t, func() bool { return readiness() == http.StatusServiceUnavailable }, time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool { return runs.Load() == 3 && readiness() == http.StatusOK }, time.Second, time.Millisecond)
+
+	// the drain phase cancels the workers and waits for them.
+	f.shutdown()
+	assert.Equal(t, WorkerStopped, f.workers[0].state)
+	assert.Equal(t, 2, f.workers[0].restarts)
+}
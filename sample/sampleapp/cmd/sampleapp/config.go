@@ -0,0 +1,15 @@
+package main
+
+import "github.com/mukhtarkv/workspace/kit"
+
+// serviceConfig is the configuration of the service, read from the config map:
+//
+//	auth:
+//	  enabled: true
+//	  jwt:
+//	    issuer: https://issuer.example.com
+//	    jwksURL: https://issuer.example.com/.well-known/jwks.json
+//	  policyFile: /etc/app/policy.yaml
+type serviceConfig struct {
+	Auth kit.AuthConfig `yaml:"auth"`
+}
@@ -36,8 +36,18 @@ func main() {
 		l.Fatal(ctx, err.Error())
 	}
 
+	// Read the service configuration, the calls are authorized if enabled.
+	var conf serviceConfig
+	if err := config.FromConfigMap(&conf); err != nil {
+		l.Fatal(ctx, "fail reading configuration", log.Error(err))
+	}
+	authOpts, err := conf.Auth.Options(ctx)
+	if err != nil {
+		l.Fatal(ctx, "fail setting up authorization", log.Error(err))
+	}
+
 	// Initialise the foundation and start the service
-	foundation, err := kit.NewFoundation("sampleapp", kit.WithLogger(l))
+	foundation, err := kit.NewFoundation("sampleapp", append(authOpts, kit.WithLogger(l))...)
 	if err != nil {
 		l.Fatal(ctx, err.Error())
 	}
@@ -74,5 +84,6 @@ func main() {
 	// 4. Graceful shutdown
 	if err := foundation.Serve(); err != nil {
 		l.Error(ctx, "fail serving", log.Error(err))
+		l.Close()
 	}
 }
@@ -0,0 +1,57 @@
+package todoapp
+
+import (
+	"context"
+
+	"github.com/mukhtarkv/workspace/kit/cache"
+)
+
+// Verify interface compliance
+var _ ToDoStorage = (*CachedStorage)(nil)
+
+// queryList is the name of the cached List query.
+const queryList = "list"
+
+// CachedStorage is a ToDoStorage caching the todo items of another storage.
+// Fetch is always cached, List only when its TTL is defined with cache.WithQueryTTL("list", ttl).
+type CachedStorage struct {
+	storage ToDoStorage
+	repo    *cache.Repository[*ToDoItem]
+}
+
+// NewCachedStorage creates a new CachedStorage over the given storage, caching the todo items in c.
+func NewCachedStorage(storage ToDoStorage, c cache.Cache, opts ...cache.RepositoryOption) *CachedStorage {
+	return &CachedStorage{
+		storage: storage,
+		repo: cache.NewRepository(c, "todo", func(item *ToDoItem) string {
+			return item.Id
+		}, opts...),
+	}
+}
+
+func (s *CachedStorage) Fetch(ctx context.Context, id string) (*ToDoItem, error) {
+	return s.repo.Fetch(ctx, id, s.storage.Fetch)
+}
+
+func (s *CachedStorage) List(ctx context.Context) ([]ToDoItem, error) {
+	var items []ToDoItem
+	err := s.repo.Query(ctx, queryList, &items, func(ctx context.Context) (interface{}, error) {
+		return s.storage.List(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *CachedStorage) Create(ctx context.Context, item *ToDoItem) error {
+	return s.repo.Create(ctx, item, s.storage.Create)
+}
+
+func (s *CachedStorage) Update(ctx context.Context, item *ToDoItem) error {
+	return s.repo.Update(ctx, item, s.storage.Update)
+}
+
+func (s *CachedStorage) Delete(ctx context.Context, id string) error {
+	return s.repo.Delete(ctx, id, s.storage.Delete)
+}
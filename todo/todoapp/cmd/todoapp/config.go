@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+
+	"github.com/mukhtarkv/workspace/kit"
+	"github.com/mukhtarkv/workspace/kit/cache"
+	"github.com/mukhtarkv/workspace/kit/cache/memory"
+	"github.com/mukhtarkv/workspace/kit/cache/redis"
+	"github.com/mukhtarkv/workspace/todo/todoapp"
+)
+
+// serviceConfig is the configuration of the service, read from the config map:
+//
+//	cache:
+//	  redis:
+//	    addrs: ["redis:6379"]
+//	  todo:
+//	    enabled: true
+//	    ttl: 5m
+//	    queries:
+//	      list: 30s
+//	auth:
+//	  enabled: true
+//	  jwt:
+//	    issuer: https://issuer.example.com
+//	    jwksURL: https://issuer.example.com/.well-known/jwks.json
+//	  policyFile: /etc/app/policy.yaml
+type serviceConfig struct {
+	Cache struct {
+		// Redis is the cache shared by the replicas, the todo items are cached in memory without addrs.
+		Redis redis.Config           `yaml:"redis"`
+		ToDo  cache.RepositoryConfig `yaml:"todo"`
+	} `yaml:"cache"`
+	Auth kit.AuthConfig `yaml:"auth"`
+}
+
+// cachedStorage decorates the storage with the cache defined by the configuration,
+// it returns the storage as it is when the cache is disabled.
+func cachedStorage(conf *serviceConfig, storage todoapp.ToDoStorage, foundation *kit.Foundation) (todoapp.ToDoStorage, error) {
+	if !conf.Cache.ToDo.Enabled {
+		return storage, nil
+	}
+
+	if len(conf.Cache.Redis.Addrs) == 0 {
+		c, err := memory.New(memory.WithName("todo"))
+		if err != nil {
+			return nil, err
+		}
+		return todoapp.NewCachedStorage(storage, c, conf.Cache.ToDo.Options()...), nil
+	}
+
+	c, err := conf.Cache.Redis.New()
+	if err != nil {
+		return nil, err
+	}
+	// Close the cache once the servers stopped.
+	foundation.RegisterCloser(kit.PhaseResources, "redis", func(ctx context.Context) error {
+		return c.Close()
+	})
+	return todoapp.NewCachedStorage(storage, c, conf.Cache.ToDo.Options()...), nil
+}
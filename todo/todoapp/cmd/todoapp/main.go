@@ -42,18 +42,37 @@ func main() {
 		l.Fatal(ctx, err.Error())
 	}
 
-	todoService := todoapp.New(storage)
-	srv, err := newGrpcToDo(todoService)
+	// Read the service configuration, the todo items are cached and the calls authorized if enabled.
+	var conf serviceConfig
+	if err := config.FromConfigMap(&conf); err != nil {
+		l.Fatal(ctx, "fail reading configuration", log.Error(err))
+	}
+	authOpts, err := conf.Auth.Options(ctx)
 	if err != nil {
-		l.Fatal(ctx, err.Error())
+		l.Fatal(ctx, "fail setting up authorization", log.Error(err))
 	}
 
 	// Initialise the foundation and start the service
-	foundation, err := kit.NewFoundation("todoapp", kit.WithLogger(l))
+	foundation, err := kit.NewFoundation("todoapp", append(authOpts, kit.WithLogger(l))...)
+	if err != nil {
+		l.Fatal(ctx, err.Error())
+	}
+	todoStorage, err := cachedStorage(&conf, storage, foundation)
+	if err != nil {
+		l.Fatal(ctx, "fail setting up cache", log.Error(err))
+	}
+
+	todoService := todoapp.New(todoStorage)
+	srv, err := newGrpcToDo(todoService)
 	if err != nil {
 		l.Fatal(ctx, err.Error())
 	}
 
+	// Close the database once the servers stopped.
+	foundation.RegisterCloser(kit.PhaseResources, "postgres", func(ctx context.Context) error {
+		return storage.DB.Close()
+	})
+
 	// Register the GRPC Server.
 	foundation.RegisterService(func(s *grpc.Server) {
 		pb.RegisterToDoAppServer(s, srv)
@@ -77,5 +96,6 @@ func main() {
 	// 4. Graceful shutdown
 	if err := foundation.Serve(); err != nil {
 		l.Error(ctx, "fail serving", log.Error(err))
+		l.Close()
 	}
 }